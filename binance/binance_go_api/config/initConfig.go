@@ -37,11 +37,32 @@ const (
 
 // API paths
 const (
-	PATH_PING               = "/api/v3/ping"
-	PATH_TIME               = "/api/v3/time"
-	PATH_EXCHANGE_INFO      = "/api/v3/exchangeInfo"
-	PATH_GET_ACCOUNT_STATUS = "/sapi/v1/account/status"
-	PATH_WALLET_STATUS      = "/sapi/v1/system/status"
+	PATH_PING                 = "/api/v3/ping"
+	PATH_TIME                 = "/api/v3/time"
+	PATH_EXCHANGE_INFO        = "/api/v3/exchangeInfo"
+	PATH_GET_ACCOUNT_STATUS   = "/sapi/v1/account/status"
+	PATH_WALLET_STATUS        = "/sapi/v1/system/status"
+	PATH_ORDER                = "/api/v3/order"
+	PATH_ORDER_TEST           = "/api/v3/order/test"
+	PATH_ORDER_CANCEL_REPLACE = "/api/v3/order/cancelReplace"
+	PATH_ORDER_LIST_OCO       = "/api/v3/orderList/oco"
+	PATH_ORDER_LIST_OTO       = "/api/v3/orderList/oto"
+	PATH_ORDER_LIST_OTOCO     = "/api/v3/orderList/otoco"
+	PATH_ORDER_LIST           = "/api/v3/orderList"
+	PATH_ALL_ORDER_LIST       = "/api/v3/allOrderList"
+	PATH_OPEN_ORDER_LIST      = "/api/v3/openOrderList"
+	PATH_SOR_ORDER            = "/api/v3/sor/order"
+	PATH_SOR_ORDER_TEST       = "/api/v3/sor/order/test"
+	PATH_MY_ALLOCATIONS       = "/api/v3/myAllocations"
+	PATH_ORDER_AMEND          = "/api/v3/order/amend/keepPriority"
+	PATH_ORDER_AMENDMENTS     = "/api/v3/order/amendments"
+	PATH_MY_PREVENTED_MATCHES = "/api/v3/myPreventedMatches"
+)
+
+// Websocket paths
+const (
+	PATH_WS     = "/ws"
+	PATH_WS_API = "/ws-api/v3"
 )
 
 // TODO: Not sure I need these, since they are already set in the paths above.
@@ -49,8 +70,6 @@ const (
 // const (
 // 	PATH_API    = "/api"
 // 	PATH_SAPI   = "/sapi"
-// 	PATH_WS     = "/ws"
-// 	PATH_WS_API = "/ws-api/v3"
 // 	PATH_STREAM = "/stream"
 // )
 
@@ -58,3 +77,71 @@ const (
 	TIMEOUT_DURATION_MILLISECOND = 1000
 	TIMEOUT                      = time.Duration(TIMEOUT_DURATION_MILLISECOND) * time.Millisecond
 )
+
+// User Data Streams
+// A listenKey is valid for 60 minutes after creation; a keepalive extends it by another 60 minutes
+const (
+	LISTEN_KEY_KEEPALIVE_INTERVAL = 30 * time.Minute
+	USER_STREAM_RECONNECT_DELAY   = 5 * time.Second
+)
+
+// WebSocket API
+const (
+	WS_API_RECONNECT_DELAY = 5 * time.Second
+)
+
+// Exchange info
+// The full document is several megabytes, so it is cached and refreshed in the background
+const (
+	EXCHANGE_INFO_REFRESH_INTERVAL = 10 * time.Minute
+)
+
+// Startup reconciliation
+// allOrders and myTrades only accept a 24 hour window between startTime and endTime
+const (
+	RECONCILE_LOOKBACK = 24 * time.Hour
+	RECONCILE_LIMIT    = 1000
+)
+
+// Kill switch
+// Cancels are spread out so that a full cancel does not hit the order rate limit
+const (
+	KILL_SWITCH_CONCURRENCY      = 5
+	KILL_SWITCH_REQUESTS_PER_SEC = 10
+	KILL_SWITCH_HTTP_ADDR        = "127.0.0.1:8099"
+	KILL_SWITCH_SECRET_HEADER    = "X-Kill-Switch-Secret"
+)
+
+// Pre-trade risk checks
+const (
+	RISK_PRICE_CACHE_TTL = time.Second // reuse getTickersPrice results for the price collar within this window
+)
+
+// Self-trade prevention
+const (
+	PREVENTED_MATCHES_LIMIT = 1000           // max page size of myPreventedMatches
+	ALL_ORDERS_MAX_WINDOW   = 24 * time.Hour // allOrders rejects startTime/endTime ranges longer than this
+)
+
+// Execution algorithms
+const (
+	KLINES_LIMIT               = 1000            // max klines per request
+	RECENT_TRADES_LIMIT        = 1000            // max trades per getRecentTradeList request
+	VWAP_PROFILE_LOOKBACK_DAYS = 5               // days of kline volume averaged into the VWAP profile
+	VWAP_BENCHMARK_INTERVAL    = "1m"            // kline interval used to compute the market VWAP benchmark
+	POV_POLL_INTERVAL          = 2 * time.Second // how often POV checks realised market volume
+	POV_OWN_FILL_WINDOW        = time.Minute     // how long POV keeps counted market trades to match our own fills reported later
+)
+
+// Recurring buys
+const (
+	DCA_CHECK_INTERVAL = 30 * time.Second // how often DCA plans check their schedule and price-drop trigger
+)
+
+// Paper trading
+const (
+	PAPER_MAKER_COMMISSION      = 0.001           // default maker fee rate for simulated fills
+	PAPER_TAKER_COMMISSION      = 0.001           // default taker fee rate for simulated fills
+	PAPER_DEPTH_LIMIT           = 100             // order book levels read when a simulated order takes liquidity
+	PAPER_TRADING_POLL_INTERVAL = 2 * time.Second // how often resting paper orders are matched against market trades
+)
@@ -0,0 +1,141 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// 减少挂单数量并保留排队优先级，orderId 和 origClientOrderId 二选一
+type AmendOrder struct {
+	orderId           *int64
+	origClientOrderId *string
+	newClientOrderId  *string
+	recvWindow        *int
+}
+
+// 查询订单的修改记录
+type OrderAmendments struct {
+	fromExecutionId *int64
+	limit           *int
+	recvWindow      *int
+}
+
+// 修改订单的返回
+type AmendOrderResponse struct {
+	TransactTime uint64 `json:"transactTime"`
+	ExecutionId  int64  `json:"executionId"`
+	AmendedOrder struct {
+		Symbol                  string  `json:"symbol"`
+		OrderId                 int64   `json:"orderId"`
+		OrderListId             int64   `json:"orderListId"`
+		OrigClientOrderId       string  `json:"origClientOrderId"`
+		ClientOrderId           string  `json:"clientOrderId"`
+		Price                   Decimal `json:"price"`
+		Qty                     Decimal `json:"qty"`
+		ExecutedQty             Decimal `json:"executedQty"`
+		PreventedQty            Decimal `json:"preventedQty"`
+		QuoteOrderQty           Decimal `json:"quoteOrderQty"`
+		CumulativeQuoteQty      Decimal `json:"cumulativeQuoteQty"`
+		Status                  string  `json:"status"`
+		TimeInForce             string  `json:"timeInForce"`
+		Type                    string  `json:"type"`
+		Side                    string  `json:"side"`
+		WorkingTime             uint64  `json:"workingTime"`
+		SelfTradePreventionMode string  `json:"selfTradePreventionMode"`
+	} `json:"amendedOrder"`
+	ListStatus *OrderListResponse `json:"listStatus,omitempty"`
+}
+
+// 订单修改记录
+type OrderAmendmentResponse struct {
+	Symbol            string  `json:"symbol"`
+	OrderId           int64   `json:"orderId"`
+	ExecutionId       int64   `json:"executionId"`
+	OrigClientOrderId string  `json:"origClientOrderId"`
+	NewClientOrderId  string  `json:"newClientOrderId"`
+	OrigQty           Decimal `json:"origQty"`
+	NewQty            Decimal `json:"newQty"`
+	Time              uint64  `json:"time"`
+}
+
+func amendOrderParams(symbol string, newQty Decimal, ao AmendOrder) map[string]string {
+	params := map[string]string{
+		"symbol": symbol,
+		"newQty": newQty.String(),
+	}
+	if ao.orderId != nil {
+		params["orderId"] = strconv.FormatInt(*ao.orderId, 10)
+	}
+	if ao.origClientOrderId != nil {
+		params["origClientOrderId"] = *ao.origClientOrderId
+	}
+	if ao.newClientOrderId != nil {
+		params["newClientOrderId"] = *ao.newClientOrderId
+	}
+	if ao.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*ao.recvWindow)
+	}
+	return params
+}
+
+// 减少挂单数量并保留排队优先级 - PUT /api/v3/order/amend/keepPriority
+func amendOrderKeepPriority(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	newQty Decimal,
+	timestamp int64,
+	ao AmendOrder,
+	proxyURL string,
+) (*AmendOrderResponse, error) {
+	params := amendOrderParams(symbol, newQty, ao)
+	if isDryRun() {
+		// 这个接口没有测试版本，dry-run 时只打印
+		fmt.Println("[dry-run] PUT "+initConfig.PATH_ORDER_AMEND, sortedQuery(params))
+		amendOrder := new(AmendOrderResponse)
+		amendOrder.AmendedOrder.Symbol = symbol
+		amendOrder.AmendedOrder.Status = dryRunResult
+		return amendOrder, nil
+	}
+
+	amendOrder := new(AmendOrderResponse)
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodPut, initConfig.PATH_ORDER_AMEND, params, proxyURL, amendOrder)
+	if err != nil {
+		return nil, err
+	}
+	return amendOrder, nil
+}
+
+// 查询订单的修改记录 - GET /api/v3/order/amendments
+func getOrderAmendments(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	orderId int64,
+	timestamp int64,
+	oa OrderAmendments,
+	proxyURL string,
+) ([]*OrderAmendmentResponse, error) {
+	params := map[string]string{
+		"symbol":  symbol,
+		"orderId": strconv.FormatInt(orderId, 10),
+	}
+	if oa.fromExecutionId != nil {
+		params["fromExecutionId"] = strconv.FormatInt(*oa.fromExecutionId, 10)
+	}
+	if oa.limit != nil {
+		params["limit"] = strconv.Itoa(*oa.limit)
+	}
+	if oa.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*oa.recvWindow)
+	}
+
+	var amendments []*OrderAmendmentResponse
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodGet, initConfig.PATH_ORDER_AMENDMENTS, params, proxyURL, &amendments)
+	if err != nil {
+		return nil, err
+	}
+	return amendments, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	initConfig "binance/binance_go_api/config"
 	"binance_connector"
 	"context"
 	"encoding/json"
@@ -8,6 +9,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -56,6 +59,15 @@ type AggregateTrades struct {
 	limit     *int
 }
 
+// k线
+type Kline struct {
+	interval  string //enum
+	startTime *uint64
+	endTime   *uint64
+	timeZone  *string
+	limit     *int
+}
+
 // 一个或多个token
 type inputTokens struct {
 	symbol  *string
@@ -64,18 +76,18 @@ type inputTokens struct {
 
 // 新订单
 type NewOrder struct {
-	timeInForce             *string
-	quantity                *float64
-	quoteOrderQty           *float64
-	price                   *float64
+	timeInForce             *TimeInForce
+	quantity                *Decimal
+	quoteOrderQty           *Decimal
+	price                   *Decimal
 	newClientOrderId        *string
 	strategyId              *int
 	strategyType            *int
-	stopPrice               *float64
+	stopPrice               *Decimal
 	trailingDelta           *int
-	icebergQty              *float64
-	newOrderRespType        *string
-	selfTradePreventionMode *string
+	icebergQty              *Decimal
+	newOrderRespType        *NewOrderRespType
+	selfTradePreventionMode *STPMode
 	recvWindow              *int
 }
 
@@ -121,21 +133,21 @@ type QueryOrder struct {
 
 // 替代
 type CancelReplace struct {
-	timeInForce             *string
-	quantity                *float64
-	quoteOrderQty           *float64
-	price                   *float64
+	timeInForce             *TimeInForce
+	quantity                *Decimal
+	quoteOrderQty           *Decimal
+	price                   *Decimal
 	cancelNewClientOrderId  *string
 	cancelOrigClientOrderId *string
 	cancelOrderId           *int64
 	newClientOrderId        *string
 	strategyId              *int32
 	strategyType            *int32
-	stopPrice               *float64
+	stopPrice               *Decimal
 	trailingDelta           *int64
-	icebergQty              *float64
-	newOrderRespType        *string
-	selfTradePreventionMode *string
+	icebergQty              *Decimal
+	newOrderRespType        *NewOrderRespType
+	selfTradePreventionMode *STPMode
 	cancelRestrictions      *string
 	recvWindow              *int
 }
@@ -213,6 +225,119 @@ func GetRequestJSON(url string) (map[string]interface{}, error) {
 	return jsonData, nil
 }
 
+// Binance 返回的错误
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Code       int64  `json:"code"`
+	Message    string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("<APIError> status=%d, code=%d, msg=%s", e.StatusCode, e.Code, e.Message)
+}
+
+// signedRequest 发送带签名的请求，用于 binance_connector 还没有封装的接口
+func signedRequest(
+	thisApiKey,
+	thisSecretKey,
+	method,
+	path string,
+	params map[string]string,
+	proxyURL string,
+) ([]byte, error) {
+	return sendRequest(thisApiKey, thisSecretKey, method, path, params, true, proxyURL)
+}
+
+func sendRequest(
+	thisApiKey,
+	thisSecretKey,
+	method,
+	path string,
+	params map[string]string,
+	signed bool,
+	proxyURL string,
+) ([]byte, error) {
+	reClient, clientErr := initClient(thisApiKey, thisSecretKey, proxyURL)
+	if clientErr != nil {
+		return nil, clientErr
+	}
+
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	if signed {
+		query.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli()-reClient.TimeOffset, 10))
+	}
+	rawQuery := query.Encode()
+	if signed {
+		rawQuery += "&signature=" + hmacSha256(thisSecretKey, rawQuery)
+	}
+
+	request, err := http.NewRequest(method, strings.TrimSuffix(reClient.BaseURL, "/")+path+"?"+rawQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+	if thisApiKey != "" {
+		request.Header.Set("X-MBX-APIKEY", thisApiKey)
+	}
+	response, err := reClient.HTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: response.StatusCode}
+		if json.Unmarshal(body, apiErr) != nil {
+			apiErr.Message = string(body)
+		}
+		return nil, apiErr
+	}
+	return body, nil
+}
+
+// 发送不需要签名的 GET 请求并把返回的 JSON 解码到 out
+func publicRequestJSON(
+	path string,
+	params map[string]string,
+	proxyURL string,
+	out interface{},
+) error {
+	data, err := sendRequest("", "", http.MethodGet, path, params, false, proxyURL)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// 发送签名请求并把返回的 JSON 解码到 out
+func signedRequestJSON(
+	thisApiKey,
+	thisSecretKey,
+	method,
+	path string,
+	params map[string]string,
+	proxyURL string,
+	out interface{},
+) error {
+	data, err := signedRequest(thisApiKey, thisSecretKey, method, path, params, proxyURL)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return err
+	}
+	fmt.Println(binance_connector.PrettyPrint(out))
+	return nil
+}
+
 func ping(
 	thisApiKey,
 	thisSecretKey string,
@@ -389,6 +514,36 @@ func getAggTradesList(
 	return aggTradesList, err
 }
 
+// k线数据 - GET /api/v3/klines
+func getKlines(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	k Kline,
+	proxyURL string,
+) ([]*binance_connector.KlinesResponse, error) {
+	reClient, clientErr := initClient(thisApiKey, thisSecretKey, proxyURL)
+	if clientErr != nil {
+		return nil, clientErr
+	}
+	// REST 接口不支持 timeZone
+	service := reClient.NewKlinesService().Symbol(symbol).Interval(k.interval)
+	if k.startTime != nil {
+		service = service.StartTime(*k.startTime)
+	}
+	if k.endTime != nil {
+		service = service.EndTime(*k.endTime)
+	}
+	if k.limit != nil {
+		service = service.Limit(*k.limit)
+	}
+	klines, err := service.Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return klines, err
+}
+
 // ticker
 func getTicker(
 	thisApiKey,
@@ -576,8 +731,12 @@ func getCurrentOpenOrders(
 		return nil, clientErr
 	}
 	// Binance Get current open orders - GET /api/v3/openOrders
-	getCurrentOpenOrders, err := reClient.NewGetOpenOrdersService().Symbol(symbol).
-		Do(context.Background())
+	// symbol 为空时返回所有交易对的挂单
+	service := reClient.NewGetOpenOrdersService()
+	if symbol != "" {
+		service = service.Symbol(symbol)
+	}
+	getCurrentOpenOrders, err := service.Do(context.Background())
 	if err != nil {
 		fmt.Println(err)
 		return nil, err
@@ -682,74 +841,31 @@ func QueryCurrentOrderCountUsage(
 	return getQueryCurrentOrderCountUsageService, err
 }
 
-// 创建新订单
+// 创建新订单，返回结构按 newOrderRespType 填充
 func createNewOrder(
 	thisApiKey,
 	thisSecretKey,
 	symbol string,
-	side string,
-	orderType string,
+	side OrderSide,
+	orderType OrderType,
 	timestamp int64,
 	no NewOrder,
 	proxyURL string,
-) (interface{}, error) {
-	reClient, clientErr := initClient(thisApiKey, thisSecretKey, proxyURL)
-	if clientErr != nil {
-		return nil, clientErr
-	}
-	service := reClient.NewCreateOrderService().Symbol(symbol).Side(side).Type(orderType)
-
-	if no.icebergQty != nil {
-		service = service.IcebergQuantity(*no.icebergQty)
-	}
-	if no.newClientOrderId != nil {
-		service = service.NewClientOrderId(*no.newClientOrderId)
-	}
-	if no.newOrderRespType != nil {
-		service = service.NewOrderRespType(*no.newOrderRespType)
-	}
-	if no.price != nil {
-		service = service.Price(*no.price)
-	}
-	if no.quantity != nil {
-		service = service.Quantity(*no.quantity)
-	}
-	if no.quoteOrderQty != nil {
-		service = service.QuoteOrderQty(*no.quoteOrderQty)
-	}
-	if no.selfTradePreventionMode != nil {
-		service = service.SelfTradePreventionMode(*no.selfTradePreventionMode)
-	}
-	if no.stopPrice != nil {
-		service = service.StopPrice(*no.stopPrice)
-	}
-	if no.strategyId != nil {
-		service = service.StrategyId(*no.strategyId)
-	}
-	if no.strategyType != nil {
-		service = service.StrategyType(*no.strategyType)
-	}
-	if no.timeInForce != nil {
-		service = service.TimeInForce(*no.timeInForce)
+) (*CreateOrderResponse, error) {
+	if err := validateNewOrder(side, orderType, no); err != nil {
+		return nil, err
 	}
-	if no.trailingDelta != nil {
-		service = service.TrailingDelta(*no.trailingDelta)
+	if isDryRun() {
+		return dryRunNewOrder(thisApiKey, thisSecretKey, symbol, side, orderType, timestamp, no, proxyURL)
 	}
 
-	// newOrder, err := reClient.NewCreateOrderService().Symbol(symbol).
-	// 	Side(side).Type(orderType).IcebergQuantity(no.icebergQty).
-	// 	NewClientOrderId(no.newClientOrderId).NewOrderRespType(no.newOrderRespType).
-	// 	Price(no.price).Quantity(no.quantity).
-	// 	QuoteOrderQty(no.quoteOrderQty).SelfTradePreventionMode(no.selfTradePreventionMode).
-	// 	StopPrice(no.stopPrice).StrategyId(no.strategyId).StrategyType(no.strategyType).
-	// 	TimeInForce(no.timeInForce).TrailingDelta(no.trailingDelta).Do(context.Background())
-	newOrder, err := service.Do(context.Background())
-
+	newOrder := &CreateOrderResponse{RespType: resolveNewOrderRespType(orderType, no)}
+	params := newOrderParams(symbol, side, orderType, no)
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodPost, initConfig.PATH_ORDER, params, proxyURL, newOrder)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println(binance_connector.PrettyPrint(newOrder))
-	return newOrder, err
+	return newOrder, nil
 }
 
 // 取消某个token订单
@@ -815,84 +931,26 @@ func cancelReplace(
 	thisApiKey,
 	thisSecretKey,
 	symbol string,
-	side string,
-	orderType string,
-	cancelReplaceMode string,
+	side OrderSide,
+	orderType OrderType,
+	cancelReplaceMode CancelReplaceMode,
 	timestamp int64,
 	cr CancelReplace,
 	proxyURL string,
 ) (*binance_connector.CancelReplaceResponse, error) {
-	reClient, clientErr := initClient(thisApiKey, thisSecretKey, proxyURL)
-	if clientErr != nil {
-		return nil, clientErr
-	}
-	service := reClient.NewCancelReplaceService().
-		Symbol(symbol).Side(side).OrderType(orderType).CancelReplaceMode(cancelReplaceMode)
-
-	if cr.cancelRestrictions != nil {
-		service = service.CancelRestrictions(*cr.cancelRestrictions)
-	}
-	if cr.cancelOrderId != nil {
-		service = service.CancelOrderId(*cr.cancelOrderId)
-	}
-	if cr.cancelNewClientOrderId != nil {
-		service = service.CancelNewClientOrderId(*cr.cancelNewClientOrderId)
-	}
-	if cr.cancelOrigClientOrderId != nil {
-		service = service.CancelOrigClientOrderId(*cr.cancelOrigClientOrderId)
-	}
-	if cr.timeInForce != nil {
-		service = service.TimeInForce(*cr.timeInForce)
-	}
-	if cr.icebergQty != nil {
-		service = service.IcebergQty(*cr.icebergQty)
-	}
-	if cr.quantity != nil {
-		service = service.Quantity(*cr.quantity)
-	}
-	if cr.price != nil {
-		service = service.Price(*cr.price)
-	}
-	if cr.newOrderRespType != nil {
-		service = service.NewOrderRespType(*cr.newOrderRespType)
-	}
-	if cr.newClientOrderId != nil {
-		service = service.NewClientOrderId(*cr.newClientOrderId)
-	}
-	if cr.selfTradePreventionMode != nil {
-		service = service.SelfTradePreventionMode(*cr.selfTradePreventionMode)
-	}
-	if cr.strategyId != nil {
-		service = service.StrategyId(*cr.strategyId)
-	}
-	if cr.strategyType != nil {
-		service = service.StrategyType(*cr.strategyType)
-	}
-	if cr.stopPrice != nil {
-		service = service.StopPrice(*cr.stopPrice)
-	}
-	if cr.timeInForce != nil {
-		service = service.TimeInForce(*cr.timeInForce)
+	if err := validateCancelReplace(side, orderType, cancelReplaceMode, cr); err != nil {
+		return nil, err
 	}
-	if cr.trailingDelta != nil {
-		service = service.TrailingDelta(*cr.trailingDelta)
+	if isDryRun() {
+		return dryRunCancelReplace(thisApiKey, thisSecretKey, symbol, side, orderType, cancelReplaceMode, timestamp, cr, proxyURL)
 	}
-
-	// cancelReplace, err := reClient.NewCancelReplaceService().
-	// 	Symbol(symbol).Side(side).OrderType(orderType).CancelRestrictions(cr.cancelRestrictions).
-	// 	CancelReplaceMode(cancelReplaceMode).CancelOrderId(cr.cancelOrderId).
-	// 	CancelNewClientOrderId(cr.cancelNewClientOrderId).CancelOrigClientOrderId(cr.cancelOrigClientOrderId).
-	// 	TimeInForce(cr.timeInForce).IcebergQty(cr.icebergQty).Quantity(cr.quantity).
-	// 	Price(cr.price).NewOrderRespType(cr.newOrderRespType).NewClientOrderId(cr.newClientOrderId).
-	// 	SelfTradePreventionMode(cr.selfTradePreventionMode).StrategyId(cr.strategyId).StrategyType(cr.strategyType).
-	// 	StopPrice(cr.stopPrice).TimeInForce(cr.timeInForce).TrailingDelta(cr.trailingDelta).Do(context.Background())
-	cancelReplace, err := service.Do(context.Background())
+	cancelReplace := new(binance_connector.CancelReplaceResponse)
+	params := cancelReplaceParams(symbol, side, orderType, cancelReplaceMode, cr)
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodPost, initConfig.PATH_ORDER_CANCEL_REPLACE, params, proxyURL, cancelReplace)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
-	fmt.Println(binance_connector.PrettyPrint(cancelReplace))
-	return cancelReplace, err
+	return cancelReplace, nil
 }
 
 func main() {
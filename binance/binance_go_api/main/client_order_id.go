@@ -0,0 +1,161 @@
+package main
+
+import (
+	"binance_connector"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// newClientOrderId 最长 36 个字符，只允许 [.A-Z:/a-z0-9_-]
+const (
+	clientOrderIdMaxLen    = 36
+	clientOrderIdSeparator = "-"
+	clientOrderIdSuffixLen = 4
+)
+
+// 前缀和策略 id 中不能出现分隔符，否则无法解析
+var clientOrderIdPartPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// 生成 <prefix>-<strategyId>-<sequence>-<suffix> 格式的 newClientOrderId
+// sequence 以毫秒时间戳为起点单调递增，重启后不会与之前的 id 重复；suffix 为随机字符，避免多进程冲突
+type ClientOrderIdGenerator struct {
+	prefix     string
+	strategyId string
+	sequence   atomic.Uint64
+}
+
+// 解析后的 newClientOrderId
+type ParsedClientOrderId struct {
+	Prefix     string
+	StrategyId string
+	Sequence   uint64
+	Suffix     string
+}
+
+func newClientOrderIdGenerator(prefix, strategyId string) (*ClientOrderIdGenerator, error) {
+	if !clientOrderIdPartPattern.MatchString(prefix) {
+		return nil, fmt.Errorf("client order id: invalid prefix %q", prefix)
+	}
+	if !clientOrderIdPartPattern.MatchString(strategyId) {
+		return nil, fmt.Errorf("client order id: invalid strategy id %q", strategyId)
+	}
+	g := &ClientOrderIdGenerator{prefix: prefix, strategyId: strategyId}
+	g.sequence.Store(uint64(time.Now().UnixMilli()))
+	// 序号按当前毫秒时间戳估算最大长度
+	if n := len(g.next()); n > clientOrderIdMaxLen {
+		return nil, fmt.Errorf("client order id: %s%s%s would be %d characters, max %d",
+			prefix, clientOrderIdSeparator, strategyId, n, clientOrderIdMaxLen)
+	}
+	return g, nil
+}
+
+// 生成下一个 id
+func (g *ClientOrderIdGenerator) next() string {
+	sequence := g.sequence.Add(1)
+	return strings.Join([]string{
+		g.prefix,
+		g.strategyId,
+		strconv.FormatUint(sequence, 36),
+		randomSuffix(clientOrderIdSuffixLen),
+	}, clientOrderIdSeparator)
+}
+
+// 该生成器产生的所有 id 的公共前缀，可用于 ReconcileConfig.clientOrderIdPrefix
+func (g *ClientOrderIdGenerator) namespace() string {
+	return g.prefix + clientOrderIdSeparator + g.strategyId + clientOrderIdSeparator
+}
+
+// 未指定 newClientOrderId 时自动填入
+func (g *ClientOrderIdGenerator) assignNewOrder(no NewOrder) NewOrder {
+	if no.newClientOrderId == nil {
+		id := g.next()
+		no.newClientOrderId = &id
+	}
+	return no
+}
+
+// 未指定新订单的 newClientOrderId 时自动填入
+func (g *ClientOrderIdGenerator) assignCancelReplace(cr CancelReplace) CancelReplace {
+	if cr.newClientOrderId == nil {
+		id := g.next()
+		cr.newClientOrderId = &id
+	}
+	return cr
+}
+
+func randomSuffix(n int) string {
+	const alphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+	base := big.NewInt(int64(len(alphabet)))
+	suffix := make([]byte, n)
+	for i := range suffix {
+		index, err := rand.Int(rand.Reader, base)
+		if err != nil {
+			// 系统随机源不可用时退化为时间戳
+			index = big.NewInt(time.Now().UnixNano() % int64(len(alphabet)))
+		}
+		suffix[i] = alphabet[index.Int64()]
+	}
+	return string(suffix)
+}
+
+// 解析 newClientOrderId，不是生成器产生的格式时返回 false
+func parseClientOrderId(clientOrderId string) (ParsedClientOrderId, bool) {
+	parts := strings.Split(clientOrderId, clientOrderIdSeparator)
+	if len(parts) != 4 {
+		return ParsedClientOrderId{}, false
+	}
+	if !clientOrderIdPartPattern.MatchString(parts[0]) || !clientOrderIdPartPattern.MatchString(parts[1]) {
+		return ParsedClientOrderId{}, false
+	}
+	sequence, err := strconv.ParseUint(parts[2], 36, 64)
+	if err != nil || len(parts[3]) != clientOrderIdSuffixLen {
+		return ParsedClientOrderId{}, false
+	}
+	return ParsedClientOrderId{
+		Prefix:     parts[0],
+		StrategyId: parts[1],
+		Sequence:   sequence,
+		Suffix:     parts[3],
+	}, true
+}
+
+// 按策略 id 对 getAllOrders 的结果分组，只统计 prefix 匹配的订单
+func attributeOrders(prefix string, orders []*binance_connector.NewAllOrdersResponse) map[string][]*binance_connector.NewAllOrdersResponse {
+	byStrategy := make(map[string][]*binance_connector.NewAllOrdersResponse)
+	for _, order := range orders {
+		parsed, ok := parseClientOrderId(order.ClientOrderId)
+		if !ok || parsed.Prefix != prefix {
+			continue
+		}
+		byStrategy[parsed.StrategyId] = append(byStrategy[parsed.StrategyId], order)
+	}
+	return byStrategy
+}
+
+// 按策略 id 对 getGetMyTrades 的结果分组
+// 成交记录里没有 clientOrderId，需要通过 orders（getAllOrders 的结果）按 orderId 关联
+func attributeTrades(
+	prefix string,
+	trades []*binance_connector.AccountTradeListResponse,
+	orders []*binance_connector.NewAllOrdersResponse,
+) map[string][]*binance_connector.AccountTradeListResponse {
+	strategyByOrder := make(map[int64]string)
+	for strategyId, strategyOrders := range attributeOrders(prefix, orders) {
+		for _, order := range strategyOrders {
+			strategyByOrder[order.OrderId] = strategyId
+		}
+	}
+	byStrategy := make(map[string][]*binance_connector.AccountTradeListResponse)
+	for _, trade := range trades {
+		if strategyId, ok := strategyByOrder[trade.OrderId]; ok {
+			byStrategy[strategyId] = append(byStrategy[strategyId], trade)
+		}
+	}
+	return byStrategy
+}
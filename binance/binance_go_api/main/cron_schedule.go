@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron 调度：分 时 日 月 周，支持 *、a-b、a,b、*/n、a-b/n，周日可写 0 或 7
+// 日和周都不是 * 时，任一满足即触发，与标准 cron 一致
+type CronSchedule struct {
+	expr     string
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	anyDay   bool // 日为 *
+	anyWeek  bool // 周为 *
+	location *time.Location
+}
+
+// location 为 nil 时使用 UTC
+func parseCronSchedule(expr string, location *time.Location) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields, got %d in %q", len(fields), expr)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	s := &CronSchedule{expr: expr, location: location}
+	var err error
+	if s.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if s.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if s.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if s.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if s.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	// 7 也是周日
+	if s.weekdays&(1<<7) != 0 {
+		s.weekdays |= 1
+	}
+	s.anyDay = fields[2] == "*"
+	s.anyWeek = fields[4] == "*"
+	return s, nil
+}
+
+// 每天 hour:minute
+func dailySchedule(hour, minute int, location *time.Location) (*CronSchedule, error) {
+	return parseCronSchedule(fmt.Sprintf("%d %d * * *", minute, hour), location)
+}
+
+// 每周 weekday 的 hour:minute
+func weeklySchedule(weekday time.Weekday, hour, minute int, location *time.Location) (*CronSchedule, error) {
+	return parseCronSchedule(fmt.Sprintf("%d %d * * %d", minute, hour, weekday), location)
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("cron: invalid step in %q", part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		low, high := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			low, err1 = strconv.Atoi(bounds[0])
+			high, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("cron: invalid range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("cron: invalid value %q", part)
+			}
+			low, high = n, n
+			// 5/10 表示从 5 开始每 10 个
+			if step > 1 {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("cron: %q out of range %d-%d", part, min, max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *CronSchedule) String() string {
+	return s.expr
+}
+
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	dayMatch := s.days&(1<<uint(t.Day())) != 0
+	weekMatch := s.weekdays&(1<<uint(t.Weekday())) != 0
+	if s.anyDay || s.anyWeek {
+		return dayMatch && weekMatch
+	}
+	return dayMatch || weekMatch
+}
+
+// after 之后的下一次触发时间，5 年内都不触发（例如 2 月 30 日）时返回零值
+func (s *CronSchedule) next(after time.Time) time.Time {
+	t := after.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+			continue
+		}
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
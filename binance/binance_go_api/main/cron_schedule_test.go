@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		if _, err := parseCronSchedule(expr, nil); err == nil {
+			t.Errorf("parseCronSchedule(%q) should fail", expr)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2026-10-19 是周一
+	from := time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"30 10 * * 1", from, time.Date(2026, 10, 26, 10, 30, 0, 0, time.UTC)},
+		{"30 10 * * 1", from.Add(-time.Second), from},
+		{"*/15 * * * *", from, time.Date(2026, 10, 19, 10, 45, 0, 0, time.UTC)},
+		{"0 0 * * *", from, time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", from, time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", from, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", from, time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", from, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 日和周都指定时任一满足即触发
+		{"0 12 25 * 3", from, time.Date(2026, 10, 21, 12, 0, 0, 0, time.UTC)},
+		{"0 0 31 12 *", time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC), time.Date(2027, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := parseCronSchedule(tt.expr, nil)
+		if err != nil {
+			t.Fatalf("parseCronSchedule(%q): %v", tt.expr, err)
+		}
+		if got := s.next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q next(%s) = %s, want %s", tt.expr, tt.from, got, tt.want)
+		}
+	}
+
+	never, err := parseCronSchedule("0 0 30 2 *", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := never.next(from); !got.IsZero() {
+		t.Errorf("February 30 next = %s, want zero", got)
+	}
+}
+
+func TestCronScheduleLocation(t *testing.T) {
+	shanghai := time.FixedZone("UTC+8", 8*3600)
+	s, err := dailySchedule(8, 0, shanghai)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// UTC 00:00 正好是 UTC+8 的 08:00
+	got := s.next(time.Date(2026, 10, 19, 0, 30, 0, 0, time.UTC))
+	if want := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("next = %s, want %s", got, want)
+	}
+}
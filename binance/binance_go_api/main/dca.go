@@ -0,0 +1,406 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 定投触发方式
+type DCATrigger string
+
+const (
+	DCATriggerSchedule  DCATrigger = "SCHEDULE"   // 按计划时间
+	DCATriggerPriceDrop DCATrigger = "PRICE_DROP" // 价格较上次买入下跌
+)
+
+// 定投计划
+type DCAPlan struct {
+	name        string // 计划名，状态文件按名字保存，必须唯一
+	symbol      string
+	schedule    *CronSchedule // parseCronSchedule / dailySchedule / weeklySchedule
+	quoteAmount Decimal       // 每次买入的计价币金额，以 quoteOrderQty 市价单下单
+	dropPercent *Decimal      // 价格比上次买入均价低这个比例时额外买一次，例如 0.05
+	dropAmount  *Decimal      // 下跌买入的金额，为空时使用 quoteAmount
+	budget      *Decimal      // 计划累计花费上限，最后一次按剩余额度买入
+
+	baseAsset  string // 由 addPlan 从 registry 填写
+	quoteAsset string
+}
+
+// 一次定投
+type DCAExecution struct {
+	Plan            string     `json:"plan"`
+	Symbol          string     `json:"symbol"`
+	BaseAsset       string     `json:"baseAsset"`
+	QuoteAsset      string     `json:"quoteAsset"`
+	Trigger         DCATrigger `json:"trigger"`
+	Time            time.Time  `json:"time"`
+	OrderId         int64      `json:"orderId,omitempty"`
+	ClientOrderId   string     `json:"clientOrderId,omitempty"`
+	QuoteQty        Decimal    `json:"quoteQty"`        // 实际花费
+	Quantity        Decimal    `json:"quantity"`        // 扣除基础币手续费后的到账数量
+	Price           Decimal    `json:"price"`           // 成交均价
+	Commission      Decimal    `json:"commission"`      // 非基础币的手续费，不计入成本
+	CommissionAsset string     `json:"commissionAsset"` // 为空表示没有或已从数量中扣除
+	Error           string     `json:"error,omitempty"`
+}
+
+// 计划的运行状态
+type DCAPlanState struct {
+	NextRun   time.Time `json:"nextRun"`
+	Spent     Decimal   `json:"spent"`
+	LastPrice Decimal   `json:"lastPrice"` // 上次买入均价，下跌触发的参考价
+}
+
+// 持久化的定投状态
+type DCAState struct {
+	Plans      map[string]*DCAPlanState `json:"plans"`
+	Executions []DCAExecution           `json:"executions"`
+	UpdatedAt  time.Time                `json:"updatedAt"`
+}
+
+// 按币种汇总的持仓成本
+type DCACostBasis struct {
+	Asset      string
+	QuoteAsset string
+	Buys       int
+	Quantity   Decimal
+	Cost       Decimal
+	AvgCost    Decimal
+}
+
+// 定投调度器：每 DCA_CHECK_INTERVAL 检查一次计划时间和下跌触发
+// 停机期间错过的多次计划时间只补买一次
+// 每笔订单都带 newClientOrderId，下单请求出错（例如超时）时按它补查，避免漏记已成交的订单
+type DCAScheduler struct {
+	api       TradingAPI
+	registry  *SymbolRegistry
+	ids       *ClientOrderIdGenerator
+	statePath string // 为空时不保存
+	proxyURL  string
+
+	mu     sync.Mutex
+	plans  []DCAPlan
+	state  DCAState
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+func newDCAScheduler(
+	api TradingAPI,
+	registry *SymbolRegistry,
+	ids *ClientOrderIdGenerator,
+	statePath string,
+	proxyURL string,
+) (*DCAScheduler, error) {
+	if registry == nil {
+		return nil, fmt.Errorf("dca: registry is required")
+	}
+	if ids == nil {
+		var err error
+		if ids, err = newClientOrderIdGenerator("dca", "0"); err != nil {
+			return nil, err
+		}
+	}
+	return &DCAScheduler{
+		api:       api,
+		registry:  registry,
+		ids:       ids,
+		statePath: statePath,
+		proxyURL:  proxyURL,
+		state:     DCAState{Plans: make(map[string]*DCAPlanState)},
+	}, nil
+}
+
+// 添加计划，需要在 start 之前调用
+func (s *DCAScheduler) addPlan(plan DCAPlan) error {
+	if plan.name == "" || plan.symbol == "" || plan.schedule == nil {
+		return fmt.Errorf("dca: name, symbol and schedule are required")
+	}
+	if plan.quoteAmount.Sign() <= 0 {
+		return fmt.Errorf("dca: quoteAmount must be positive")
+	}
+	if plan.dropPercent != nil && (plan.dropPercent.Sign() <= 0 || !plan.dropPercent.LessThan(newDecimalFromInt(1))) {
+		return fmt.Errorf("dca: dropPercent must be between 0 and 1, got %s", *plan.dropPercent)
+	}
+	if plan.budget != nil && plan.budget.Sign() <= 0 {
+		return fmt.Errorf("dca: budget must be positive")
+	}
+	symbolInfo, ok := s.registry.symbol(plan.symbol)
+	if !ok {
+		return fmt.Errorf("dca: unknown symbol %s", plan.symbol)
+	}
+	plan.baseAsset, plan.quoteAsset = symbolInfo.BaseAsset, symbolInfo.QuoteAsset
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopCh != nil {
+		return fmt.Errorf("dca: already started")
+	}
+	for _, p := range s.plans {
+		if p.name == plan.name {
+			return fmt.Errorf("dca: duplicate plan %s", plan.name)
+		}
+	}
+	s.plans = append(s.plans, plan)
+	return nil
+}
+
+// 加载状态文件并在后台开始调度
+func (s *DCAScheduler) start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopCh != nil {
+		return fmt.Errorf("dca: already started")
+	}
+	if s.statePath != "" {
+		if _, err := loadJSONFile(s.statePath, &s.state); err != nil {
+			return err
+		}
+		if s.state.Plans == nil {
+			s.state.Plans = make(map[string]*DCAPlanState)
+		}
+	}
+	now := time.Now()
+	for _, plan := range s.plans {
+		planState, ok := s.state.Plans[plan.name]
+		if !ok {
+			planState = &DCAPlanState{}
+			s.state.Plans[plan.name] = planState
+		}
+		if planState.NextRun.IsZero() {
+			planState.NextRun = plan.schedule.next(now)
+		}
+	}
+	if err := s.saveLocked(); err != nil {
+		return err
+	}
+	s.stopCh = make(chan struct{})
+	s.doneCh = make(chan struct{})
+	go s.run(s.stopCh, s.doneCh)
+	return nil
+}
+
+// 停止调度，正在下的单会等它完成
+func (s *DCAScheduler) stop() {
+	s.mu.Lock()
+	stopCh, doneCh := s.stopCh, s.doneCh
+	s.stopCh, s.doneCh = nil, nil
+	s.mu.Unlock()
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	<-doneCh
+}
+
+func (s *DCAScheduler) run(stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+	s.check(time.Now())
+	ticker := time.NewTicker(initConfig.DCA_CHECK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case now := <-ticker.C:
+			s.check(now)
+		}
+	}
+}
+
+// 检查所有计划，只在调度协程中调用
+func (s *DCAScheduler) check(now time.Time) {
+	s.mu.Lock()
+	plans := append([]DCAPlan(nil), s.plans...)
+	s.mu.Unlock()
+
+	for _, plan := range plans {
+		s.mu.Lock()
+		planState := *s.state.Plans[plan.name]
+		s.mu.Unlock()
+
+		if !planState.NextRun.IsZero() && !now.Before(planState.NextRun) {
+			s.execute(plan, DCATriggerSchedule, plan.quoteAmount)
+			s.mu.Lock()
+			s.state.Plans[plan.name].NextRun = plan.schedule.next(now)
+			s.saveLocked()
+			s.mu.Unlock()
+			continue
+		}
+
+		if plan.dropPercent == nil || planState.LastPrice.IsZero() {
+			continue
+		}
+		price, err := s.lastPrice(plan.symbol)
+		if err != nil {
+			fmt.Println("dca:", err)
+			continue
+		}
+		threshold := planState.LastPrice.Mul(newDecimalFromInt(1).Sub(*plan.dropPercent))
+		if price.GreaterThan(threshold) {
+			continue
+		}
+		amount := plan.quoteAmount
+		if plan.dropAmount != nil {
+			amount = *plan.dropAmount
+		}
+		s.execute(plan, DCATriggerPriceDrop, amount)
+	}
+}
+
+func (s *DCAScheduler) lastPrice(symbol string) (Decimal, error) {
+	ticker, err := getTickersPrice("", "", inputTokens{symbol: &symbol}, s.proxyURL)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return newDecimalFromString(ticker.Price)
+}
+
+// 以 quoteOrderQty 市价买入，结果记入状态文件；超出预算的部分不买
+func (s *DCAScheduler) execute(plan DCAPlan, trigger DCATrigger, amount Decimal) {
+	s.mu.Lock()
+	spent := s.state.Plans[plan.name].Spent
+	s.mu.Unlock()
+	if plan.budget != nil {
+		remaining := plan.budget.Sub(spent)
+		if remaining.Sign() <= 0 {
+			return
+		}
+		if remaining.LessThan(amount) {
+			amount = remaining
+		}
+	}
+
+	execution := DCAExecution{
+		Plan:       plan.name,
+		Symbol:     plan.symbol,
+		BaseAsset:  plan.baseAsset,
+		QuoteAsset: plan.quoteAsset,
+		Trigger:    trigger,
+		Time:       time.Now(),
+		QuoteQty:   amount,
+	}
+
+	order, err := s.placeOrder(plan.symbol, amount)
+	if err != nil {
+		execution.Error = err.Error()
+	} else {
+		execution.OrderId = order.OrderId
+		execution.ClientOrderId = order.ClientOrderId
+		execution.QuoteQty = order.CumulativeQuoteQty
+		execution.Quantity = order.ExecutedQty
+		execution.Price = order.AvgFillPrice()
+		for _, fill := range order.Fills {
+			if fill.CommissionAsset == execution.BaseAsset {
+				execution.Quantity = execution.Quantity.Sub(fill.Commission)
+				continue
+			}
+			if fill.Commission.IsZero() {
+				continue
+			}
+			execution.CommissionAsset = fill.CommissionAsset
+			execution.Commission = execution.Commission.Add(fill.Commission)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Executions = append(s.state.Executions, execution)
+	if execution.Error == "" {
+		planState := s.state.Plans[plan.name]
+		planState.Spent = planState.Spent.Add(execution.QuoteQty)
+		if !execution.Price.IsZero() {
+			planState.LastPrice = execution.Price
+		}
+	}
+	if err := s.saveLocked(); err != nil {
+		fmt.Println("dca:", err)
+	}
+}
+
+// 下单出错时按 clientOrderId 补查，订单有成交时按成交返回，避免超时后漏记已花费的金额
+func (s *DCAScheduler) placeOrder(symbol string, amount Decimal) (*CreateOrderResponse, error) {
+	respType := NewOrderRespFULL
+	no, err := s.registry.checkOrder(symbol, SideBuy, OrderTypeMarket, NewOrder{quoteOrderQty: &amount, newOrderRespType: &respType}, FilterCheck{})
+	if err != nil {
+		return nil, err
+	}
+	no = s.ids.assignNewOrder(no)
+	order, err := s.api.createNewOrder(symbol, SideBuy, OrderTypeMarket, no)
+	if err == nil {
+		return order, nil
+	}
+
+	queried, queryErr := s.api.getQueryOrder(symbol, QueryOrder{origClientOrderId: no.newClientOrderId})
+	if queryErr != nil {
+		return nil, fmt.Errorf("%w; query %s: %v", err, *no.newClientOrderId, queryErr)
+	}
+	order = &CreateOrderResponse{
+		Symbol:             queried.Symbol,
+		OrderId:            queried.OrderId,
+		ClientOrderId:      queried.ClientOrderId,
+		ExecutedQty:        decimalOrZero(queried.ExecutedQty),
+		CumulativeQuoteQty: decimalOrZero(queried.CumulativeQuoteQty),
+		Status:             queried.Status,
+	}
+	if order.ExecutedQty.Sign() <= 0 {
+		return nil, fmt.Errorf("%w; order %s %s without fills", err, *no.newClientOrderId, queried.Status)
+	}
+	// 查询结果没有成交明细，手续费无法扣除，按成交数量记录
+	fmt.Println("dca: recovered", *no.newClientOrderId, "after", err)
+	return order, nil
+}
+
+func (s *DCAScheduler) saveLocked() error {
+	if s.statePath == "" {
+		return nil
+	}
+	s.state.UpdatedAt = time.Now()
+	return saveJSONFile(s.statePath, s.state)
+}
+
+// 所有定投记录，包括失败的
+func (s *DCAScheduler) executions() []DCAExecution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DCAExecution(nil), s.state.Executions...)
+}
+
+// 按基础币和计价币汇总成功的定投，平均成本 = 花费 / 到账数量
+func (s *DCAScheduler) costBasis() []DCACostBasis {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byAsset := make(map[[2]string]*DCACostBasis)
+	for _, execution := range s.state.Executions {
+		if execution.Error != "" {
+			continue
+		}
+		key := [2]string{execution.BaseAsset, execution.QuoteAsset}
+		basis, ok := byAsset[key]
+		if !ok {
+			basis = &DCACostBasis{Asset: execution.BaseAsset, QuoteAsset: execution.QuoteAsset}
+			byAsset[key] = basis
+		}
+		basis.Buys++
+		basis.Quantity = basis.Quantity.Add(execution.Quantity)
+		basis.Cost = basis.Cost.Add(execution.QuoteQty)
+	}
+
+	summary := make([]DCACostBasis, 0, len(byAsset))
+	for _, basis := range byAsset {
+		if avgCost, err := basis.Cost.CheckedDiv(basis.Quantity); err == nil {
+			basis.AvgCost = avgCost
+		}
+		summary = append(summary, *basis)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Asset != summary[j].Asset {
+			return summary[i].Asset < summary[j].Asset
+		}
+		return summary[i].QuoteAsset < summary[j].QuoteAsset
+	})
+	return summary
+}
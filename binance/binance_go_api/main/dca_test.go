@@ -0,0 +1,83 @@
+package main
+
+import (
+	"binance_connector"
+	"fmt"
+	"testing"
+)
+
+// 下单请求超时，但订单实际已经成交
+type fakeTimeoutAPI struct {
+	TradingAPI
+	clientOrderId string
+	executedQty   string
+}
+
+func (f *fakeTimeoutAPI) createNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*CreateOrderResponse, error) {
+	f.clientOrderId = *no.newClientOrderId
+	return nil, fmt.Errorf("context deadline exceeded")
+}
+
+func (f *fakeTimeoutAPI) getQueryOrder(symbol string, qo QueryOrder) (*binance_connector.GetOrderResponse, error) {
+	if qo.origClientOrderId == nil || *qo.origClientOrderId != f.clientOrderId {
+		return nil, fmt.Errorf("order does not exist")
+	}
+	status := OrderStatusFilled
+	if f.executedQty == "0" {
+		status = OrderStatusExpired
+	}
+	return &binance_connector.GetOrderResponse{
+		Symbol: symbol, OrderId: 7, ClientOrderId: f.clientOrderId, Status: string(status),
+		ExecutedQty: f.executedQty, CumulativeQuoteQty: "100",
+	}, nil
+}
+
+func TestDCASchedulerRequiresRegistry(t *testing.T) {
+	if _, err := newDCAScheduler(&fakeTimeoutAPI{}, nil, nil, "", ""); err == nil {
+		t.Error("newDCAScheduler without registry should fail")
+	}
+}
+
+func TestDCASchedulerRecoversTimedOutOrder(t *testing.T) {
+	for _, tt := range []struct {
+		executedQty string
+		wantSpent   string
+		wantError   bool
+	}{
+		{"0.004", "100", false},
+		{"0", "0", true},
+	} {
+		api := &fakeTimeoutAPI{executedQty: tt.executedQty}
+		s, err := newDCAScheduler(api, testSymbolRegistry(testSymbolDetail()), nil, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		schedule, err := dailySchedule(0, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.addPlan(DCAPlan{name: "btc", symbol: "BTCUSDT", schedule: schedule, quoteAmount: mustDecimal("100")}); err != nil {
+			t.Fatal(err)
+		}
+		s.state.Plans["btc"] = &DCAPlanState{}
+
+		s.execute(s.plans[0], DCATriggerSchedule, mustDecimal("100"))
+		executions := s.executions()
+		if len(executions) != 1 {
+			t.Fatalf("executions = %+v, want 1", executions)
+		}
+		execution := executions[0]
+		if execution.BaseAsset != "BTC" || execution.QuoteAsset != "USDT" {
+			t.Errorf("assets = %s/%s, want BTC/USDT", execution.BaseAsset, execution.QuoteAsset)
+		}
+		if (execution.Error != "") != tt.wantError {
+			t.Errorf("executedQty %s: error = %q, wantError %v", tt.executedQty, execution.Error, tt.wantError)
+		}
+		if spent := s.state.Plans["btc"].Spent.String(); spent != tt.wantSpent {
+			t.Errorf("executedQty %s: spent = %s, want %s", tt.executedQty, spent, tt.wantSpent)
+		}
+		if !tt.wantError && (execution.OrderId != 7 || execution.Price.String() != "25000") {
+			t.Errorf("recovered execution = %+v, want order 7 at 25000", execution)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// 除法默认保留的小数位数
+const decimalDivPrecision = 18
+
+// 科学计数法指数的上限，Binance 的价格和数量远小于这个范围，超出时拒绝解析，避免 scale 溢出或生成巨大的整数
+const decimalMaxExponent = 1000
+
+var errDivisionByZero = errors.New("decimal: division by zero")
+
+var bigTen = big.NewInt(10)
+
+// 定点小数，值为 value * 10^-scale，用于价格和数量，避免 float64 产生 0.30000000000000004 这样的值
+// 零值表示 0，可以直接使用；所有运算都返回新值，不会修改原值
+type Decimal struct {
+	value *big.Int
+	scale int32
+}
+
+// 从字符串解析，支持 "0.1"、"-12.50"、"1e-8" 这样的写法
+func newDecimalFromString(s string) (Decimal, error) {
+	original := s
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Decimal{}, fmt.Errorf("decimal: empty string")
+	}
+
+	var exp int64
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("decimal: invalid exponent in %q", original)
+		}
+		if e > decimalMaxExponent || e < -decimalMaxExponent {
+			return Decimal{}, fmt.Errorf("decimal: exponent out of range in %q", original)
+		}
+		exp = e
+		s = s[:i]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	sign := ""
+	if strings.HasPrefix(intPart, "-") || strings.HasPrefix(intPart, "+") {
+		sign, intPart = intPart[:1], intPart[1:]
+	}
+	digits := intPart + fracPart
+	if digits == "" || strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return Decimal{}, fmt.Errorf("decimal: invalid number %q", original)
+	}
+
+	value, ok := new(big.Int).SetString(sign+digits, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("decimal: invalid number %q", original)
+	}
+	scale := int64(len(fracPart)) - exp
+	if scale > math.MaxInt32 {
+		return Decimal{}, fmt.Errorf("decimal: too many digits in %q", original)
+	}
+	if scale < 0 {
+		value.Mul(value, pow10(int32(-scale)))
+		scale = 0
+	}
+	return Decimal{value: value, scale: int32(scale)}, nil
+}
+
+// 解析失败直接 panic，只用于常量
+func mustDecimal(s string) Decimal {
+	d, err := newDecimalFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// 解析 Binance 返回的字符串，空字符串或格式错误时返回 0
+func decimalOrZero(s string) Decimal {
+	d, err := newDecimalFromString(s)
+	if err != nil {
+		return Decimal{}
+	}
+	return d
+}
+
+// 从 float64 转换，使用能还原该 float64 的最短十进制表示；只用于常量等已知有限的值
+func newDecimalFromFloat(f float64) Decimal {
+	d, err := decimalFromFloat(f)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// 从 float64 转换，NaN 和 ±Inf 返回错误，用于外部输入
+func decimalFromFloat(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, fmt.Errorf("decimal: non-finite number %v", f)
+	}
+	return newDecimalFromString(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func newDecimalFromInt(i int64) Decimal {
+	return Decimal{value: big.NewInt(i)}
+}
+
+// 兼容仍在使用 float64 的调用方：nil 保持为 nil
+func decimalPtrFromFloat(f *float64) *Decimal {
+	if f == nil {
+		return nil
+	}
+	d := newDecimalFromFloat(*f)
+	return &d
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) bigInt() *big.Int {
+	if d.value == nil {
+		return new(big.Int)
+	}
+	return d.value
+}
+
+// 按给定小数位数返回放大后的整数，scale 必须不小于 d.scale
+func (d Decimal) rescaled(scale int32) *big.Int {
+	value := new(big.Int).Set(d.bigInt())
+	if scale > d.scale {
+		value.Mul(value, pow10(scale-d.scale))
+	}
+	return value
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	scale := max(d.scale, other.scale)
+	return Decimal{value: new(big.Int).Add(d.rescaled(scale), other.rescaled(scale)), scale: scale}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	scale := max(d.scale, other.scale)
+	return Decimal{value: new(big.Int).Sub(d.rescaled(scale), other.rescaled(scale)), scale: scale}
+}
+
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{value: new(big.Int).Mul(d.bigInt(), other.bigInt()), scale: d.scale + other.scale}
+}
+
+// 除法，结果保留 decimalDivPrecision 位小数并向零截断
+// 除数为 0 时 panic，只用于除数已确认不为 0 的地方；除数来自交易所数据时使用 CheckedDiv
+func (d Decimal) Div(other Decimal) Decimal {
+	return d.DivPrec(other, decimalDivPrecision)
+}
+
+// 除数为 0 时返回 errDivisionByZero
+func (d Decimal) CheckedDiv(other Decimal) (Decimal, error) {
+	return d.CheckedDivPrec(other, decimalDivPrecision)
+}
+
+func (d Decimal) CheckedDivPrec(other Decimal, precision int32) (Decimal, error) {
+	if other.IsZero() {
+		return Decimal{}, errDivisionByZero
+	}
+	return d.DivPrec(other, precision), nil
+}
+
+// 除法，结果保留 precision 位小数并向零截断，除数为 0 时 panic
+func (d Decimal) DivPrec(other Decimal, precision int32) Decimal {
+	if other.IsZero() {
+		panic(errDivisionByZero)
+	}
+	// d.value*10^-d.scale / (o.value*10^-o.scale) = d.value*10^(precision-d.scale+o.scale) / o.value * 10^-precision
+	numerator := new(big.Int).Set(d.bigInt())
+	denominator := new(big.Int).Set(other.bigInt())
+	shift := precision - d.scale + other.scale
+	if shift >= 0 {
+		numerator.Mul(numerator, pow10(shift))
+	} else {
+		denominator.Mul(denominator, pow10(-shift))
+	}
+	return Decimal{value: numerator.Quo(numerator, denominator), scale: precision}
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{value: new(big.Int).Neg(d.bigInt()), scale: d.scale}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{value: new(big.Int).Abs(d.bigInt()), scale: d.scale}
+}
+
+// 比较大小，d < other 返回 -1，相等返回 0，d > other 返回 1
+func (d Decimal) Cmp(other Decimal) int {
+	scale := max(d.scale, other.scale)
+	return d.rescaled(scale).Cmp(other.rescaled(scale))
+}
+
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+func (d Decimal) LessThan(other Decimal) bool {
+	return d.Cmp(other) < 0
+}
+
+func (d Decimal) GreaterThan(other Decimal) bool {
+	return d.Cmp(other) > 0
+}
+
+func (d Decimal) Sign() int {
+	return d.bigInt().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// 保留 places 位小数，多余部分向零截断
+func (d Decimal) Truncate(places int32) Decimal {
+	if places >= d.scale {
+		return d
+	}
+	value := new(big.Int).Quo(d.bigInt(), pow10(d.scale-places))
+	return Decimal{value: value, scale: places}
+}
+
+// 保留 places 位小数，四舍五入（远离零）
+func (d Decimal) Round(places int32) Decimal {
+	if places >= d.scale {
+		return d
+	}
+	divisor := pow10(d.scale - places)
+	value, remainder := new(big.Int).QuoRem(d.bigInt(), divisor, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(divisor) >= 0 {
+		if d.Sign() < 0 {
+			value.Sub(value, big.NewInt(1))
+		} else {
+			value.Add(value, big.NewInt(1))
+		}
+	}
+	return Decimal{value: value, scale: places}
+}
+
+// 按 step 向下取整到 step 的整数倍，用于把数量对齐到 stepSize；step 不大于 0 时原样返回
+func (d Decimal) RoundDownToStep(step Decimal) Decimal {
+	if step.Sign() <= 0 {
+		return d
+	}
+	steps := d.DivPrec(step, 0)
+	if d.Sign() < 0 && !steps.Mul(step).Equal(d) {
+		steps = steps.Sub(newDecimalFromInt(1))
+	}
+	return steps.Mul(step)
+}
+
+// 按 step 向上取整到 step 的整数倍；step 不大于 0 时原样返回
+func (d Decimal) RoundUpToStep(step Decimal) Decimal {
+	rounded := d.RoundDownToStep(step)
+	if rounded.LessThan(d) {
+		rounded = rounded.Add(step)
+	}
+	return rounded
+}
+
+// 是否为 step 的整数倍，step 不大于 0 时总是返回 true
+func (d Decimal) IsMultipleOf(step Decimal) bool {
+	if step.Sign() <= 0 {
+		return true
+	}
+	return d.RoundDownToStep(step).Equal(d)
+}
+
+// 转成 float64，可能损失精度，只用于统计和展示
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// 精确的十进制字符串，去掉末尾多余的 0，不使用科学计数法
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.bigInt()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale <= 0 {
+		return sign + digits
+	}
+	if len(digits) <= int(d.scale) {
+		digits = strings.Repeat("0", int(d.scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(d.scale)
+	intPart, fracPart := digits[:point], strings.TrimRight(digits[point:], "0")
+	if fracPart == "" {
+		if intPart == "0" {
+			return "0"
+		}
+		return sign + intPart
+	}
+	return sign + intPart + "." + fracPart
+}
+
+// 序列化成带引号的字符串，与 Binance 返回的格式一致
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// 同时兼容 "0.1" 和 0.1 两种写法，空字符串和 null 视为 0
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*d = Decimal{}
+		return nil
+	}
+	parsed, err := newDecimalFromString(string(data))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
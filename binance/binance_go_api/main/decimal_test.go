@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNewDecimalFromString(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"0", "0", false},
+		{"0.10", "0.1", false},
+		{"-12.50", "-12.5", false},
+		{"+3", "3", false},
+		{" 7.25 ", "7.25", false},
+		{".5", "0.5", false},
+		{"5.", "5", false},
+		{"1e3", "1000", false},
+		{"1.5e-3", "0.0015", false},
+		{"-2E2", "-200", false},
+		{"0.000", "0", false},
+		{"-0", "0", false},
+		{"123456789012345678901234567890.000000000000000001", "123456789012345678901234567890.000000000000000001", false},
+		{"", "", true},
+		{"-", "", true},
+		{"abc", "", true},
+		{"1.2.3", "", true},
+		{"1e", "", true},
+		{"1e-2147483647", "", true},
+		{"1e2147483647", "", true},
+		{"1e1001", "", true},
+	}
+	for _, tt := range tests {
+		got, err := newDecimalFromString(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("newDecimalFromString(%q) = %s, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("newDecimalFromString(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("newDecimalFromString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{"add", mustDecimal("0.1").Add(mustDecimal("0.2")), "0.3"},
+		{"sub", mustDecimal("1").Sub(mustDecimal("1.0001")), "-0.0001"},
+		{"mul", mustDecimal("1.5").Mul(mustDecimal("-0.2")), "-0.3"},
+		{"div", mustDecimal("1").Div(mustDecimal("3")), "0.333333333333333333"},
+		{"div negative truncates toward zero", mustDecimal("-2").DivPrec(mustDecimal("3"), 2), "-0.66"},
+		{"zero value", Decimal{}.Add(mustDecimal("1")), "1"},
+		{"float", newDecimalFromFloat(0.1), "0.1"},
+		{"int", newDecimalFromInt(-42), "-42"},
+	}
+	for _, tt := range tests {
+		if tt.got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestDecimalCheckedDiv(t *testing.T) {
+	if _, err := mustDecimal("1").CheckedDiv(Decimal{}); !errors.Is(err, errDivisionByZero) {
+		t.Errorf("CheckedDiv by zero error = %v, want errDivisionByZero", err)
+	}
+	got, err := mustDecimal("10").CheckedDiv(mustDecimal("4"))
+	if err != nil || got.String() != "2.5" {
+		t.Errorf("CheckedDiv(10, 4) = %s, %v, want 2.5", got, err)
+	}
+}
+
+func TestDecimalFromFloat(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := decimalFromFloat(f); err == nil {
+			t.Errorf("decimalFromFloat(%v) should fail", f)
+		}
+	}
+	got, err := decimalFromFloat(0.1)
+	if err != nil || got.String() != "0.1" {
+		t.Errorf("decimalFromFloat(0.1) = %s, %v, want 0.1", got, err)
+	}
+}
+
+func TestDecimalRounding(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{"round half up", mustDecimal("2.345").Round(2), "2.35"},
+		{"round half away from zero", mustDecimal("-1.25").Round(1), "-1.3"},
+		{"round down", mustDecimal("2.344").Round(2), "2.34"},
+		{"round no-op", mustDecimal("2.3").Round(5), "2.3"},
+		{"truncate", mustDecimal("-2.349").Truncate(2), "-2.34"},
+		{"down to step", mustDecimal("0.123456").RoundDownToStep(mustDecimal("0.001")), "0.123"},
+		{"down to step exact", mustDecimal("0.15").RoundDownToStep(mustDecimal("0.05")), "0.15"},
+		{"down to step negative", mustDecimal("-0.15").RoundDownToStep(mustDecimal("0.1")), "-0.2"},
+		{"down to step negative exact", mustDecimal("-0.2").RoundDownToStep(mustDecimal("0.1")), "-0.2"},
+		{"down to step zero step", mustDecimal("1.234").RoundDownToStep(Decimal{}), "1.234"},
+		{"up to step", mustDecimal("0.1201").RoundUpToStep(mustDecimal("0.01")), "0.13"},
+		{"up to step negative", mustDecimal("-0.15").RoundUpToStep(mustDecimal("0.1")), "-0.1"},
+		{"up to step exact", mustDecimal("0.12").RoundUpToStep(mustDecimal("0.01")), "0.12"},
+	}
+	for _, tt := range tests {
+		if tt.got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if !mustDecimal("0.3").IsMultipleOf(mustDecimal("0.1")) {
+		t.Error("0.3 should be a multiple of 0.1")
+	}
+	if mustDecimal("0.35").IsMultipleOf(mustDecimal("0.1")) {
+		t.Error("0.35 should not be a multiple of 0.1")
+	}
+}
+
+func TestDecimalCompare(t *testing.T) {
+	a, b := mustDecimal("1.10"), mustDecimal("1.1")
+	if !a.Equal(b) || a.Cmp(b) != 0 {
+		t.Errorf("%s should equal %s", a, b)
+	}
+	if !mustDecimal("-1").LessThan(Decimal{}) || !mustDecimal("0.0001").GreaterThan(Decimal{}) {
+		t.Error("sign comparison against zero value failed")
+	}
+	if !(Decimal{}).IsZero() || mustDecimal("-0.5").Sign() != -1 {
+		t.Error("IsZero/Sign failed")
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	for _, in := range []string{"0", "0.1", "-12.5", "123456789.123456789", "0.00000001"} {
+		d := mustDecimal(in)
+		data, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != `"`+in+`"` {
+			t.Errorf("Marshal(%s) = %s", in, data)
+		}
+		var back Decimal
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !back.Equal(d) || back.String() != in {
+			t.Errorf("round trip %s = %s", in, back)
+		}
+	}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`"0.1"`, "0.1"},
+		{`0.1`, "0.1"},
+		{`""`, "0"},
+		{`null`, "0"},
+		{`"1e-8"`, "0.00000001"},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		if err := json.Unmarshal([]byte(tt.in), &d); err != nil {
+			t.Errorf("Unmarshal(%s) error: %v", tt.in, err)
+			continue
+		}
+		if d.String() != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, d, tt.want)
+		}
+	}
+	var d Decimal
+	if err := json.Unmarshal([]byte(`"abc"`), &d); err == nil {
+		t.Error("Unmarshal of invalid number should fail")
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import "fmt"
+
+// 买卖方向
+type OrderSide string
+
+const (
+	SideBuy  OrderSide = "BUY"
+	SideSell OrderSide = "SELL"
+)
+
+// 订单类型
+type OrderType string
+
+const (
+	OrderTypeLimit           OrderType = "LIMIT"
+	OrderTypeMarket          OrderType = "MARKET"
+	OrderTypeStopLoss        OrderType = "STOP_LOSS"
+	OrderTypeStopLossLimit   OrderType = "STOP_LOSS_LIMIT"
+	OrderTypeTakeProfit      OrderType = "TAKE_PROFIT"
+	OrderTypeTakeProfitLimit OrderType = "TAKE_PROFIT_LIMIT"
+	OrderTypeLimitMaker      OrderType = "LIMIT_MAKER"
+)
+
+// 订单有效方式
+type TimeInForce string
+
+const (
+	TimeInForceGTC TimeInForce = "GTC"
+	TimeInForceIOC TimeInForce = "IOC"
+	TimeInForceFOK TimeInForce = "FOK"
+)
+
+// 下单返回的详细程度
+type NewOrderRespType string
+
+const (
+	NewOrderRespACK    NewOrderRespType = "ACK"
+	NewOrderRespRESULT NewOrderRespType = "RESULT"
+	NewOrderRespFULL   NewOrderRespType = "FULL"
+)
+
+// 防自成交模式
+type STPMode string
+
+const (
+	STPModeNone        STPMode = "NONE"
+	STPModeExpireMaker STPMode = "EXPIRE_MAKER"
+	STPModeExpireTaker STPMode = "EXPIRE_TAKER"
+	STPModeExpireBoth  STPMode = "EXPIRE_BOTH"
+	STPModeDecrement   STPMode = "DECREMENT"
+)
+
+// cancelReplace 撤单失败时的处理方式
+type CancelReplaceMode string
+
+const (
+	CancelReplaceStopOnFailure CancelReplaceMode = "STOP_ON_FAILURE"
+	CancelReplaceAllowFailure  CancelReplaceMode = "ALLOW_FAILURE"
+)
+
+func (s OrderSide) isValid() bool {
+	return s == SideBuy || s == SideSell
+}
+
+// 反方向，用于平仓
+func (s OrderSide) opposite() OrderSide {
+	if s == SideBuy {
+		return SideSell
+	}
+	return SideBuy
+}
+
+func (t OrderType) isValid() bool {
+	switch t {
+	case OrderTypeLimit, OrderTypeMarket, OrderTypeStopLoss, OrderTypeStopLossLimit,
+		OrderTypeTakeProfit, OrderTypeTakeProfitLimit, OrderTypeLimitMaker:
+		return true
+	}
+	return false
+}
+
+func (t TimeInForce) isValid() bool {
+	return t == TimeInForceGTC || t == TimeInForceIOC || t == TimeInForceFOK
+}
+
+func (t NewOrderRespType) isValid() bool {
+	return t == NewOrderRespACK || t == NewOrderRespRESULT || t == NewOrderRespFULL
+}
+
+func (m STPMode) isValid() bool {
+	switch m {
+	case STPModeNone, STPModeExpireMaker, STPModeExpireTaker, STPModeExpireBoth, STPModeDecrement:
+		return true
+	}
+	return false
+}
+
+func (m CancelReplaceMode) isValid() bool {
+	return m == CancelReplaceStopOnFailure || m == CancelReplaceAllowFailure
+}
+
+// 下单前检查各订单类型必填和不允许的参数
+//
+//	LIMIT             timeInForce, quantity, price
+//	MARKET            quantity 或 quoteOrderQty 二选一
+//	STOP_LOSS         quantity, stopPrice 或 trailingDelta
+//	STOP_LOSS_LIMIT   timeInForce, quantity, price, stopPrice 或 trailingDelta
+//	TAKE_PROFIT       quantity, stopPrice 或 trailingDelta
+//	TAKE_PROFIT_LIMIT timeInForce, quantity, price, stopPrice 或 trailingDelta
+//	LIMIT_MAKER       quantity, price
+func validateNewOrder(side OrderSide, orderType OrderType, no NewOrder) error {
+	if !side.isValid() {
+		return fmt.Errorf("order: invalid side %q", side)
+	}
+	if !orderType.isValid() {
+		return fmt.Errorf("order: invalid type %q", orderType)
+	}
+	if no.timeInForce != nil && !no.timeInForce.isValid() {
+		return fmt.Errorf("order: invalid timeInForce %q", *no.timeInForce)
+	}
+	if no.newOrderRespType != nil && !no.newOrderRespType.isValid() {
+		return fmt.Errorf("order: invalid newOrderRespType %q", *no.newOrderRespType)
+	}
+	if no.selfTradePreventionMode != nil && !no.selfTradePreventionMode.isValid() {
+		return fmt.Errorf("order: invalid selfTradePreventionMode %q", *no.selfTradePreventionMode)
+	}
+
+	var missing []string
+	require := func(ok bool, name string) {
+		if !ok {
+			missing = append(missing, name)
+		}
+	}
+	hasStop := no.stopPrice != nil || no.trailingDelta != nil
+
+	switch orderType {
+	case OrderTypeLimit:
+		require(no.timeInForce != nil, "timeInForce")
+		require(no.quantity != nil, "quantity")
+		require(no.price != nil, "price")
+	case OrderTypeMarket:
+		if (no.quantity == nil) == (no.quoteOrderQty == nil) {
+			return fmt.Errorf("order: MARKET requires exactly one of quantity and quoteOrderQty")
+		}
+	case OrderTypeStopLoss, OrderTypeTakeProfit:
+		require(no.quantity != nil, "quantity")
+		require(hasStop, "stopPrice or trailingDelta")
+	case OrderTypeStopLossLimit, OrderTypeTakeProfitLimit:
+		require(no.timeInForce != nil, "timeInForce")
+		require(no.quantity != nil, "quantity")
+		require(no.price != nil, "price")
+		require(hasStop, "stopPrice or trailingDelta")
+	case OrderTypeLimitMaker:
+		require(no.quantity != nil, "quantity")
+		require(no.price != nil, "price")
+		if no.timeInForce != nil {
+			return fmt.Errorf("order: LIMIT_MAKER does not accept timeInForce")
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("order: %s requires %v", orderType, missing)
+	}
+
+	if no.quoteOrderQty != nil && orderType != OrderTypeMarket {
+		return fmt.Errorf("order: quoteOrderQty is only allowed for MARKET orders")
+	}
+	if no.price != nil && (orderType == OrderTypeMarket || orderType == OrderTypeStopLoss || orderType == OrderTypeTakeProfit) {
+		return fmt.Errorf("order: %s does not accept price", orderType)
+	}
+	if hasStop && (orderType == OrderTypeLimit || orderType == OrderTypeMarket || orderType == OrderTypeLimitMaker) {
+		return fmt.Errorf("order: %s does not accept stopPrice or trailingDelta", orderType)
+	}
+	if no.icebergQty != nil {
+		switch orderType {
+		case OrderTypeLimit, OrderTypeLimitMaker, OrderTypeStopLossLimit, OrderTypeTakeProfitLimit:
+		default:
+			return fmt.Errorf("order: %s does not accept icebergQty", orderType)
+		}
+		if no.timeInForce != nil && *no.timeInForce != TimeInForceGTC {
+			return fmt.Errorf("order: icebergQty requires timeInForce GTC")
+		}
+	}
+	return nil
+}
+
+// cancelReplace 除了新订单本身的检查，还需要指定要撤销的订单
+func validateCancelReplace(side OrderSide, orderType OrderType, cancelReplaceMode CancelReplaceMode, cr CancelReplace) error {
+	if !cancelReplaceMode.isValid() {
+		return fmt.Errorf("order: invalid cancelReplaceMode %q", cancelReplaceMode)
+	}
+	if cr.cancelOrderId == nil && cr.cancelOrigClientOrderId == nil {
+		return fmt.Errorf("order: cancelReplace requires cancelOrderId or cancelOrigClientOrderId")
+	}
+	return validateNewOrder(side, orderType, cancelReplaceNewOrder(cr))
+}
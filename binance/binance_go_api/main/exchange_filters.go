@@ -0,0 +1,369 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"binance_connector"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 交易对过滤器类型
+const (
+	FilterPriceFilter        = "PRICE_FILTER"
+	FilterLotSize            = "LOT_SIZE"
+	FilterMarketLotSize      = "MARKET_LOT_SIZE"
+	FilterMinNotional        = "MIN_NOTIONAL"
+	FilterNotional           = "NOTIONAL"
+	FilterPercentPriceBySide = "PERCENT_PRICE_BY_SIDE"
+	FilterIcebergParts       = "ICEBERG_PARTS"
+	FilterMaxNumOrders       = "MAX_NUM_ORDERS"
+	FilterMaxNumAlgoOrders   = "MAX_NUM_ALGO_ORDERS"
+	FilterTrailingDelta      = "TRAILING_DELTA"
+)
+
+// 完整的 exchangeInfo，connector 中的结构缺少大部分过滤器字段
+type ExchangeInfoDetail struct {
+	Timezone        string                         `json:"timezone"`
+	ServerTime      uint64                         `json:"serverTime"`
+	RateLimits      []*binance_connector.RateLimit `json:"rateLimits"`
+	ExchangeFilters []SymbolFilter                 `json:"exchangeFilters"`
+	Symbols         []*SymbolDetail                `json:"symbols"`
+	Sors            []SORInfo                      `json:"sors,omitempty"`
+}
+
+// 单个交易对的规则
+type SymbolDetail struct {
+	Symbol                          string         `json:"symbol"`
+	Status                          string         `json:"status"`
+	BaseAsset                       string         `json:"baseAsset"`
+	BaseAssetPrecision              int            `json:"baseAssetPrecision"`
+	QuoteAsset                      string         `json:"quoteAsset"`
+	QuoteAssetPrecision             int            `json:"quoteAssetPrecision"`
+	BaseCommissionPrecision         int            `json:"baseCommissionPrecision"`
+	QuoteCommissionPrecision        int            `json:"quoteCommissionPrecision"`
+	OrderTypes                      []string       `json:"orderTypes"`
+	IcebergAllowed                  bool           `json:"icebergAllowed"`
+	OcoAllowed                      bool           `json:"ocoAllowed"`
+	OtoAllowed                      bool           `json:"otoAllowed"`
+	QuoteOrderQtyMarketAllowed      bool           `json:"quoteOrderQtyMarketAllowed"`
+	AllowTrailingStop               bool           `json:"allowTrailingStop"`
+	CancelReplaceAllowed            bool           `json:"cancelReplaceAllowed"`
+	AmendAllowed                    bool           `json:"amendAllowed"`
+	IsSpotTradingAllowed            bool           `json:"isSpotTradingAllowed"`
+	IsMarginTradingAllowed          bool           `json:"isMarginTradingAllowed"`
+	Filters                         []SymbolFilter `json:"filters"`
+	Permissions                     []string       `json:"permissions"`
+	PermissionSets                  [][]string     `json:"permissionSets"`
+	DefaultSelfTradePreventionMode  STPMode        `json:"defaultSelfTradePreventionMode"`
+	AllowedSelfTradePreventionModes []STPMode      `json:"allowedSelfTradePreventionModes"`
+}
+
+// 过滤器，不同 filterType 只会用到其中一部分字段
+type SymbolFilter struct {
+	FilterType string `json:"filterType"`
+
+	// PRICE_FILTER
+	MinPrice Decimal `json:"minPrice"`
+	MaxPrice Decimal `json:"maxPrice"`
+	TickSize Decimal `json:"tickSize"`
+
+	// LOT_SIZE / MARKET_LOT_SIZE
+	MinQty   Decimal `json:"minQty"`
+	MaxQty   Decimal `json:"maxQty"`
+	StepSize Decimal `json:"stepSize"`
+
+	// MIN_NOTIONAL / NOTIONAL
+	MinNotional      Decimal `json:"minNotional"`
+	MaxNotional      Decimal `json:"maxNotional"`
+	ApplyToMarket    bool    `json:"applyToMarket"`
+	ApplyMinToMarket bool    `json:"applyMinToMarket"`
+	ApplyMaxToMarket bool    `json:"applyMaxToMarket"`
+	AvgPriceMins     int     `json:"avgPriceMins"`
+
+	// PERCENT_PRICE_BY_SIDE
+	BidMultiplierUp   Decimal `json:"bidMultiplierUp"`
+	BidMultiplierDown Decimal `json:"bidMultiplierDown"`
+	AskMultiplierUp   Decimal `json:"askMultiplierUp"`
+	AskMultiplierDown Decimal `json:"askMultiplierDown"`
+
+	// ICEBERG_PARTS
+	Limit int `json:"limit"`
+
+	// MAX_NUM_ORDERS / MAX_NUM_ALGO_ORDERS
+	MaxNumOrders     int `json:"maxNumOrders"`
+	MaxNumAlgoOrders int `json:"maxNumAlgoOrders"`
+
+	// TRAILING_DELTA
+	MinTrailingAboveDelta int `json:"minTrailingAboveDelta"`
+	MaxTrailingAboveDelta int `json:"maxTrailingAboveDelta"`
+	MinTrailingBelowDelta int `json:"minTrailingBelowDelta"`
+	MaxTrailingBelowDelta int `json:"maxTrailingBelowDelta"`
+}
+
+// 违反交易对过滤器
+type FilterError struct {
+	Symbol  string
+	Filter  string
+	Message string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter %s on %s: %s", e.Filter, e.Symbol, e.Message)
+}
+
+// 过滤器检查的附加信息和取整选项
+type FilterCheck struct {
+	roundPrice    bool     // price/stopPrice 按 tickSize 取整：买单向下，卖单向上
+	roundQuantity bool     // quantity/icebergQty 按 stepSize 向下取整
+	avgPrice      *Decimal // 当前均价，PERCENT_PRICE_BY_SIDE 和市价单的名义价值检查需要，为空时跳过
+	openOrders    *int     // 该交易对当前挂单数，为空时跳过 MAX_NUM_ORDERS
+	algoOrders    *int     // 该交易对当前条件单数，为空时跳过 MAX_NUM_ALGO_ORDERS
+}
+
+// 获取完整的 exchangeInfo，可以按 symbol、symbols 或 permissions 过滤
+func getExchangeInfoDetail(ei ExchangeInfo, proxyURL string) (*ExchangeInfoDetail, error) {
+	params := map[string]string{}
+	if ei.symbol != "" {
+		params["symbol"] = ei.symbol
+	}
+	if len(ei.symbols) > 0 {
+		symbols, _ := json.Marshal(ei.symbols)
+		params["symbols"] = string(symbols)
+	}
+	if ei.permissions != "" {
+		params["permissions"] = ei.permissions
+	}
+	exchangeInfo := new(ExchangeInfoDetail)
+	if err := publicRequestJSON(initConfig.PATH_EXCHANGE_INFO, params, proxyURL, exchangeInfo); err != nil {
+		return nil, err
+	}
+	return exchangeInfo, nil
+}
+
+// 按类型查找过滤器，不存在时返回 nil
+func (s *SymbolDetail) filter(filterType string) *SymbolFilter {
+	for i := range s.Filters {
+		if s.Filters[i].FilterType == filterType {
+			return &s.Filters[i]
+		}
+	}
+	return nil
+}
+
+// 是否支持该订单类型
+func (s *SymbolDetail) allowsOrderType(orderType OrderType) bool {
+	for _, allowed := range s.OrderTypes {
+		if allowed == string(orderType) {
+			return true
+		}
+	}
+	return false
+}
+
+// 下单前按交易对过滤器检查订单，按需对价格和数量取整
+// 返回取整后的 NewOrder，原订单不会被修改
+func applyExchangeFilters(
+	symbolInfo *SymbolDetail,
+	side OrderSide,
+	orderType OrderType,
+	no NewOrder,
+	fc FilterCheck,
+) (NewOrder, error) {
+	fail := func(filter, format string, args ...interface{}) (NewOrder, error) {
+		return no, &FilterError{Symbol: symbolInfo.Symbol, Filter: filter, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if symbolInfo.Status != "TRADING" {
+		return fail("STATUS", "symbol status is %s", symbolInfo.Status)
+	}
+	if !symbolInfo.allowsOrderType(orderType) {
+		return fail("ORDER_TYPES", "order type %s not allowed, allowed: %s", orderType, strings.Join(symbolInfo.OrderTypes, ","))
+	}
+	if no.selfTradePreventionMode != nil && !symbolInfo.allowsSTPMode(*no.selfTradePreventionMode) {
+		return fail("STP_MODES", "selfTradePreventionMode %s not allowed, allowed: %s", *no.selfTradePreventionMode, symbolInfo.allowedSTPModes())
+	}
+	if no.icebergQty != nil && !symbolInfo.IcebergAllowed {
+		return fail(FilterIcebergParts, "iceberg orders not allowed")
+	}
+	if orderType == OrderTypeMarket && no.quoteOrderQty != nil && !symbolInfo.QuoteOrderQtyMarketAllowed {
+		return fail(FilterNotional, "quoteOrderQty not allowed for MARKET orders")
+	}
+
+	// PRICE_FILTER
+	if f := symbolInfo.filter(FilterPriceFilter); f != nil {
+		for _, field := range []struct {
+			name  string
+			value **Decimal
+		}{{"price", &no.price}, {"stopPrice", &no.stopPrice}} {
+			if *field.value == nil {
+				continue
+			}
+			price := **field.value
+			if fc.roundPrice && f.TickSize.Sign() > 0 {
+				if side == SideSell {
+					price = price.Sub(f.MinPrice).RoundUpToStep(f.TickSize).Add(f.MinPrice)
+				} else {
+					price = price.Sub(f.MinPrice).RoundDownToStep(f.TickSize).Add(f.MinPrice)
+				}
+				*field.value = &price
+			}
+			if f.MinPrice.Sign() > 0 && price.LessThan(f.MinPrice) {
+				return fail(FilterPriceFilter, "%s %s below minPrice %s", field.name, price, f.MinPrice)
+			}
+			if f.MaxPrice.Sign() > 0 && price.GreaterThan(f.MaxPrice) {
+				return fail(FilterPriceFilter, "%s %s above maxPrice %s", field.name, price, f.MaxPrice)
+			}
+			if !price.Sub(f.MinPrice).IsMultipleOf(f.TickSize) {
+				return fail(FilterPriceFilter, "%s %s is not a multiple of tickSize %s", field.name, price, f.TickSize)
+			}
+		}
+	}
+
+	// LOT_SIZE，市价单还要检查 MARKET_LOT_SIZE
+	lotFilters := []string{FilterLotSize}
+	if orderType == OrderTypeMarket {
+		lotFilters = append(lotFilters, FilterMarketLotSize)
+	}
+	for _, filterType := range lotFilters {
+		f := symbolInfo.filter(filterType)
+		if f == nil {
+			continue
+		}
+		for _, field := range []struct {
+			name  string
+			value **Decimal
+		}{{"quantity", &no.quantity}, {"icebergQty", &no.icebergQty}} {
+			if *field.value == nil {
+				continue
+			}
+			qty := **field.value
+			if fc.roundQuantity && f.StepSize.Sign() > 0 {
+				qty = qty.Sub(f.MinQty).RoundDownToStep(f.StepSize).Add(f.MinQty)
+				*field.value = &qty
+			}
+			if f.MinQty.Sign() > 0 && qty.LessThan(f.MinQty) {
+				return fail(filterType, "%s %s below minQty %s", field.name, qty, f.MinQty)
+			}
+			if f.MaxQty.Sign() > 0 && qty.GreaterThan(f.MaxQty) {
+				return fail(filterType, "%s %s above maxQty %s", field.name, qty, f.MaxQty)
+			}
+			if !qty.Sub(f.MinQty).IsMultipleOf(f.StepSize) {
+				return fail(filterType, "%s %s is not a multiple of stepSize %s", field.name, qty, f.StepSize)
+			}
+		}
+	}
+
+	// 名义价值：限价单用 price，市价单用 quoteOrderQty 或 avgPrice 估算
+	var notional *Decimal
+	switch {
+	case no.price != nil && no.quantity != nil:
+		value := no.price.Mul(*no.quantity)
+		notional = &value
+	case no.quoteOrderQty != nil:
+		notional = no.quoteOrderQty
+	case fc.avgPrice != nil && no.quantity != nil:
+		value := fc.avgPrice.Mul(*no.quantity)
+		notional = &value
+	}
+	isMarket := orderType == OrderTypeMarket
+	if f := symbolInfo.filter(FilterMinNotional); f != nil && notional != nil && (!isMarket || f.ApplyToMarket) {
+		if notional.LessThan(f.MinNotional) {
+			return fail(FilterMinNotional, "notional %s below minNotional %s", notional, f.MinNotional)
+		}
+	}
+	if f := symbolInfo.filter(FilterNotional); f != nil && notional != nil {
+		if (!isMarket || f.ApplyMinToMarket) && notional.LessThan(f.MinNotional) {
+			return fail(FilterNotional, "notional %s below minNotional %s", notional, f.MinNotional)
+		}
+		if (!isMarket || f.ApplyMaxToMarket) && f.MaxNotional.Sign() > 0 && notional.GreaterThan(f.MaxNotional) {
+			return fail(FilterNotional, "notional %s above maxNotional %s", notional, f.MaxNotional)
+		}
+	}
+
+	// PERCENT_PRICE_BY_SIDE：限价不能偏离均价太多
+	if f := symbolInfo.filter(FilterPercentPriceBySide); f != nil && fc.avgPrice != nil && no.price != nil {
+		up, down := f.BidMultiplierUp, f.BidMultiplierDown
+		if side == SideSell {
+			up, down = f.AskMultiplierUp, f.AskMultiplierDown
+		}
+		upper, lower := fc.avgPrice.Mul(up), fc.avgPrice.Mul(down)
+		if no.price.GreaterThan(upper) || no.price.LessThan(lower) {
+			return fail(FilterPercentPriceBySide, "price %s outside [%s, %s] for %s", no.price, lower, upper, side)
+		}
+	}
+
+	// ICEBERG_PARTS：quantity / icebergQty 向上取整后不能超过 limit
+	if f := symbolInfo.filter(FilterIcebergParts); f != nil && no.icebergQty != nil && no.quantity != nil && no.icebergQty.Sign() > 0 {
+		parts := no.quantity.Div(*no.icebergQty).RoundUpToStep(newDecimalFromInt(1))
+		if parts.GreaterThan(newDecimalFromInt(int64(f.Limit))) {
+			return fail(FilterIcebergParts, "%s iceberg parts exceeds limit %d", parts, f.Limit)
+		}
+	}
+
+	// MAX_NUM_ORDERS / MAX_NUM_ALGO_ORDERS
+	if f := symbolInfo.filter(FilterMaxNumOrders); f != nil && fc.openOrders != nil && *fc.openOrders+1 > f.MaxNumOrders {
+		return fail(FilterMaxNumOrders, "%d open orders, max %d", *fc.openOrders, f.MaxNumOrders)
+	}
+	if isAlgoOrder(orderType) {
+		if f := symbolInfo.filter(FilterMaxNumAlgoOrders); f != nil && fc.algoOrders != nil && *fc.algoOrders+1 > f.MaxNumAlgoOrders {
+			return fail(FilterMaxNumAlgoOrders, "%d open algo orders, max %d", *fc.algoOrders, f.MaxNumAlgoOrders)
+		}
+	}
+
+	// TRAILING_DELTA：止损买单和止盈卖单在价格上方触发，其余在下方
+	if f := symbolInfo.filter(FilterTrailingDelta); f != nil && no.trailingDelta != nil {
+		if !symbolInfo.AllowTrailingStop {
+			return fail(FilterTrailingDelta, "trailing stop not allowed")
+		}
+		minDelta, maxDelta := f.MinTrailingBelowDelta, f.MaxTrailingBelowDelta
+		if triggersAbove(side, orderType) {
+			minDelta, maxDelta = f.MinTrailingAboveDelta, f.MaxTrailingAboveDelta
+		}
+		if *no.trailingDelta < minDelta || *no.trailingDelta > maxDelta {
+			return fail(FilterTrailingDelta, "trailingDelta %d outside [%d, %d]", *no.trailingDelta, minDelta, maxDelta)
+		}
+	}
+	return no, nil
+}
+
+// 拉取该交易对的规则后检查订单，返回取整后的 NewOrder
+func checkOrderFilters(
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	no NewOrder,
+	fc FilterCheck,
+	proxyURL string,
+) (NewOrder, error) {
+	exchangeInfo, err := getExchangeInfoDetail(ExchangeInfo{symbol: symbol}, proxyURL)
+	if err != nil {
+		return no, err
+	}
+	for _, symbolInfo := range exchangeInfo.Symbols {
+		if symbolInfo.Symbol == symbol {
+			return applyExchangeFilters(symbolInfo, side, orderType, no, fc)
+		}
+	}
+	return no, fmt.Errorf("filter: unknown symbol %s", symbol)
+}
+
+// 条件单，计入 MAX_NUM_ALGO_ORDERS
+func isAlgoOrder(orderType OrderType) bool {
+	switch orderType {
+	case OrderTypeStopLoss, OrderTypeStopLossLimit, OrderTypeTakeProfit, OrderTypeTakeProfitLimit:
+		return true
+	}
+	return false
+}
+
+// 触发价是否在当前价格上方
+func triggersAbove(side OrderSide, orderType OrderType) bool {
+	switch orderType {
+	case OrderTypeStopLoss, OrderTypeStopLossLimit:
+		return side == SideBuy
+	case OrderTypeTakeProfit, OrderTypeTakeProfitLimit:
+		return side == SideSell
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// 测试用的 BTCUSDT 规则，与现货交易所的真实配置接近
+func testSymbolDetail() *SymbolDetail {
+	return &SymbolDetail{
+		Symbol:     "BTCUSDT",
+		Status:     "TRADING",
+		BaseAsset:  "BTC",
+		QuoteAsset: "USDT",
+
+		BaseAssetPrecision:  8,
+		QuoteAssetPrecision: 8,
+		OrderTypes: []string{
+			string(OrderTypeLimit), string(OrderTypeLimitMaker), string(OrderTypeMarket),
+			string(OrderTypeStopLoss), string(OrderTypeStopLossLimit),
+			string(OrderTypeTakeProfit), string(OrderTypeTakeProfitLimit),
+		},
+		IcebergAllowed:                  true,
+		OcoAllowed:                      true,
+		QuoteOrderQtyMarketAllowed:      true,
+		AllowTrailingStop:               true,
+		CancelReplaceAllowed:            true,
+		AmendAllowed:                    true,
+		DefaultSelfTradePreventionMode:  STPModeExpireMaker,
+		AllowedSelfTradePreventionModes: []STPMode{STPModeNone, STPModeExpireMaker, STPModeExpireTaker, STPModeExpireBoth},
+		Filters: []SymbolFilter{
+			{FilterType: FilterPriceFilter, MinPrice: mustDecimal("0.01"), MaxPrice: mustDecimal("1000000"), TickSize: mustDecimal("0.01")},
+			{FilterType: FilterLotSize, MinQty: mustDecimal("0.00001"), MaxQty: mustDecimal("9000"), StepSize: mustDecimal("0.00001")},
+			{FilterType: FilterMarketLotSize, MaxQty: mustDecimal("100")},
+			{FilterType: FilterNotional, MinNotional: mustDecimal("5"), MaxNotional: mustDecimal("9000000"), ApplyMinToMarket: true, ApplyMaxToMarket: false},
+			{FilterType: FilterPercentPriceBySide, BidMultiplierUp: mustDecimal("5"), BidMultiplierDown: mustDecimal("0.2"), AskMultiplierUp: mustDecimal("5"), AskMultiplierDown: mustDecimal("0.2")},
+			{FilterType: FilterIcebergParts, Limit: 10},
+			{FilterType: FilterMaxNumOrders, MaxNumOrders: 200},
+			{FilterType: FilterMaxNumAlgoOrders, MaxNumAlgoOrders: 5},
+			{FilterType: FilterTrailingDelta, MinTrailingAboveDelta: 10, MaxTrailingAboveDelta: 2000, MinTrailingBelowDelta: 10, MaxTrailingBelowDelta: 2000},
+		},
+	}
+}
+
+func decimalPtr(s string) *Decimal {
+	d := mustDecimal(s)
+	return &d
+}
+
+func TestApplyExchangeFilters(t *testing.T) {
+	gtc := TimeInForceGTC
+	avgPrice := decimalPtr("30000")
+	openOrders, algoOrders := 200, 5
+	stpDecrement := STPModeDecrement
+	trailingDelta := 5
+
+	tests := []struct {
+		name       string
+		side       OrderSide
+		orderType  OrderType
+		no         NewOrder
+		fc         FilterCheck
+		wantFilter string // 为空表示通过
+		wantPrice  string
+		wantQty    string
+	}{
+		{
+			name: "valid limit", side: SideBuy, orderType: OrderTypeLimit,
+			no:        NewOrder{price: decimalPtr("30000.01"), quantity: decimalPtr("0.001"), timeInForce: &gtc},
+			wantPrice: "30000.01", wantQty: "0.001",
+		},
+		{
+			name: "price off tick", side: SideBuy, orderType: OrderTypeLimit,
+			no:         NewOrder{price: decimalPtr("30000.015"), quantity: decimalPtr("0.001"), timeInForce: &gtc},
+			wantFilter: FilterPriceFilter,
+		},
+		{
+			name: "buy price rounds down", side: SideBuy, orderType: OrderTypeLimit,
+			no:        NewOrder{price: decimalPtr("30000.019"), quantity: decimalPtr("0.001"), timeInForce: &gtc},
+			fc:        FilterCheck{roundPrice: true},
+			wantPrice: "30000.01", wantQty: "0.001",
+		},
+		{
+			name: "sell price rounds up", side: SideSell, orderType: OrderTypeLimit,
+			no:        NewOrder{price: decimalPtr("30000.011"), quantity: decimalPtr("0.001"), timeInForce: &gtc},
+			fc:        FilterCheck{roundPrice: true},
+			wantPrice: "30000.02", wantQty: "0.001",
+		},
+		{
+			name: "quantity rounds down to step", side: SideBuy, orderType: OrderTypeLimit,
+			no:        NewOrder{price: decimalPtr("30000"), quantity: decimalPtr("0.0012345"), timeInForce: &gtc},
+			fc:        FilterCheck{roundQuantity: true},
+			wantPrice: "30000", wantQty: "0.00123",
+		},
+		{
+			name: "quantity off step", side: SideBuy, orderType: OrderTypeLimit,
+			no:         NewOrder{price: decimalPtr("30000"), quantity: decimalPtr("0.0012345"), timeInForce: &gtc},
+			wantFilter: FilterLotSize,
+		},
+		{
+			name: "quantity below minQty", side: SideBuy, orderType: OrderTypeLimit,
+			no:         NewOrder{price: decimalPtr("30000"), quantity: decimalPtr("0.000001"), timeInForce: &gtc},
+			fc:         FilterCheck{roundQuantity: true},
+			wantFilter: FilterLotSize,
+		},
+		{
+			name: "market above MARKET_LOT_SIZE", side: SideSell, orderType: OrderTypeMarket,
+			no:         NewOrder{quantity: decimalPtr("150")},
+			wantFilter: FilterMarketLotSize,
+		},
+		{
+			name: "notional too small", side: SideBuy, orderType: OrderTypeLimit,
+			no:         NewOrder{price: decimalPtr("1000"), quantity: decimalPtr("0.001"), timeInForce: &gtc},
+			wantFilter: FilterNotional,
+		},
+		{
+			name: "market quoteOrderQty below minNotional", side: SideBuy, orderType: OrderTypeMarket,
+			no:         NewOrder{quoteOrderQty: decimalPtr("4")},
+			wantFilter: FilterNotional,
+		},
+		{
+			name: "market notional from avgPrice", side: SideSell, orderType: OrderTypeMarket,
+			no:         NewOrder{quantity: decimalPtr("0.0001")},
+			fc:         FilterCheck{avgPrice: avgPrice},
+			wantFilter: FilterNotional,
+		},
+		{
+			name: "market without avgPrice skips notional", side: SideSell, orderType: OrderTypeMarket,
+			no:      NewOrder{quantity: decimalPtr("0.0001")},
+			wantQty: "0.0001",
+		},
+		{
+			name: "price too far from avgPrice", side: SideBuy, orderType: OrderTypeLimit,
+			no:         NewOrder{price: decimalPtr("5000"), quantity: decimalPtr("0.01"), timeInForce: &gtc},
+			fc:         FilterCheck{avgPrice: avgPrice},
+			wantFilter: FilterPercentPriceBySide,
+		},
+		{
+			name: "too many iceberg parts", side: SideBuy, orderType: OrderTypeLimit,
+			no:         NewOrder{price: decimalPtr("30000"), quantity: decimalPtr("0.011"), icebergQty: decimalPtr("0.001"), timeInForce: &gtc},
+			wantFilter: FilterIcebergParts,
+		},
+		{
+			name: "max open orders", side: SideBuy, orderType: OrderTypeLimit,
+			no:         NewOrder{price: decimalPtr("30000"), quantity: decimalPtr("0.001"), timeInForce: &gtc},
+			fc:         FilterCheck{openOrders: &openOrders},
+			wantFilter: FilterMaxNumOrders,
+		},
+		{
+			name: "max algo orders", side: SideSell, orderType: OrderTypeStopLossLimit,
+			no:         NewOrder{price: decimalPtr("29000"), stopPrice: decimalPtr("29100"), quantity: decimalPtr("0.001"), timeInForce: &gtc},
+			fc:         FilterCheck{algoOrders: &algoOrders},
+			wantFilter: FilterMaxNumAlgoOrders,
+		},
+		{
+			name: "trailing delta too small", side: SideSell, orderType: OrderTypeStopLoss,
+			no:         NewOrder{quantity: decimalPtr("0.001"), trailingDelta: &trailingDelta},
+			wantFilter: FilterTrailingDelta,
+		},
+		{
+			name: "stp mode not allowed", side: SideBuy, orderType: OrderTypeLimit,
+			no:         NewOrder{price: decimalPtr("30000"), quantity: decimalPtr("0.001"), timeInForce: &gtc, selfTradePreventionMode: &stpDecrement},
+			wantFilter: "STP_MODES",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyExchangeFilters(testSymbolDetail(), tt.side, tt.orderType, tt.no, tt.fc)
+			if tt.wantFilter != "" {
+				var filterErr *FilterError
+				if !errors.As(err, &filterErr) {
+					t.Fatalf("error = %v, want FilterError %s", err, tt.wantFilter)
+				}
+				if filterErr.Filter != tt.wantFilter {
+					t.Fatalf("filter = %s (%s), want %s", filterErr.Filter, filterErr.Message, tt.wantFilter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantPrice != "" && (got.price == nil || got.price.String() != tt.wantPrice) {
+				t.Errorf("price = %v, want %s", got.price, tt.wantPrice)
+			}
+			if tt.wantQty != "" && (got.quantity == nil || got.quantity.String() != tt.wantQty) {
+				t.Errorf("quantity = %v, want %s", got.quantity, tt.wantQty)
+			}
+		})
+	}
+}
+
+func TestApplyExchangeFiltersDoesNotModifyInput(t *testing.T) {
+	gtc := TimeInForceGTC
+	price, qty := mustDecimal("30000.019"), mustDecimal("0.0012345")
+	no := NewOrder{price: &price, quantity: &qty, timeInForce: &gtc}
+	if _, err := applyExchangeFilters(testSymbolDetail(), SideBuy, OrderTypeLimit, no, FilterCheck{roundPrice: true, roundQuantity: true}); err != nil {
+		t.Fatal(err)
+	}
+	if no.price.String() != "30000.019" || no.quantity.String() != "0.0012345" {
+		t.Errorf("input modified: price %s quantity %s", no.price, no.quantity)
+	}
+}
+
+func TestApplyExchangeFiltersSymbolState(t *testing.T) {
+	symbolInfo := testSymbolDetail()
+	symbolInfo.Status = "BREAK"
+	_, err := applyExchangeFilters(symbolInfo, SideBuy, OrderTypeMarket, NewOrder{quoteOrderQty: decimalPtr("10")}, FilterCheck{})
+	var filterErr *FilterError
+	if !errors.As(err, &filterErr) || filterErr.Filter != "STATUS" {
+		t.Errorf("error = %v, want STATUS", err)
+	}
+
+	symbolInfo = testSymbolDetail()
+	symbolInfo.OrderTypes = []string{string(OrderTypeLimit)}
+	_, err = applyExchangeFilters(symbolInfo, SideBuy, OrderTypeMarket, NewOrder{quoteOrderQty: decimalPtr("10")}, FilterCheck{})
+	if !errors.As(err, &filterErr) || filterErr.Filter != "ORDER_TYPES" {
+		t.Errorf("error = %v, want ORDER_TYPES", err)
+	}
+}
+
+// 不访问网络的注册表，直接写入交易对
+func testSymbolRegistry(symbols ...*SymbolDetail) *SymbolRegistry {
+	r := newSymbolRegistry(ExchangeInfo{}, 0, "")
+	r.exchangeInfo = &ExchangeInfoDetail{Symbols: symbols}
+	r.bySymbol = make(map[string]*SymbolDetail)
+	r.byBaseAsset = make(map[string][]*SymbolDetail)
+	r.byQuoteAsset = make(map[string][]*SymbolDetail)
+	for _, symbolInfo := range symbols {
+		r.bySymbol[symbolInfo.Symbol] = symbolInfo
+		r.byBaseAsset[symbolInfo.BaseAsset] = append(r.byBaseAsset[symbolInfo.BaseAsset], symbolInfo)
+		r.byQuoteAsset[symbolInfo.QuoteAsset] = append(r.byQuoteAsset[symbolInfo.QuoteAsset], symbolInfo)
+	}
+	return r
+}
@@ -0,0 +1,669 @@
+package main
+
+import (
+	"binance_connector"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 执行算法的状态
+type AlgoStatus string
+
+const (
+	AlgoStatusPending   AlgoStatus = "PENDING"
+	AlgoStatusRunning   AlgoStatus = "RUNNING"
+	AlgoStatusPaused    AlgoStatus = "PAUSED"
+	AlgoStatusCanceled  AlgoStatus = "CANCELED"
+	AlgoStatusCompleted AlgoStatus = "COMPLETED"
+	AlgoStatusFailed    AlgoStatus = "FAILED"
+)
+
+func (s AlgoStatus) isFinal() bool {
+	return s == AlgoStatusCanceled || s == AlgoStatusCompleted || s == AlgoStatusFailed
+}
+
+// 子单数量不满足 LOT_SIZE/MIN_NOTIONAL 等规则时返回，数量累加到下一次
+var errSliceTooSmall = errors.New("algo: slice below exchange minimums")
+
+// 母单拆出的子订单
+type ChildOrder struct {
+	OrderId            int64
+	ClientOrderId      string
+	Type               OrderType
+	Price              Decimal
+	Qty                Decimal
+	ExecutedQty        Decimal
+	CumulativeQuoteQty Decimal
+	Status             OrderStatus
+}
+
+// 执行进度
+type AlgoProgress struct {
+	Symbol       string
+	Side         OrderSide
+	Status       AlgoStatus
+	TargetQty    Decimal
+	FilledQty    Decimal
+	RemainingQty Decimal
+	AvgPrice     Decimal
+	SlicesSent   int
+	Children     []ChildOrder
+	Error        string
+}
+
+// 执行算法的公共部分：子单下单、通过 getQueryOrder 跟踪成交、用 cancelReplace 改挂未成交子单，以及暂停/恢复/取消
+// 同一时间最多只有一个挂着的子单
+type algoExecution struct {
+	api      TradingAPI
+	registry *SymbolRegistry // 为空时不做交易所规则检查
+	ids      *ClientOrderIdGenerator
+	proxyURL string
+	symbol   string
+	side     OrderSide
+	target   Decimal
+
+	mu         sync.Mutex
+	status     AlgoStatus
+	children   []*ChildOrder
+	active     *ChildOrder
+	slicesSent int
+	err        error
+	startTime  time.Time
+	endTime    time.Time
+	arrival    Decimal // 开始时的中间价
+	resumeCh   chan struct{}
+	cancelCh   chan struct{}
+	doneCh     chan struct{}
+	onProgress func(AlgoProgress)
+}
+
+func newAlgoExecution(
+	api TradingAPI,
+	registry *SymbolRegistry,
+	ids *ClientOrderIdGenerator,
+	symbol string,
+	side OrderSide,
+	target Decimal,
+	proxyURL string,
+) *algoExecution {
+	return &algoExecution{
+		api:      api,
+		registry: registry,
+		ids:      ids,
+		proxyURL: proxyURL,
+		symbol:   symbol,
+		side:     side,
+		target:   target,
+		status:   AlgoStatusPending,
+		cancelCh: make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// 每次子单变化后回调，回调在执行 goroutine 中同步调用
+func (a *algoExecution) setOnProgress(onProgress func(AlgoProgress)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onProgress = onProgress
+}
+
+// 记录到达价后开始执行，run 返回后根据错误决定最终状态
+func (a *algoExecution) launch(run func() error) error {
+	a.mu.Lock()
+	if a.status != AlgoStatusPending {
+		a.mu.Unlock()
+		return fmt.Errorf("algo: already started")
+	}
+	a.status = AlgoStatusRunning
+	a.mu.Unlock()
+
+	arrival, err := a.midPrice()
+	a.mu.Lock()
+	if err != nil {
+		a.status = AlgoStatusPending
+		a.mu.Unlock()
+		return err
+	}
+	a.startTime = time.Now()
+	a.arrival = arrival
+	a.mu.Unlock()
+
+	go func() {
+		err := run()
+		// 停止时撤掉挂着的子单
+		if cancelErr := a.cancelActive(); cancelErr != nil && err == nil {
+			err = cancelErr
+		}
+		a.mu.Lock()
+		switch {
+		case a.isCanceled():
+			a.status = AlgoStatusCanceled
+		case err != nil:
+			a.status = AlgoStatusFailed
+			a.err = err
+		default:
+			a.status = AlgoStatusCompleted
+		}
+		a.endTime = time.Now()
+		a.mu.Unlock()
+		a.notify()
+		close(a.doneCh)
+	}()
+	return nil
+}
+
+// 暂停：不再下新子单，已挂的子单保持不动
+func (a *algoExecution) pause() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status == AlgoStatusRunning {
+		a.status = AlgoStatusPaused
+		a.resumeCh = make(chan struct{})
+	}
+}
+
+// 恢复执行，暂停期间错过的数量会在下一次子单中补上
+func (a *algoExecution) resume() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status == AlgoStatusPaused {
+		a.status = AlgoStatusRunning
+		close(a.resumeCh)
+		a.resumeCh = nil
+	}
+}
+
+// 取消执行并撤掉挂着的子单，等待结束可以用 wait
+func (a *algoExecution) cancel() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.isCanceled() && !a.status.isFinal() {
+		close(a.cancelCh)
+	}
+}
+
+func (a *algoExecution) isCanceled() bool {
+	select {
+	case <-a.cancelCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// 等待执行结束，返回执行中的错误
+func (a *algoExecution) wait() error {
+	<-a.doneCh
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// 当前进度
+func (a *algoExecution) progress() AlgoProgress {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.progressLocked()
+}
+
+func (a *algoExecution) progressLocked() AlgoProgress {
+	p := AlgoProgress{
+		Symbol:     a.symbol,
+		Side:       a.side,
+		Status:     a.status,
+		TargetQty:  a.target,
+		SlicesSent: a.slicesSent,
+	}
+	var quote Decimal
+	for _, child := range a.children {
+		p.FilledQty = p.FilledQty.Add(child.ExecutedQty)
+		quote = quote.Add(child.CumulativeQuoteQty)
+		p.Children = append(p.Children, *child)
+	}
+	p.RemainingQty = a.target.Sub(p.FilledQty)
+	if !p.FilledQty.IsZero() {
+		p.AvgPrice = quote.Div(p.FilledQty)
+	}
+	if a.err != nil {
+		p.Error = a.err.Error()
+	}
+	return p
+}
+
+func (a *algoExecution) notify() {
+	a.mu.Lock()
+	onProgress := a.onProgress
+	p := a.progressLocked()
+	a.mu.Unlock()
+	if onProgress != nil {
+		onProgress(p)
+	}
+}
+
+// 已成交数量
+func (a *algoExecution) filled() Decimal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var filled Decimal
+	for _, child := range a.children {
+		filled = filled.Add(child.ExecutedQty)
+	}
+	return filled
+}
+
+// 等到 t，暂停期间一直阻塞；被取消时返回 false
+func (a *algoExecution) waitUntil(t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-a.cancelCh:
+		return false
+	case <-timer.C:
+	}
+	for {
+		a.mu.Lock()
+		resumeCh := a.resumeCh
+		a.mu.Unlock()
+		if resumeCh == nil {
+			return true
+		}
+		select {
+		case <-a.cancelCh:
+			return false
+		case <-resumeCh:
+		}
+	}
+}
+
+// 盘口最优价
+func (a *algoExecution) bookTicker() (bid, ask Decimal, err error) {
+	symbol := a.symbol
+	tickers, err := getSymbolOrderBookTicker("", "", inputTokens{symbol: &symbol}, a.proxyURL)
+	if err != nil {
+		return bid, ask, err
+	}
+	if len(tickers) == 0 {
+		return bid, ask, fmt.Errorf("algo: no book ticker for %s", a.symbol)
+	}
+	return decimalOrZero(tickers[0].BidPrice), decimalOrZero(tickers[0].AskPrice), nil
+}
+
+// 被动挂单价：买单挂买一，卖单挂卖一，不超过 limitPrice
+func (a *algoExecution) passivePrice(limitPrice *Decimal) (Decimal, error) {
+	bid, ask, err := a.bookTicker()
+	if err != nil {
+		return Decimal{}, err
+	}
+	price := ask
+	if a.side == SideBuy {
+		price = bid
+	}
+	if limitPrice != nil && !a.withinLimit(price, *limitPrice) {
+		price = *limitPrice
+	}
+	return price, nil
+}
+
+// 价格是否在限价保护内：买入不高于、卖出不低于 limitPrice
+func (a *algoExecution) withinLimit(price, limitPrice Decimal) bool {
+	if a.side == SideBuy {
+		return !price.GreaterThan(limitPrice)
+	}
+	return !price.LessThan(limitPrice)
+}
+
+// 市价单的对手价是否在限价保护内
+func (a *algoExecution) marketWithinLimit(limitPrice *Decimal) (bool, error) {
+	if limitPrice == nil {
+		return true, nil
+	}
+	bid, ask, err := a.bookTicker()
+	if err != nil {
+		return false, err
+	}
+	if a.side == SideBuy {
+		return a.withinLimit(ask, *limitPrice), nil
+	}
+	return a.withinLimit(bid, *limitPrice), nil
+}
+
+// 按交易所规则对齐数量和价格，不满足最小值时返回 errSliceTooSmall
+func (a *algoExecution) prepare(orderType OrderType, no NewOrder) (NewOrder, error) {
+	if a.registry == nil {
+		return no, nil
+	}
+	no, err := a.registry.checkOrder(a.symbol, a.side, orderType, no, FilterCheck{roundPrice: true, roundQuantity: true})
+	var filterErr *FilterError
+	if errors.As(err, &filterErr) && (filterErr.Filter == FilterLotSize || filterErr.Filter == FilterMarketLotSize ||
+		filterErr.Filter == FilterMinNotional || filterErr.Filter == FilterNotional) {
+		return no, fmt.Errorf("%w: %v", errSliceTooSmall, err)
+	}
+	return no, err
+}
+
+// 下一个子单，LIMIT 单未成交完时成为当前挂单
+func (a *algoExecution) placeChild(orderType OrderType, qty Decimal, price *Decimal) error {
+	no := NewOrder{quantity: &qty, price: price}
+	if orderType == OrderTypeLimit {
+		timeInForce := TimeInForceGTC
+		no.timeInForce = &timeInForce
+	}
+	no, err := a.prepare(orderType, no)
+	if err != nil {
+		return err
+	}
+	if a.ids != nil {
+		no = a.ids.assignNewOrder(no)
+	}
+	newOrder, err := a.api.createNewOrder(a.symbol, a.side, orderType, no)
+	if err != nil {
+		return err
+	}
+
+	child := &ChildOrder{
+		OrderId:            newOrder.OrderId,
+		ClientOrderId:      newOrder.ClientOrderId,
+		Type:               orderType,
+		Qty:                *no.quantity,
+		ExecutedQty:        newOrder.ExecutedQty,
+		CumulativeQuoteQty: newOrder.CumulativeQuoteQty,
+		Status:             OrderStatus(newOrder.Status),
+	}
+	if no.price != nil {
+		child.Price = *no.price
+	}
+	if child.Status == "" {
+		child.Status = OrderStatusNew
+	}
+	a.mu.Lock()
+	a.children = append(a.children, child)
+	a.slicesSent++
+	if !child.Status.isFinal() && newOrder.Status != dryRunResult {
+		a.active = child
+	}
+	a.mu.Unlock()
+	a.notify()
+	return nil
+}
+
+// 用 getQueryOrder 更新当前挂单的成交
+func (a *algoExecution) refreshActive() error {
+	a.mu.Lock()
+	active := a.active
+	a.mu.Unlock()
+	if active == nil {
+		return nil
+	}
+	orderId := active.OrderId
+	order, err := a.api.getQueryOrder(a.symbol, QueryOrder{orderId: &orderId})
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	active.ExecutedQty = decimalOrZero(order.ExecutedQty)
+	active.CumulativeQuoteQty = decimalOrZero(order.CumulativeQuoteQty)
+	active.Status = OrderStatus(order.Status)
+	if active.Status.isFinal() {
+		a.active = nil
+	}
+	a.mu.Unlock()
+	a.notify()
+	return nil
+}
+
+// 当前挂单的快照
+func (a *algoExecution) activeOrder() (ChildOrder, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.active == nil {
+		return ChildOrder{}, false
+	}
+	return *a.active, true
+}
+
+// 把当前挂单改成 qty@price：没有挂单时直接下单，否则用 cancelReplace 撤单重下
+// qty 按上次查询的成交计算，旧挂单在撤单前又成交的部分从新挂单中减掉
+func (a *algoExecution) replaceActive(qty, price Decimal) error {
+	a.mu.Lock()
+	active := a.active
+	var knownExecuted Decimal
+	if active != nil {
+		knownExecuted = active.ExecutedQty
+	}
+	a.mu.Unlock()
+	if active == nil {
+		return a.placeChild(OrderTypeLimit, qty, &price)
+	}
+
+	no, err := a.prepare(OrderTypeLimit, NewOrder{quantity: &qty, price: &price})
+	if err != nil {
+		return err
+	}
+	timeInForce := TimeInForceGTC
+	cancelOrderId := active.OrderId
+	cr := CancelReplace{
+		timeInForce:   &timeInForce,
+		quantity:      no.quantity,
+		price:         no.price,
+		cancelOrderId: &cancelOrderId,
+	}
+	if a.ids != nil {
+		cr = a.ids.assignCancelReplace(cr)
+	}
+	replaced, err := a.api.cancelReplace(a.symbol, a.side, OrderTypeLimit, CancelReplaceStopOnFailure, cr)
+	if err != nil {
+		// 撤单失败通常是子单已经成交，重新查询后由下一次调度处理
+		if refreshErr := a.refreshActive(); refreshErr != nil {
+			return refreshErr
+		}
+		return nil
+	}
+
+	a.mu.Lock()
+	var overfill Decimal
+	if replaced.CancelResponse != nil {
+		active.ExecutedQty = decimalOrZero(replaced.CancelResponse.ExecutedQty)
+		active.CumulativeQuoteQty = decimalOrZero(replaced.CancelResponse.CumulativeQuoteQty)
+		active.Status = OrderStatus(replaced.CancelResponse.Status)
+		overfill = active.ExecutedQty.Sub(knownExecuted)
+	}
+	a.active = nil
+	var child *ChildOrder
+	if newOrder := replaced.NewOrderResponse; newOrder != nil && newOrder.OrderId != 0 {
+		child = &ChildOrder{
+			OrderId:            newOrder.OrderId,
+			ClientOrderId:      newOrder.ClientOrderId,
+			Type:               OrderTypeLimit,
+			Price:              *no.price,
+			Qty:                *no.quantity,
+			ExecutedQty:        decimalOrZero(newOrder.ExecutedQty),
+			CumulativeQuoteQty: decimalOrZero(newOrder.CumulativeQuoteQty),
+			Status:             OrderStatus(newOrder.Status),
+		}
+		a.children = append(a.children, child)
+		a.slicesSent++
+		if !child.Status.isFinal() {
+			a.active = child
+		}
+	}
+	shrink := overfill.Sign() > 0 && child != nil && a.active == child
+	a.mu.Unlock()
+	a.notify()
+	if shrink {
+		return a.shrinkActive(child, child.Qty.Sub(overfill))
+	}
+	return nil
+}
+
+// 把挂单数量减到 qty 并保留排队位置，已成交不少于 qty 或改单失败时撤单，差额留到下一次
+func (a *algoExecution) shrinkActive(child *ChildOrder, qty Decimal) error {
+	a.mu.Lock()
+	executed := child.ExecutedQty
+	a.mu.Unlock()
+	if !qty.GreaterThan(executed) {
+		return a.cancelActive()
+	}
+	orderId := child.OrderId
+	amended, err := a.api.amendOrderKeepPriority(a.symbol, qty, AmendOrder{orderId: &orderId})
+	if err != nil {
+		return a.cancelActive()
+	}
+
+	order := amended.AmendedOrder
+	a.mu.Lock()
+	child.Qty = order.Qty
+	child.ExecutedQty = order.ExecutedQty
+	child.CumulativeQuoteQty = order.CumulativeQuoteQty
+	child.Status = OrderStatus(order.Status)
+	if child.Status.isFinal() && a.active == child {
+		a.active = nil
+	}
+	a.mu.Unlock()
+	a.notify()
+	return nil
+}
+
+// 撤掉当前挂单
+func (a *algoExecution) cancelActive() error {
+	a.mu.Lock()
+	active := a.active
+	a.mu.Unlock()
+	if active == nil {
+		return nil
+	}
+	orderId := active.OrderId
+	canceled, err := a.api.cancelOrder(a.symbol, CancelOrder{orderId: &orderId})
+	if err != nil {
+		// 可能已经成交，以查询结果为准
+		return a.refreshActive()
+	}
+	a.applyCancel(active, canceled)
+	return nil
+}
+
+func (a *algoExecution) applyCancel(child *ChildOrder, canceled *binance_connector.CancelOrderResponse) {
+	a.mu.Lock()
+	child.ExecutedQty = decimalOrZero(canceled.ExecutedQty)
+	child.CumulativeQuoteQty = decimalOrZero(canceled.CumulativeQuoteQty)
+	child.Status = OrderStatus(canceled.Status)
+	if a.active == child {
+		a.active = nil
+	}
+	a.mu.Unlock()
+	a.notify()
+}
+
+// 让已成交加挂单数量追上 due：MARKET 直接补齐，LIMIT 以被动价新挂或改挂
+// 对手价超出 limitPrice 或数量不满足交易所最小值时跳过，差额留到下一次
+func (a *algoExecution) catchUp(due Decimal, orderType OrderType, limitPrice *Decimal) error {
+	if err := a.refreshActive(); err != nil {
+		return err
+	}
+	outstanding := due.Sub(a.filled())
+	if outstanding.Sign() <= 0 {
+		return nil
+	}
+
+	var err error
+	if orderType == OrderTypeMarket {
+		var ok bool
+		if ok, err = a.marketWithinLimit(limitPrice); err != nil || !ok {
+			return err
+		}
+		err = a.placeChild(OrderTypeMarket, outstanding, nil)
+	} else {
+		var price Decimal
+		if price, err = a.passivePrice(limitPrice); err != nil {
+			return err
+		}
+		// 价格和数量都没变时保留挂单，不丢排队位置
+		if active, ok := a.activeOrder(); ok && active.Price.Equal(price) && active.Qty.Sub(active.ExecutedQty).Equal(outstanding) {
+			return nil
+		}
+		err = a.replaceActive(outstanding, price)
+	}
+	if errors.Is(err, errSliceTooSmall) {
+		return nil
+	}
+	return err
+}
+
+// 撤掉挂单，marketOnFinish 为 true 时用市价单补齐剩余数量
+func (a *algoExecution) finishRemaining(marketOnFinish bool, limitPrice *Decimal) error {
+	if err := a.cancelActive(); err != nil {
+		return err
+	}
+	remaining := a.target.Sub(a.filled())
+	if !marketOnFinish || remaining.Sign() <= 0 {
+		return nil
+	}
+	ok, err := a.marketWithinLimit(limitPrice)
+	if err != nil || !ok {
+		return err
+	}
+	err = a.placeChild(OrderTypeMarket, remaining, nil)
+	if errors.Is(err, errSliceTooSmall) {
+		return nil
+	}
+	return err
+}
+
+// 中间价，用作到达价
+func (a *algoExecution) midPrice() (Decimal, error) {
+	bid, ask, err := a.bookTicker()
+	if err != nil {
+		return Decimal{}, err
+	}
+	return bid.Add(ask).Div(newDecimalFromInt(2)), nil
+}
+
+// 执行结束后的成交质量报告
+type AlgoReport struct {
+	AlgoProgress
+	StartTime      time.Time
+	EndTime        time.Time
+	ArrivalPrice   Decimal // 开始时的中间价
+	BenchmarkName  string
+	BenchmarkPrice Decimal
+	SlippageBps    Decimal // 成交均价相对基准的滑点，正数表示比基准差
+	ArrivalBps     Decimal // 成交均价相对到达价的滑点
+}
+
+// 等待执行结束，返回开始和结束时间
+func (a *algoExecution) window() (time.Time, time.Time) {
+	<-a.doneCh
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.startTime, a.endTime
+}
+
+// 等待执行结束后生成报告
+func (a *algoExecution) buildReport(benchmarkName string, benchmark Decimal) AlgoReport {
+	<-a.doneCh
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	report := AlgoReport{
+		AlgoProgress:   a.progressLocked(),
+		StartTime:      a.startTime,
+		EndTime:        a.endTime,
+		ArrivalPrice:   a.arrival,
+		BenchmarkName:  benchmarkName,
+		BenchmarkPrice: benchmark,
+	}
+	report.SlippageBps = a.slippageBps(report.AvgPrice, benchmark)
+	report.ArrivalBps = a.slippageBps(report.AvgPrice, a.arrival)
+	return report
+}
+
+func (a *algoExecution) slippageBps(price, reference Decimal) Decimal {
+	if price.IsZero() || reference.Sign() <= 0 {
+		return Decimal{}
+	}
+	diff := price.Sub(reference)
+	if a.side == SideSell {
+		diff = diff.Neg()
+	}
+	return diff.Mul(newDecimalFromInt(10000)).Div(reference).Round(2)
+}
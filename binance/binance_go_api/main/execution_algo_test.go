@@ -0,0 +1,94 @@
+package main
+
+import (
+	"binance_connector"
+	"encoding/json"
+	"testing"
+)
+
+// 改挂时旧子单在撤单前已经部分成交
+type fakeReplaceAPI struct {
+	TradingAPI
+	canceledExecutedQty string
+	amended             []Decimal
+	canceled            []int64
+}
+
+func (f *fakeReplaceAPI) createNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*CreateOrderResponse, error) {
+	return &CreateOrderResponse{Symbol: symbol, OrderId: 1, Status: string(OrderStatusNew)}, nil
+}
+
+func (f *fakeReplaceAPI) cancelReplace(symbol string, side OrderSide, orderType OrderType, cancelReplaceMode CancelReplaceMode, cr CancelReplace) (*binance_connector.CancelReplaceResponse, error) {
+	replaced := new(binance_connector.CancelReplaceResponse)
+	payload := `{"cancelResult":"SUCCESS","newOrderResult":"SUCCESS",
+		"cancelResponse":{"symbol":"BTCUSDT","orderId":1,"executedQty":"` + f.canceledExecutedQty + `","cumulativeQuoteQty":"0","status":"CANCELED"},
+		"newOrderResponse":{"symbol":"BTCUSDT","orderId":2,"origQty":"` + cr.quantity.String() + `","executedQty":"0","cumulativeQuoteQty":"0","status":"NEW"}}`
+	if err := json.Unmarshal([]byte(payload), replaced); err != nil {
+		return nil, err
+	}
+	return replaced, nil
+}
+
+func (f *fakeReplaceAPI) amendOrderKeepPriority(symbol string, newQty Decimal, ao AmendOrder) (*AmendOrderResponse, error) {
+	f.amended = append(f.amended, newQty)
+	amended := new(AmendOrderResponse)
+	amended.AmendedOrder.OrderId = *ao.orderId
+	amended.AmendedOrder.Qty = newQty
+	amended.AmendedOrder.Status = string(OrderStatusNew)
+	return amended, nil
+}
+
+func (f *fakeReplaceAPI) cancelOrder(symbol string, co CancelOrder) (*binance_connector.CancelOrderResponse, error) {
+	f.canceled = append(f.canceled, *co.orderId)
+	return &binance_connector.CancelOrderResponse{Symbol: symbol, OrderId: *co.orderId, ExecutedQty: "0", Status: string(OrderStatusCanceled)}, nil
+}
+
+func TestAlgoReplaceActiveSubtractsFillDuringReplace(t *testing.T) {
+	tests := []struct {
+		name         string
+		executedQty  string
+		wantAmended  string
+		wantCanceled bool
+		wantFilled   string
+	}{
+		{"partial fill shrinks the new order", "0.3", "0.7", false, "0.3"},
+		{"full fill cancels the new order", "1", "", true, "1"},
+		{"no fill keeps the new order", "0", "", false, "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeReplaceAPI{canceledExecutedQty: tt.executedQty}
+			a := newAlgoExecution(api, nil, nil, "BTCUSDT", SideBuy, mustDecimal("1"), "")
+			price := mustDecimal("30000")
+			if err := a.placeChild(OrderTypeLimit, mustDecimal("1"), &price); err != nil {
+				t.Fatal(err)
+			}
+			// 按上次查询的成交 0 改挂 1，撤单时实际已经成交 executedQty
+			if err := a.replaceActive(mustDecimal("1"), mustDecimal("30010")); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantAmended == "" && len(api.amended) != 0 {
+				t.Errorf("amended %v, want no amend", api.amended)
+			}
+			if tt.wantAmended != "" && (len(api.amended) != 1 || api.amended[0].String() != tt.wantAmended) {
+				t.Errorf("amended %v, want [%s]", api.amended, tt.wantAmended)
+			}
+			if canceled := len(api.canceled) == 1 && api.canceled[0] == 2; canceled != tt.wantCanceled {
+				t.Errorf("canceled %v, want new order canceled %v", api.canceled, tt.wantCanceled)
+			}
+			p := a.progress()
+			if !p.FilledQty.Equal(mustDecimal(tt.wantFilled)) {
+				t.Errorf("filled = %s, want %s", p.FilledQty, tt.wantFilled)
+			}
+			// 已成交加挂单数量不超过目标
+			open := Decimal{}
+			if active, ok := a.activeOrder(); ok {
+				open = active.Qty.Sub(active.ExecutedQty)
+			}
+			if total := p.FilledQty.Add(open); total.GreaterThan(mustDecimal("1")) {
+				t.Errorf("filled %s + open %s exceeds target 1", p.FilledQty, open)
+			}
+		})
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// 网格间距
+type GridSpacing string
+
+const (
+	GridArithmetic GridSpacing = "ARITHMETIC" // 等差，每格价差相同
+	GridGeometric  GridSpacing = "GEOMETRIC"  // 等比，每格涨跌幅相同
+)
+
+// 网格配置
+type GridConfig struct {
+	symbol    string
+	lower     Decimal
+	upper     Decimal
+	levels    int // 价格档位数，至少 2 个
+	spacing   GridSpacing
+	quantity  Decimal // 每档下单数量
+	statePath string  // 状态文件，重启后从这里恢复，为空时不保存
+}
+
+// 网格的一档
+type GridLevel struct {
+	Index         int       `json:"index"`
+	Price         Decimal   `json:"price"`
+	Side          OrderSide `json:"side,omitempty"` // 为空表示该档没有挂单
+	OrderId       int64     `json:"orderId,omitempty"`
+	ClientOrderId string    `json:"clientOrderId,omitempty"`
+}
+
+// 持久化的网格状态
+type GridState struct {
+	Symbol         string      `json:"symbol"`
+	Quantity       Decimal     `json:"quantity"`
+	Levels         []GridLevel `json:"levels"`
+	Buys           int         `json:"buys"`
+	Sells          int         `json:"sells"`
+	RealizedProfit Decimal     `json:"realizedProfit"` // 计价币，每次卖出成交按与下一档买价的价差计算
+	UpdatedAt      time.Time   `json:"updatedAt"`
+}
+
+// 网格报告
+type GridReport struct {
+	Symbol         string
+	Buys           int
+	Sells          int
+	OpenBuys       int
+	OpenSells      int
+	RealizedProfit Decimal
+}
+
+// 网格机器人：在区间内每档挂单，买单成交后在上一档挂卖单，卖单成交后在下一档挂买单
+// 成交由 OrderTracker 推送，tracker 需要由用户数据流更新；sync 可以补查遗漏的成交
+// 请求都在 g.mu 之外发出：先在锁内整理要挂的单，解锁后下单，再加锁记录结果
+type GridBot struct {
+	api       TradingAPI
+	registry  *SymbolRegistry
+	tracker   *OrderTracker
+	ids       *ClientOrderIdGenerator
+	config    GridConfig
+	lastPrice func(symbol string) (Decimal, error) // 初始挂单用的现价
+
+	mu        sync.Mutex
+	state     GridState
+	byOrderId map[int64]int // 订单 id -> 档位
+	placing   map[int]bool  // 正在下单的档位
+	inflight  sync.WaitGroup
+	running   bool
+}
+
+// 待挂的一档
+type gridPlacement struct {
+	index int
+	side  OrderSide
+}
+
+func newGridBot(
+	api TradingAPI,
+	registry *SymbolRegistry,
+	tracker *OrderTracker,
+	ids *ClientOrderIdGenerator,
+	config GridConfig,
+	proxyURL string,
+) (*GridBot, error) {
+	if tracker == nil {
+		return nil, fmt.Errorf("grid: tracker is required")
+	}
+	if config.levels < 2 {
+		return nil, fmt.Errorf("grid: at least 2 levels required")
+	}
+	if config.lower.Sign() <= 0 || !config.upper.GreaterThan(config.lower) {
+		return nil, fmt.Errorf("grid: invalid price range %s - %s", config.lower, config.upper)
+	}
+	if config.quantity.Sign() <= 0 {
+		return nil, fmt.Errorf("grid: quantity must be positive")
+	}
+	if config.spacing != GridArithmetic && config.spacing != GridGeometric {
+		return nil, fmt.Errorf("grid: unknown spacing %q", config.spacing)
+	}
+	g := &GridBot{
+		api:       api,
+		registry:  registry,
+		tracker:   tracker,
+		ids:       ids,
+		config:    config,
+		byOrderId: make(map[int64]int),
+		placing:   make(map[int]bool),
+		lastPrice: func(symbol string) (Decimal, error) {
+			ticker, err := getTickersPrice("", "", inputTokens{symbol: &symbol}, proxyURL)
+			if err != nil {
+				return Decimal{}, err
+			}
+			return newDecimalFromString(ticker.Price)
+		},
+	}
+	tracker.subscribe(g.onTransition)
+	return g, nil
+}
+
+// 各档价格，按 tickSize 对齐
+func gridPrices(config GridConfig, tickSize Decimal) []Decimal {
+	prices := make([]Decimal, config.levels)
+	steps := int64(config.levels - 1)
+	if config.spacing == GridArithmetic {
+		step := config.upper.Sub(config.lower).Div(newDecimalFromInt(steps))
+		for i := range prices {
+			prices[i] = config.lower.Add(step.Mul(newDecimalFromInt(int64(i))))
+		}
+	} else {
+		// Decimal 没有开方，等比系数用 float64 计算
+		ratio := math.Pow(config.upper.Div(config.lower).Float64(), 1/float64(steps))
+		for i := range prices {
+			prices[i] = config.lower.Mul(newDecimalFromFloat(math.Pow(ratio, float64(i))))
+		}
+		prices[len(prices)-1] = config.upper
+	}
+	if tickSize.Sign() > 0 {
+		for i := range prices {
+			prices[i] = prices[i].RoundDownToStep(tickSize)
+		}
+	}
+	return prices
+}
+
+// 开始运行：有未完成的挂单时（内存中或状态文件中）恢复并补查成交，否则按当前价格挂初始订单
+// stop(false) 之后再 start 会沿用内存中的档位，不会丢掉挂单
+func (g *GridBot) start() error {
+	g.mu.Lock()
+	if g.running {
+		g.mu.Unlock()
+		return fmt.Errorf("grid: already running")
+	}
+	if len(g.state.Levels) == 0 && g.config.statePath != "" {
+		var state GridState
+		found, err := loadJSONFile(g.config.statePath, &state)
+		if err != nil {
+			g.mu.Unlock()
+			return err
+		}
+		if found {
+			if state.Symbol != g.config.symbol || len(state.Levels) != g.config.levels {
+				g.mu.Unlock()
+				return fmt.Errorf("grid: state file %s does not match config", g.config.statePath)
+			}
+			g.state = state
+		}
+	}
+	g.running = true
+	if g.hasOpenOrdersLocked() {
+		for _, level := range g.state.Levels {
+			if level.OrderId != 0 {
+				g.byOrderId[level.OrderId] = level.Index
+			}
+		}
+		g.mu.Unlock()
+		return g.sync()
+	}
+	g.mu.Unlock()
+
+	lastPrice, err := g.lastPrice(g.config.symbol)
+	if err != nil {
+		g.mu.Lock()
+		g.running = false
+		g.mu.Unlock()
+		return err
+	}
+
+	var tickSize Decimal
+	if g.registry != nil {
+		if symbolInfo, ok := g.registry.symbol(g.config.symbol); ok {
+			if f := symbolInfo.filter(FilterPriceFilter); f != nil {
+				tickSize = f.TickSize
+			}
+		}
+	}
+
+	// 没有挂单时重新划分档位，保留累计的成交统计
+	g.mu.Lock()
+	g.state.Symbol = g.config.symbol
+	g.state.Quantity = g.config.quantity
+	g.state.Levels = g.state.Levels[:0]
+	for i, price := range gridPrices(g.config, tickSize) {
+		g.state.Levels = append(g.state.Levels, GridLevel{Index: i, Price: price})
+	}
+	// 低于现价的档挂买单，高于现价的档挂卖单，与现价重合的档留空
+	var work []gridPlacement
+	for i, level := range g.state.Levels {
+		switch {
+		case level.Price.LessThan(lastPrice):
+			work = append(work, gridPlacement{i, SideBuy})
+		case level.Price.GreaterThan(lastPrice):
+			work = append(work, gridPlacement{i, SideSell})
+		}
+	}
+	err = g.saveLocked()
+	g.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return g.placeAll(work)
+}
+
+func (g *GridBot) hasOpenOrdersLocked() bool {
+	for _, level := range g.state.Levels {
+		if level.OrderId != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// 停止运行，cancelOrders 为 true 时等进行中的下单结束后撤掉所有网格挂单
+func (g *GridBot) stop(cancelOrders bool) error {
+	g.mu.Lock()
+	g.running = false
+	if !cancelOrders {
+		defer g.mu.Unlock()
+		return g.saveLocked()
+	}
+	g.mu.Unlock()
+	g.inflight.Wait()
+
+	g.mu.Lock()
+	var orders []GridLevel
+	for _, level := range g.state.Levels {
+		if level.OrderId != 0 {
+			orders = append(orders, level)
+		}
+	}
+	g.mu.Unlock()
+
+	for _, level := range orders {
+		orderId := level.OrderId
+		_, err := g.api.cancelOrder(g.config.symbol, CancelOrder{orderId: &orderId})
+		g.mu.Lock()
+		if err != nil {
+			g.saveLocked()
+			g.mu.Unlock()
+			return err
+		}
+		// 撤单推送可能已经先到，清空过的档位不再处理
+		if current := &g.state.Levels[level.Index]; current.OrderId == orderId {
+			delete(g.byOrderId, orderId)
+			*current = GridLevel{Index: current.Index, Price: current.Price}
+		}
+		g.mu.Unlock()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.saveLocked()
+}
+
+// 依次挂单，立即成交或已经到终态的单产生的后续挂单也在这里处理
+func (g *GridBot) placeAll(work []gridPlacement) error {
+	for len(work) > 0 {
+		next := work[0]
+		work = work[1:]
+		i, side := next.index, next.side
+
+		g.mu.Lock()
+		level := g.state.Levels[i]
+		if !g.running || level.OrderId != 0 || g.placing[i] {
+			g.mu.Unlock()
+			continue
+		}
+		price, quantity := level.Price, g.config.quantity
+		timeInForce := TimeInForceGTC
+		no := NewOrder{quantity: &quantity, price: &price, timeInForce: &timeInForce}
+		if g.registry != nil {
+			var err error
+			if no, err = g.registry.checkOrder(g.config.symbol, side, OrderTypeLimit, no, FilterCheck{roundQuantity: true}); err != nil {
+				g.mu.Unlock()
+				return err
+			}
+		}
+		if g.ids != nil {
+			no = g.ids.assignNewOrder(no)
+		}
+		g.placing[i] = true
+		g.inflight.Add(1)
+		g.mu.Unlock()
+
+		order, err := g.api.createNewOrder(g.config.symbol, side, OrderTypeLimit, no)
+
+		g.mu.Lock()
+		delete(g.placing, i)
+		if err != nil {
+			g.saveLocked()
+			g.mu.Unlock()
+			g.inflight.Done()
+			return err
+		}
+		current := &g.state.Levels[i]
+		current.Side = side
+		current.OrderId = order.OrderId
+		current.ClientOrderId = order.ClientOrderId
+		g.byOrderId[order.OrderId] = i
+		if status := OrderStatus(order.Status); status.isFinal() {
+			// 挂单时立即成交
+			work = append(work, g.applyFinalLocked(i, status)...)
+		} else if tracked, ok := g.tracker.get(order.OrderId); ok && tracked.Status.isFinal() {
+			// 成交推送可能先于下单返回到达
+			work = append(work, g.applyFinalLocked(i, tracked.Status)...)
+		}
+		g.saveLocked()
+		g.mu.Unlock()
+		g.inflight.Done()
+	}
+	return nil
+}
+
+// 第 i 档成交后返回相邻档要挂的反向单
+func (g *GridBot) onFillLocked(i int) []gridPlacement {
+	level := &g.state.Levels[i]
+	side := level.Side
+	delete(g.byOrderId, level.OrderId)
+	*level = GridLevel{Index: level.Index, Price: level.Price}
+
+	if side == SideBuy {
+		g.state.Buys++
+		if i+1 < len(g.state.Levels) && g.running {
+			return []gridPlacement{{i + 1, SideSell}}
+		}
+		return nil
+	}
+	g.state.Sells++
+	if i > 0 {
+		spread := level.Price.Sub(g.state.Levels[i-1].Price)
+		g.state.RealizedProfit = g.state.RealizedProfit.Add(spread.Mul(g.state.Quantity))
+		if g.running {
+			return []gridPlacement{{i - 1, SideBuy}}
+		}
+	}
+	return nil
+}
+
+// OrderTracker 的订单变化
+func (g *GridBot) onTransition(transition OrderTransition) {
+	if !transition.To.isFinal() {
+		return
+	}
+	g.mu.Lock()
+	i, ok := g.byOrderId[transition.Order.OrderId]
+	if !ok {
+		g.mu.Unlock()
+		return
+	}
+	work := g.applyFinalLocked(i, transition.To)
+	g.saveLocked()
+	g.mu.Unlock()
+	if err := g.placeAll(work); err != nil {
+		fmt.Println("grid:", err)
+	}
+}
+
+// 挂单到终态，返回需要在锁外挂出的单
+func (g *GridBot) applyFinalLocked(i int, status OrderStatus) []gridPlacement {
+	if status == OrderStatusFilled {
+		return g.onFillLocked(i)
+	}
+	// 被撤单或过期时按原方向重新挂
+	level := &g.state.Levels[i]
+	side := level.Side
+	delete(g.byOrderId, level.OrderId)
+	*level = GridLevel{Index: level.Index, Price: level.Price}
+	if g.running {
+		return []gridPlacement{{i, side}}
+	}
+	return nil
+}
+
+// 用 getQueryOrder 补查所有网格挂单，处理推送遗漏的成交
+func (g *GridBot) sync() error {
+	g.mu.Lock()
+	var orders []GridLevel
+	for _, level := range g.state.Levels {
+		if level.OrderId != 0 {
+			orders = append(orders, level)
+		}
+	}
+	g.mu.Unlock()
+
+	var work []gridPlacement
+	for _, level := range orders {
+		orderId := level.OrderId
+		order, err := g.api.getQueryOrder(g.config.symbol, QueryOrder{orderId: &orderId})
+		if err != nil {
+			g.placeAll(work)
+			return err
+		}
+		status := OrderStatus(order.Status)
+		if !status.isFinal() {
+			continue
+		}
+		g.mu.Lock()
+		// 推送可能已经处理过这一档
+		if g.state.Levels[level.Index].OrderId == orderId {
+			work = append(work, g.applyFinalLocked(level.Index, status)...)
+		}
+		g.mu.Unlock()
+	}
+	g.mu.Lock()
+	err := g.saveLocked()
+	g.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return g.placeAll(work)
+}
+
+func (g *GridBot) saveLocked() error {
+	if g.config.statePath == "" {
+		return nil
+	}
+	g.state.UpdatedAt = time.Now()
+	return saveJSONFile(g.config.statePath, g.state)
+}
+
+// 网格收益和挂单统计
+func (g *GridBot) report() GridReport {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	report := GridReport{
+		Symbol:         g.state.Symbol,
+		Buys:           g.state.Buys,
+		Sells:          g.state.Sells,
+		RealizedProfit: g.state.RealizedProfit,
+	}
+	for _, level := range g.state.Levels {
+		switch level.Side {
+		case SideBuy:
+			report.OpenBuys++
+		case SideSell:
+			report.OpenSells++
+		}
+	}
+	return report
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"binance_connector"
+	"testing"
+)
+
+// 挂单都停在 NEW，撤单总是成功
+type fakeGridAPI struct {
+	TradingAPI
+	placed   []CreateOrderResponse
+	canceled []int64
+}
+
+func (f *fakeGridAPI) createNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*CreateOrderResponse, error) {
+	order := CreateOrderResponse{
+		Symbol:  symbol,
+		OrderId: int64(len(f.placed) + 1),
+		Side:    string(side),
+		Type:    string(orderType),
+		Price:   *no.price,
+		OrigQty: *no.quantity,
+		Status:  string(OrderStatusNew),
+	}
+	f.placed = append(f.placed, order)
+	return &order, nil
+}
+
+func (f *fakeGridAPI) cancelOrder(symbol string, co CancelOrder) (*binance_connector.CancelOrderResponse, error) {
+	f.canceled = append(f.canceled, *co.orderId)
+	return &binance_connector.CancelOrderResponse{Symbol: symbol, OrderId: *co.orderId, Status: string(OrderStatusCanceled)}, nil
+}
+
+func (f *fakeGridAPI) getQueryOrder(symbol string, qo QueryOrder) (*binance_connector.GetOrderResponse, error) {
+	return &binance_connector.GetOrderResponse{Symbol: symbol, OrderId: *qo.orderId, Status: string(OrderStatusNew)}, nil
+}
+
+func testGridBot(t *testing.T, api TradingAPI, tracker *OrderTracker) *GridBot {
+	t.Helper()
+	g, err := newGridBot(api, testSymbolRegistry(testSymbolDetail()), tracker, nil, GridConfig{
+		symbol: "BTCUSDT", lower: mustDecimal("29000"), upper: mustDecimal("31000"),
+		levels: 5, spacing: GridArithmetic, quantity: mustDecimal("0.001"),
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.lastPrice = func(string) (Decimal, error) { return mustDecimal("30000"), nil }
+	return g
+}
+
+func TestGridBotRestartKeepsOrders(t *testing.T) {
+	api := &fakeGridAPI{}
+	tracker := newOrderTracker()
+	g := testGridBot(t, api, tracker)
+	if err := g.start(); err != nil {
+		t.Fatal(err)
+	}
+	if report := g.report(); len(api.placed) != 4 || report.OpenBuys != 2 || report.OpenSells != 2 {
+		t.Fatalf("placed %d orders, report %+v, want 2 buys and 2 sells", len(api.placed), report)
+	}
+
+	if err := g.stop(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.start(); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.placed) != 4 {
+		t.Errorf("restart placed %d orders, want the original 4", len(api.placed))
+	}
+
+	// 29500 的买单成交后在 30000 挂卖单
+	buy := api.placed[1]
+	tracker.onExecutionReport(&ExecutionReportEvent{
+		Symbol: "BTCUSDT", OrderId: buy.OrderId, Side: string(SideBuy), OrderType: string(OrderTypeLimit),
+		Quantity: "0.001", Price: "29500", CumulativeFilledQty: "0.001", CumulativeQuoteQty: "29.5",
+		ExecutionType: "TRADE", OrderStatus: string(OrderStatusFilled), TradeId: 1,
+		LastExecutedPrice: "29500", LastExecutedQty: "0.001",
+	})
+	last := api.placed[len(api.placed)-1]
+	if len(api.placed) != 5 || last.Side != string(SideSell) || last.Price.String() != "30000" {
+		t.Errorf("after fill placed %+v, want a sell at 30000", last)
+	}
+
+	if err := g.stop(true); err != nil {
+		t.Fatal(err)
+	}
+	if report := g.report(); len(api.canceled) != 4 || report.OpenBuys != 0 || report.OpenSells != 0 {
+		t.Errorf("canceled %v, report %+v, want all 4 open orders canceled", api.canceled, report)
+	}
+}
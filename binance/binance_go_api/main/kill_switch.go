@@ -0,0 +1,331 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"binance_connector"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 紧急撤单的操作类型
+const (
+	killActionCancel  = "CANCEL"
+	killActionFlatten = "FLATTEN"
+)
+
+// 需要紧急撤单的账户
+type KillSwitchAccount struct {
+	name      string
+	apiKey    string
+	secretKey string
+	proxyURL  string
+}
+
+// 紧急撤单配置
+type KillSwitchConfig struct {
+	accounts   []KillSwitchAccount
+	flatten    bool            // 撤单后是否把持仓市价卖成 quoteAsset
+	quoteAsset string          // 平仓使用的计价币，例如 USDT
+	registry   *SymbolRegistry // 平仓时用来查找交易对和对齐数量，flatten 为 true 时必填
+	httpSecret string          // HTTP 触发需要在 KILL_SWITCH_SECRET_HEADER 中带上的密钥，为空时不能启动 HTTP 触发
+}
+
+// 单个订单或单笔平仓的结果
+type KillSwitchResult struct {
+	Account       string `json:"account"`
+	Action        string `json:"action"`
+	Symbol        string `json:"symbol"`
+	OrderId       int64  `json:"orderId,omitempty"`
+	ClientOrderId string `json:"clientOrderId,omitempty"`
+	Status        string `json:"status,omitempty"`
+	Quantity      string `json:"quantity,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// 一次触发的完整报告
+type KillSwitchReport struct {
+	Reason     string             `json:"reason"`
+	StartTime  time.Time          `json:"startTime"`
+	FinishTime time.Time          `json:"finishTime"`
+	Results    []KillSwitchResult `json:"results"`
+}
+
+// 失败的条目数
+func (r *KillSwitchReport) failures() int {
+	failures := 0
+	for _, result := range r.Results {
+		if result.Error != "" {
+			failures++
+		}
+	}
+	return failures
+}
+
+// 紧急撤单开关：并发撤销所有账户所有交易对的挂单，可选平仓
+// 可以直接调用 trigger，也可以通过 SIGUSR1 或本地 HTTP 请求触发
+type KillSwitch struct {
+	config KillSwitchConfig
+
+	triggerMu sync.Mutex // 同一时间只允许一次触发
+	mu        sync.Mutex // 保护 server 和 onReport
+	server    *http.Server
+	onReport  func(report *KillSwitchReport)
+}
+
+func newKillSwitch(config KillSwitchConfig) (*KillSwitch, error) {
+	if config.flatten && (config.registry == nil || config.quoteAsset == "") {
+		return nil, fmt.Errorf("kill switch: flatten requires registry and quoteAsset")
+	}
+	return &KillSwitch{config: config}, nil
+}
+
+// 通过信号或 HTTP 触发后的回调，用于记录或告警
+func (k *KillSwitch) setOnReport(onReport func(report *KillSwitchReport)) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onReport = onReport
+}
+
+// 执行紧急撤单，返回每个订单的结果；onReport 在锁外调用，回调中可以再调用 setOnReport 等方法
+func (k *KillSwitch) trigger(reason string) *KillSwitchReport {
+	k.triggerMu.Lock()
+	report := k.cancelAll(reason)
+	k.triggerMu.Unlock()
+
+	k.mu.Lock()
+	onReport := k.onReport
+	k.mu.Unlock()
+	if onReport != nil {
+		onReport(report)
+	}
+	return report
+}
+
+// 撤销所有挂单，需要持有 k.triggerMu
+func (k *KillSwitch) cancelAll(reason string) *KillSwitchReport {
+
+	report := &KillSwitchReport{Reason: reason, StartTime: time.Now()}
+	limiter := time.NewTicker(time.Second / initConfig.KILL_SWITCH_REQUESTS_PER_SEC)
+	defer limiter.Stop()
+	sem := make(chan struct{}, initConfig.KILL_SWITCH_CONCURRENCY)
+
+	var (
+		resultsMu sync.Mutex
+		wg        sync.WaitGroup
+	)
+	record := func(results ...KillSwitchResult) {
+		resultsMu.Lock()
+		report.Results = append(report.Results, results...)
+		resultsMu.Unlock()
+	}
+	// 限制并发和请求速率
+	run := func(task func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			<-limiter.C
+			task()
+		}()
+	}
+
+	for _, account := range k.config.accounts {
+		account := account
+		openOrders, err := getCurrentOpenOrders(account.apiKey, account.secretKey, "", time.Now().UnixMilli(), account.proxyURL)
+		if err != nil {
+			record(KillSwitchResult{Account: account.name, Action: killActionCancel, Error: err.Error()})
+			continue
+		}
+		bySymbol := make(map[string][]*binance_connector.NewOpenOrdersResponse)
+		for _, order := range openOrders {
+			bySymbol[order.Symbol] = append(bySymbol[order.Symbol], order)
+		}
+		for symbol, orders := range bySymbol {
+			symbol, orders := symbol, orders
+			run(func() { record(k.cancelSymbol(account, symbol, orders, limiter)...) })
+		}
+	}
+	wg.Wait()
+
+	if k.config.flatten {
+		for _, account := range k.config.accounts {
+			account := account
+			run(func() { record(k.flattenAccount(account)...) })
+		}
+		wg.Wait()
+	}
+
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		a, b := report.Results[i], report.Results[j]
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		if a.Action != b.Action {
+			return a.Action == killActionCancel
+		}
+		return a.Symbol < b.Symbol
+	})
+	report.FinishTime = time.Now()
+	return report
+}
+
+// 先用 DELETE /api/v3/openOrders 一次撤销，失败时逐个撤销
+func (k *KillSwitch) cancelSymbol(
+	account KillSwitchAccount,
+	symbol string,
+	orders []*binance_connector.NewOpenOrdersResponse,
+	limiter *time.Ticker,
+) []KillSwitchResult {
+	var results []KillSwitchResult
+	canceled, err := cancelSymbolAllOpenOrders(account.apiKey, account.secretKey, symbol, time.Now().UnixMilli(), account.proxyURL)
+	if err == nil {
+		for _, order := range canceled {
+			results = append(results, KillSwitchResult{
+				Account:       account.name,
+				Action:        killActionCancel,
+				Symbol:        symbol,
+				OrderId:       order.OrderId,
+				ClientOrderId: order.OrigClientOrderId,
+				Status:        order.Status,
+			})
+		}
+		return results
+	}
+
+	for _, order := range orders {
+		<-limiter.C
+		orderId := order.OrderId
+		result := KillSwitchResult{
+			Account:       account.name,
+			Action:        killActionCancel,
+			Symbol:        symbol,
+			OrderId:       orderId,
+			ClientOrderId: order.ClientOrderId,
+		}
+		cancel, err := cancelOrder(account.apiKey, account.secretKey, symbol, CancelOrder{orderId: &orderId}, account.proxyURL)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Status = cancel.Status
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// 把除 quoteAsset 外的可用余额市价卖出，数量按 LOT_SIZE/MARKET_LOT_SIZE 向下对齐
+func (k *KillSwitch) flattenAccount(account KillSwitchAccount) []KillSwitchResult {
+	accountInformation, err := getAccountInformation(account.apiKey, account.secretKey, time.Now().UnixMilli(), AccountInformation{omitZeroBalances: true}, account.proxyURL)
+	if err != nil {
+		return []KillSwitchResult{{Account: account.name, Action: killActionFlatten, Error: err.Error()}}
+	}
+
+	var results []KillSwitchResult
+	for _, balance := range accountInformation.Balances {
+		free := decimalOrZero(balance.Free)
+		if balance.Asset == k.config.quoteAsset || free.IsZero() {
+			continue
+		}
+		symbol := balance.Asset + k.config.quoteAsset
+		result := KillSwitchResult{Account: account.name, Action: killActionFlatten, Symbol: symbol}
+		if _, ok := k.config.registry.symbol(symbol); !ok {
+			result.Error = fmt.Sprintf("no %s market for %s", k.config.quoteAsset, balance.Asset)
+			results = append(results, result)
+			continue
+		}
+
+		no, err := k.config.registry.checkOrder(symbol, SideSell, OrderTypeMarket, NewOrder{quantity: &free}, FilterCheck{roundQuantity: true})
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		result.Quantity = no.quantity.String()
+		newOrder, err := createNewOrder(account.apiKey, account.secretKey, symbol, SideSell, OrderTypeMarket, time.Now().UnixMilli(), no, account.proxyURL)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.OrderId = newOrder.OrderId
+			result.ClientOrderId = newOrder.ClientOrderId
+			result.Status = newOrder.Status
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// 在本地地址上监听 POST /kill，返回 JSON 格式的报告；addr 为空时使用 KILL_SWITCH_HTTP_ADDR
+// 请求必须在 KILL_SWITCH_SECRET_HEADER 中带上 httpSecret，浏览器跨域的简单请求无法设置这个头
+func (k *KillSwitch) serveHTTP(addr string) error {
+	if k.config.httpSecret == "" {
+		return fmt.Errorf("kill switch: httpSecret is required to serve http")
+	}
+	if addr == "" {
+		addr = initConfig.KILL_SWITCH_HTTP_ADDR
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/kill", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !k.authorized(r.Header.Get(initConfig.KILL_SWITCH_SECRET_HEADER)) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		reason := r.URL.Query().Get("reason")
+		if reason == "" {
+			reason = "http"
+		}
+		report := k.trigger(reason)
+		w.Header().Set("Content-Type", "application/json")
+		if report.failures() > 0 {
+			w.WriteHeader(http.StatusMultiStatus)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	k.mu.Lock()
+	if k.server != nil {
+		k.mu.Unlock()
+		return fmt.Errorf("kill switch: http already serving on %s", k.server.Addr)
+	}
+	k.server = server
+	k.mu.Unlock()
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Println("kill switch:", err)
+		}
+	}()
+	return nil
+}
+
+// 常量时间比较密钥，先取哈希避免泄露长度
+func (k *KillSwitch) authorized(secret string) bool {
+	got, want := sha256.Sum256([]byte(secret)), sha256.Sum256([]byte(k.config.httpSecret))
+	return secret != "" && subtle.ConstantTimeCompare(got[:], want[:]) == 1
+}
+
+// 关闭 HTTP 触发
+func (k *KillSwitch) stopHTTP() error {
+	k.mu.Lock()
+	server := k.server
+	k.server = nil
+	k.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), initConfig.TIMEOUT)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 收到 SIGUSR1 时触发紧急撤单，返回的函数用于取消监听
+func (k *KillSwitch) listenSignal() (func(), error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-signals:
+				report := k.trigger(sig.String())
+				fmt.Printf("kill switch: %d results, %d failures\n", len(report.Results), report.failures())
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}, nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "fmt"
+
+// windows 没有 SIGUSR1，请使用 HTTP 或直接调用 trigger
+func (k *KillSwitch) listenSignal() (func(), error) {
+	return nil, fmt.Errorf("kill switch: SIGUSR1 is not supported on windows")
+}
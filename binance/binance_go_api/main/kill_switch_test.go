@@ -0,0 +1,87 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestKillSwitchHTTPRequiresSecret(t *testing.T) {
+	k, err := newKillSwitch(KillSwitchConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.serveHTTP("127.0.0.1:0"); err == nil {
+		k.stopHTTP()
+		t.Fatal("serveHTTP should refuse to start without httpSecret")
+	}
+
+	k, _ = newKillSwitch(KillSwitchConfig{httpSecret: "s3cret"})
+	tests := []struct {
+		secret string
+		want   bool
+	}{
+		{"s3cret", true},
+		{"", false},
+		{"s3cre", false},
+		{"s3cret ", false},
+	}
+	for _, tt := range tests {
+		if got := k.authorized(tt.secret); got != tt.want {
+			t.Errorf("authorized(%q) = %v, want %v", tt.secret, got, tt.want)
+		}
+	}
+}
+
+func TestKillSwitchHTTPRejectsUnauthorized(t *testing.T) {
+	k, _ := newKillSwitch(KillSwitchConfig{httpSecret: "s3cret"})
+	if err := k.serveHTTP("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer k.stopHTTP()
+
+	handler := k.server.Handler
+	for _, secret := range []string{"", "wrong"} {
+		req := httptest.NewRequest(http.MethodPost, "/kill", nil)
+		if secret != "" {
+			req.Header.Set(initConfig.KILL_SWITCH_SECRET_HEADER, secret)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("secret %q: status = %d, want %d", secret, rec.Code, http.StatusUnauthorized)
+		}
+	}
+
+	// 没有账户时触发只返回空报告
+	req := httptest.NewRequest(http.MethodPost, "/kill?reason=test", nil)
+	req.Header.Set(initConfig.KILL_SWITCH_SECRET_HEADER, "s3cret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("authorized status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestKillSwitchReportCallbackCanReenter(t *testing.T) {
+	k, err := newKillSwitch(KillSwitchConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan *KillSwitchReport, 1)
+	k.setOnReport(func(report *KillSwitchReport) {
+		k.setOnReport(nil)
+		done <- report
+	})
+	go k.trigger("test")
+	select {
+	case report := <-done:
+		if report.Reason != "test" {
+			t.Errorf("reason = %q, want test", report.Reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("onReport calling back into the kill switch deadlocked")
+	}
+}
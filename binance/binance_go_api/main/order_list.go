@@ -0,0 +1,396 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// 订单列表中的一条腿
+// OCO 的两条腿共用 side/quantity；OTO 的 working/pending 各自带 side/quantity
+type OrderListLeg struct {
+	orderType     OrderType
+	side          *OrderSide
+	quantity      *Decimal
+	clientOrderId *string
+	price         *Decimal
+	stopPrice     *Decimal
+	trailingDelta *int
+	timeInForce   *TimeInForce
+	icebergQty    *Decimal
+	strategyId    *int
+	strategyType  *int
+}
+
+// 新 OCO 订单，above 为高于市价的一条腿，below 为低于市价的一条腿
+type NewOCO struct {
+	listClientOrderId       *string
+	above                   OrderListLeg
+	below                   OrderListLeg
+	newOrderRespType        *NewOrderRespType
+	selfTradePreventionMode *STPMode
+	recvWindow              *int
+}
+
+// 新 OTO 订单，working 成交后才会挂出 pending
+type NewOTO struct {
+	listClientOrderId       *string
+	working                 OrderListLeg
+	pending                 OrderListLeg
+	newOrderRespType        *NewOrderRespType
+	selfTradePreventionMode *STPMode
+	recvWindow              *int
+}
+
+// 新 OTOCO 订单，working 成交后挂出由 pendingAbove/pendingBelow 组成的 OCO
+type NewOTOCO struct {
+	listClientOrderId       *string
+	working                 OrderListLeg
+	pendingSide             OrderSide
+	pendingQuantity         Decimal
+	pendingAbove            OrderListLeg
+	pendingBelow            *OrderListLeg
+	newOrderRespType        *NewOrderRespType
+	selfTradePreventionMode *STPMode
+	recvWindow              *int
+}
+
+// 取消订单列表
+type CancelOrderList struct {
+	orderListId       *int64
+	listClientOrderId *string
+	newClientOrderId  *string
+	recvWindow        *int
+}
+
+// 查询单个订单列表
+type QueryOrderList struct {
+	orderListId       *int64
+	origClientOrderId *string
+	recvWindow        *int
+}
+
+// 查询所有订单列表
+type AllOrderLists struct {
+	fromId     *int64
+	startTime  *uint64
+	endTime    *uint64
+	limit      *int
+	recvWindow *int
+}
+
+// 订单列表状态，下单和撤单时还会带上每条腿的 orderReports
+type OrderListResponse struct {
+	OrderListId       int64             `json:"orderListId"`
+	ContingencyType   string            `json:"contingencyType"`
+	ListStatusType    string            `json:"listStatusType"`
+	ListOrderStatus   string            `json:"listOrderStatus"`
+	ListClientOrderId string            `json:"listClientOrderId"`
+	TransactionTime   uint64            `json:"transactionTime"`
+	Symbol            string            `json:"symbol"`
+	Orders            []OrderListOrder  `json:"orders"`
+	OrderReports      []OrderListReport `json:"orderReports,omitempty"`
+}
+
+type OrderListOrder struct {
+	Symbol        string `json:"symbol"`
+	OrderId       int64  `json:"orderId"`
+	ClientOrderId string `json:"clientOrderId"`
+}
+
+// 订单列表中每条腿的回报
+type OrderListReport struct {
+	Symbol                  string  `json:"symbol"`
+	OrderId                 int64   `json:"orderId"`
+	OrderListId             int64   `json:"orderListId"`
+	ClientOrderId           string  `json:"clientOrderId"`
+	OrigClientOrderId       string  `json:"origClientOrderId,omitempty"`
+	TransactTime            uint64  `json:"transactTime"`
+	Price                   Decimal `json:"price"`
+	OrigQty                 Decimal `json:"origQty"`
+	ExecutedQty             Decimal `json:"executedQty"`
+	CumulativeQuoteQty      Decimal `json:"cummulativeQuoteQty"`
+	Status                  string  `json:"status"`
+	TimeInForce             string  `json:"timeInForce"`
+	Type                    string  `json:"type"`
+	Side                    string  `json:"side"`
+	StopPrice               Decimal `json:"stopPrice"`
+	IcebergQty              Decimal `json:"icebergQty"`
+	TrailingDelta           int64   `json:"trailingDelta,omitempty"`
+	WorkingTime             int64   `json:"workingTime,omitempty"`
+	SelfTradePreventionMode string  `json:"selfTradePreventionMode"`
+}
+
+// 把一条腿按前缀写入参数，如 above -> aboveType, abovePrice
+func setOrderListLegParams(params map[string]string, prefix string, leg OrderListLeg) {
+	params[prefix+"Type"] = string(leg.orderType)
+	if leg.side != nil {
+		params[prefix+"Side"] = string(*leg.side)
+	}
+	if leg.quantity != nil {
+		params[prefix+"Quantity"] = leg.quantity.String()
+	}
+	if leg.clientOrderId != nil {
+		params[prefix+"ClientOrderId"] = *leg.clientOrderId
+	}
+	if leg.price != nil {
+		params[prefix+"Price"] = leg.price.String()
+	}
+	if leg.stopPrice != nil {
+		params[prefix+"StopPrice"] = leg.stopPrice.String()
+	}
+	if leg.trailingDelta != nil {
+		params[prefix+"TrailingDelta"] = strconv.Itoa(*leg.trailingDelta)
+	}
+	if leg.timeInForce != nil {
+		params[prefix+"TimeInForce"] = string(*leg.timeInForce)
+	}
+	if leg.icebergQty != nil {
+		params[prefix+"IcebergQty"] = leg.icebergQty.String()
+	}
+	if leg.strategyId != nil {
+		params[prefix+"StrategyId"] = strconv.Itoa(*leg.strategyId)
+	}
+	if leg.strategyType != nil {
+		params[prefix+"StrategyType"] = strconv.Itoa(*leg.strategyType)
+	}
+}
+
+func setOrderListCommonParams(
+	params map[string]string,
+	listClientOrderId *string,
+	newOrderRespType *NewOrderRespType,
+	selfTradePreventionMode *STPMode,
+	recvWindow *int,
+) {
+	if listClientOrderId != nil {
+		params["listClientOrderId"] = *listClientOrderId
+	}
+	if newOrderRespType != nil {
+		params["newOrderRespType"] = string(*newOrderRespType)
+	}
+	if selfTradePreventionMode != nil {
+		params["selfTradePreventionMode"] = string(*selfTradePreventionMode)
+	}
+	if recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*recvWindow)
+	}
+}
+
+// 创建 OCO 订单 - POST /api/v3/orderList/oco
+func createNewOCO(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	side OrderSide,
+	quantity Decimal,
+	timestamp int64,
+	oco NewOCO,
+	proxyURL string,
+) (*OrderListResponse, error) {
+	params := map[string]string{
+		"symbol":   symbol,
+		"side":     string(side),
+		"quantity": quantity.String(),
+	}
+	setOrderListLegParams(params, "above", oco.above)
+	setOrderListLegParams(params, "below", oco.below)
+	setOrderListCommonParams(params, oco.listClientOrderId, oco.newOrderRespType,
+		oco.selfTradePreventionMode, oco.recvWindow)
+	if isDryRun() {
+		// 这个接口没有测试版本，dry-run 时只打印
+		fmt.Println("[dry-run] POST "+initConfig.PATH_ORDER_LIST_OCO, sortedQuery(params))
+		return &OrderListResponse{Symbol: symbol, ContingencyType: "OCO", ListStatusType: dryRunResult, ListOrderStatus: dryRunResult}, nil
+	}
+
+	newOCO := new(OrderListResponse)
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodPost, initConfig.PATH_ORDER_LIST_OCO, params, proxyURL, newOCO)
+	if err != nil {
+		return nil, err
+	}
+	return newOCO, nil
+}
+
+// 创建 OTO 订单 - POST /api/v3/orderList/oto
+func createNewOTO(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	timestamp int64,
+	oto NewOTO,
+	proxyURL string,
+) (*OrderListResponse, error) {
+	params := map[string]string{"symbol": symbol}
+	setOrderListLegParams(params, "working", oto.working)
+	setOrderListLegParams(params, "pending", oto.pending)
+	setOrderListCommonParams(params, oto.listClientOrderId, oto.newOrderRespType,
+		oto.selfTradePreventionMode, oto.recvWindow)
+	if isDryRun() {
+		fmt.Println("[dry-run] POST "+initConfig.PATH_ORDER_LIST_OTO, sortedQuery(params))
+		return &OrderListResponse{Symbol: symbol, ContingencyType: "OTO", ListStatusType: dryRunResult, ListOrderStatus: dryRunResult}, nil
+	}
+
+	newOTO := new(OrderListResponse)
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodPost, initConfig.PATH_ORDER_LIST_OTO, params, proxyURL, newOTO)
+	if err != nil {
+		return nil, err
+	}
+	return newOTO, nil
+}
+
+// 创建 OTOCO 订单 - POST /api/v3/orderList/otoco
+func createNewOTOCO(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	timestamp int64,
+	otoco NewOTOCO,
+	proxyURL string,
+) (*OrderListResponse, error) {
+	params := map[string]string{
+		"symbol":          symbol,
+		"pendingSide":     string(otoco.pendingSide),
+		"pendingQuantity": otoco.pendingQuantity.String(),
+	}
+	setOrderListLegParams(params, "working", otoco.working)
+	setOrderListLegParams(params, "pendingAbove", otoco.pendingAbove)
+	if otoco.pendingBelow != nil {
+		setOrderListLegParams(params, "pendingBelow", *otoco.pendingBelow)
+	}
+	setOrderListCommonParams(params, otoco.listClientOrderId, otoco.newOrderRespType,
+		otoco.selfTradePreventionMode, otoco.recvWindow)
+	if isDryRun() {
+		fmt.Println("[dry-run] POST "+initConfig.PATH_ORDER_LIST_OTOCO, sortedQuery(params))
+		return &OrderListResponse{Symbol: symbol, ContingencyType: "OTO", ListStatusType: dryRunResult, ListOrderStatus: dryRunResult}, nil
+	}
+
+	newOTOCO := new(OrderListResponse)
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodPost, initConfig.PATH_ORDER_LIST_OTOCO, params, proxyURL, newOTOCO)
+	if err != nil {
+		return nil, err
+	}
+	return newOTOCO, nil
+}
+
+// 取消整个订单列表 - DELETE /api/v3/orderList
+func cancelOrderList(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	col CancelOrderList,
+	proxyURL string,
+) (*OrderListResponse, error) {
+	params := map[string]string{"symbol": symbol}
+	if col.orderListId != nil {
+		params["orderListId"] = strconv.FormatInt(*col.orderListId, 10)
+	}
+	if col.listClientOrderId != nil {
+		params["listClientOrderId"] = *col.listClientOrderId
+	}
+	if col.newClientOrderId != nil {
+		params["newClientOrderId"] = *col.newClientOrderId
+	}
+	if col.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*col.recvWindow)
+	}
+	if isDryRun() {
+		fmt.Println("[dry-run] DELETE "+initConfig.PATH_ORDER_LIST, sortedQuery(params))
+		response := &OrderListResponse{Symbol: symbol, ListStatusType: dryRunResult, ListOrderStatus: dryRunResult}
+		if col.orderListId != nil {
+			response.OrderListId = *col.orderListId
+		}
+		if col.listClientOrderId != nil {
+			response.ListClientOrderId = *col.listClientOrderId
+		}
+		return response, nil
+	}
+
+	cancelList := new(OrderListResponse)
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodDelete, initConfig.PATH_ORDER_LIST, params, proxyURL, cancelList)
+	if err != nil {
+		return nil, err
+	}
+	return cancelList, nil
+}
+
+// 查询单个订单列表 - GET /api/v3/orderList
+func getQueryOrderList(
+	thisApiKey,
+	thisSecretKey string,
+	timestamp int64,
+	qol QueryOrderList,
+	proxyURL string,
+) (*OrderListResponse, error) {
+	params := map[string]string{}
+	if qol.orderListId != nil {
+		params["orderListId"] = strconv.FormatInt(*qol.orderListId, 10)
+	}
+	if qol.origClientOrderId != nil {
+		params["origClientOrderId"] = *qol.origClientOrderId
+	}
+	if qol.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*qol.recvWindow)
+	}
+
+	orderList := new(OrderListResponse)
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodGet, initConfig.PATH_ORDER_LIST, params, proxyURL, orderList)
+	if err != nil {
+		return nil, err
+	}
+	return orderList, nil
+}
+
+// 查询所有订单列表 - GET /api/v3/allOrderList
+func getAllOrderLists(
+	thisApiKey,
+	thisSecretKey string,
+	timestamp int64,
+	aol AllOrderLists,
+	proxyURL string,
+) ([]*OrderListResponse, error) {
+	params := map[string]string{}
+	if aol.fromId != nil {
+		params["fromId"] = strconv.FormatInt(*aol.fromId, 10)
+	}
+	if aol.startTime != nil {
+		params["startTime"] = strconv.FormatUint(*aol.startTime, 10)
+	}
+	if aol.endTime != nil {
+		params["endTime"] = strconv.FormatUint(*aol.endTime, 10)
+	}
+	if aol.limit != nil {
+		params["limit"] = strconv.Itoa(*aol.limit)
+	}
+	if aol.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*aol.recvWindow)
+	}
+
+	var orderLists []*OrderListResponse
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodGet, initConfig.PATH_ALL_ORDER_LIST, params, proxyURL, &orderLists)
+	if err != nil {
+		return nil, err
+	}
+	return orderLists, nil
+}
+
+// 查询当前挂着的订单列表 - GET /api/v3/openOrderList
+func getOpenOrderLists(
+	thisApiKey,
+	thisSecretKey string,
+	timestamp int64,
+	recvWindow *int,
+	proxyURL string,
+) ([]*OrderListResponse, error) {
+	params := map[string]string{}
+	if recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*recvWindow)
+	}
+
+	var orderLists []*OrderListResponse
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodGet, initConfig.PATH_OPEN_ORDER_LIST, params, proxyURL, &orderLists)
+	if err != nil {
+		return nil, err
+	}
+	return orderLists, nil
+}
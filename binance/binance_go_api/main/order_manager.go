@@ -0,0 +1,752 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// 托管订单的状态
+type ManagedStatus string
+
+const (
+	ManagedPending  ManagedStatus = "PENDING"  // 括号单等待开仓成交
+	ManagedActive   ManagedStatus = "ACTIVE"   // 跟踪止损生效中/括号单的 OCO 已挂出
+	ManagedClosed   ManagedStatus = "CLOSED"   // 已平仓
+	ManagedCanceled ManagedStatus = "CANCELED" // 主动取消或开仓未成交
+	ManagedFailed   ManagedStatus = "FAILED"
+)
+
+// 跟踪止损配置，percent 和 distance 二选一
+type TrailingStopConfig struct {
+	symbol          string
+	side            OrderSide // 平仓方向，多头持仓为 SELL
+	quantity        Decimal
+	percent         *Decimal // 按比例回撤，例如 0.02
+	distance        *Decimal // 按绝对价差回撤
+	activationPrice *Decimal // 价格到达后才开始跟踪，为空时立即开始
+	resting         bool     // true 时在交易所挂 STOP_LOSS 单并用 cancelReplace 移动；false 时本地触发后下市价单
+}
+
+// 跟踪止损状态
+type TrailingStop struct {
+	Id        string
+	Symbol    string
+	Side      OrderSide
+	Quantity  Decimal
+	Status    ManagedStatus
+	Activated bool
+	Extreme   Decimal // 激活后的最高价（SELL）或最低价（BUY）
+	StopPrice Decimal
+	OrderId   int64 // resting 模式下交易所的止损单，本地模式下触发后的市价单
+	Error     string
+}
+
+// 括号单配置：开仓成交后挂出止盈 + 止损的 OCO
+type BracketConfig struct {
+	symbol           string
+	side             OrderSide // 开仓方向
+	quantity         Decimal
+	entryType        OrderType // LIMIT 或 MARKET
+	entryPrice       *Decimal
+	takeProfit       Decimal
+	stopLoss         Decimal
+	stopLimitPrice   *Decimal // 止损腿用 STOP_LOSS_LIMIT 时的限价，为空时用 STOP_LOSS
+	breakEvenTrigger *Decimal // 价格到达后把止损移到开仓均价
+}
+
+// 括号单状态
+type Bracket struct {
+	Id               string
+	Symbol           string
+	Side             OrderSide
+	Status           ManagedStatus
+	EntryOrderId     int64
+	EntryPrice       Decimal // 开仓成交均价
+	FilledQty        Decimal
+	OrderListId      int64
+	StopLoss         Decimal // 当前止损价
+	MovedToBreakEven bool
+	ExitOrderId      int64
+	ExitPrice        Decimal
+	Error            string
+}
+
+type bracketOrder struct {
+	config         BracketConfig
+	state          Bracket
+	stopLimitPrice *Decimal // 当前止损腿的限价
+	legs           []int64
+	exitedQty      Decimal // 被撤掉的 OCO 腿撤单前已经成交的数量
+	busy           bool    // 有请求在进行中，价格更新跳过
+	breakEvenTried bool    // 保本移动失败并恢复原 OCO 后不再重试
+}
+
+type trailingStop struct {
+	config TrailingStopConfig
+	state  TrailingStop
+	busy   bool // 有请求在进行中，价格更新跳过
+}
+
+// 本地订单管理：跟踪止损、括号单和保本移动，由 onPrice 的价格更新和 OrderTracker 的订单变化驱动
+// tracker 需要由用户数据流的 executionReport 更新，否则无法得知成交
+// 所有请求都在 m.mu 之外发出，同一个托管订单同一时间只有一个请求
+type OrderManager struct {
+	api      TradingAPI
+	registry *SymbolRegistry // 用于对齐止损价，可以为空
+	tracker  *OrderTracker
+	ids      *ClientOrderIdGenerator
+
+	mu        sync.Mutex
+	nextId    int
+	trailing  map[string]*trailingStop
+	brackets  map[string]*bracketOrder
+	byOrderId map[int64]string
+}
+
+func newOrderManager(
+	api TradingAPI,
+	registry *SymbolRegistry,
+	tracker *OrderTracker,
+	ids *ClientOrderIdGenerator,
+) (*OrderManager, error) {
+	if tracker == nil {
+		return nil, fmt.Errorf("order manager: tracker is required")
+	}
+	m := &OrderManager{
+		api:       api,
+		registry:  registry,
+		tracker:   tracker,
+		ids:       ids,
+		trailing:  make(map[string]*trailingStop),
+		brackets:  make(map[string]*bracketOrder),
+		byOrderId: make(map[int64]string),
+	}
+	tracker.subscribe(m.onTransition)
+	return m, nil
+}
+
+func (m *OrderManager) newId(kind string) string {
+	m.nextId++
+	return kind + "-" + strconv.Itoa(m.nextId)
+}
+
+// 按 tickSize 对齐价格，registry 为空时原样返回
+func (m *OrderManager) roundPrice(symbol string, price Decimal, up bool) Decimal {
+	if m.registry == nil {
+		return price
+	}
+	symbolInfo, ok := m.registry.symbol(symbol)
+	if !ok {
+		return price
+	}
+	f := symbolInfo.filter(FilterPriceFilter)
+	if f == nil || f.TickSize.Sign() <= 0 {
+		return price
+	}
+	if up {
+		return price.RoundUpToStep(f.TickSize)
+	}
+	return price.RoundDownToStep(f.TickSize)
+}
+
+func (m *OrderManager) assign(no NewOrder) NewOrder {
+	if m.ids != nil {
+		no = m.ids.assignNewOrder(no)
+	}
+	return no
+}
+
+// 添加跟踪止损，返回托管 id
+func (m *OrderManager) addTrailingStop(config TrailingStopConfig) (string, error) {
+	if (config.percent == nil) == (config.distance == nil) {
+		return "", fmt.Errorf("trailing stop: exactly one of percent and distance is required")
+	}
+	if config.quantity.Sign() <= 0 || !config.side.isValid() {
+		return "", fmt.Errorf("trailing stop: invalid side or quantity")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.newId("trail")
+	m.trailing[id] = &trailingStop{
+		config: config,
+		state: TrailingStop{
+			Id:       id,
+			Symbol:   config.symbol,
+			Side:     config.side,
+			Quantity: config.quantity,
+			Status:   ManagedActive,
+		},
+	}
+	return id, nil
+}
+
+// 取消跟踪止损，resting 模式下同时撤掉交易所的止损单
+func (m *OrderManager) cancelTrailingStop(id string) error {
+	m.mu.Lock()
+	ts, ok := m.trailing[id]
+	if !ok || ts.state.Status != ManagedActive {
+		m.mu.Unlock()
+		return fmt.Errorf("trailing stop: %s is not active", id)
+	}
+	if ts.busy {
+		m.mu.Unlock()
+		return fmt.Errorf("trailing stop: %s has a request in flight, retry later", id)
+	}
+	orderId := ts.state.OrderId
+	if !ts.config.resting && orderId != 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("trailing stop: %s already sent exit order %d", id, orderId)
+	}
+	if !ts.config.resting || orderId == 0 {
+		ts.state.Status = ManagedCanceled
+		m.mu.Unlock()
+		return nil
+	}
+	ts.busy = true
+	// 先解除映射，避免把撤单推送当成止损失败
+	delete(m.byOrderId, orderId)
+	m.mu.Unlock()
+
+	_, err := m.api.cancelOrder(ts.config.symbol, CancelOrder{orderId: &orderId})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ts.busy = false
+	if err != nil {
+		// 撤单失败通常是止损单已经成交，恢复映射并补上期间错过的推送
+		m.byOrderId[orderId] = ts.state.Id
+		m.catchUpLocked(orderId)
+		return err
+	}
+	ts.state.Status = ManagedCanceled
+	return nil
+}
+
+// 下括号单的开仓单，返回托管 id
+func (m *OrderManager) placeBracket(config BracketConfig) (string, error) {
+	long := config.side == SideBuy
+	if !config.side.isValid() || config.quantity.Sign() <= 0 {
+		return "", fmt.Errorf("bracket: invalid side or quantity")
+	}
+	if (long && !config.takeProfit.GreaterThan(config.stopLoss)) || (!long && !config.takeProfit.LessThan(config.stopLoss)) {
+		return "", fmt.Errorf("bracket: takeProfit %s and stopLoss %s are on the wrong sides for %s", config.takeProfit, config.stopLoss, config.side)
+	}
+
+	quantity := config.quantity
+	no := NewOrder{quantity: &quantity, price: config.entryPrice}
+	if config.entryType == OrderTypeLimit {
+		timeInForce := TimeInForceGTC
+		no.timeInForce = &timeInForce
+	}
+	entry, err := m.api.createNewOrder(config.symbol, config.side, config.entryType, m.assign(no))
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	id := m.newId("bracket")
+	b := &bracketOrder{
+		config:         config,
+		stopLimitPrice: config.stopLimitPrice,
+		state: Bracket{
+			Id:           id,
+			Symbol:       config.symbol,
+			Side:         config.side,
+			Status:       ManagedPending,
+			EntryOrderId: entry.OrderId,
+			StopLoss:     config.stopLoss,
+		},
+	}
+	m.brackets[id] = b
+	m.byOrderId[entry.OrderId] = id
+
+	// 市价单或立即成交的限价单在返回中就是 FILLED；成交推送也可能先于返回到达
+	var exit *bracketOrder
+	if OrderStatus(entry.Status) == OrderStatusFilled {
+		exit = m.onEntryFinalLocked(b, entry.ExecutedQty, entry.AvgFillPrice())
+	} else {
+		exit = m.catchUpLocked(entry.OrderId)
+	}
+	m.mu.Unlock()
+	if exit != nil {
+		m.openExit(exit)
+	}
+	return id, nil
+}
+
+// 取消括号单：未成交时撤开仓单，已挂 OCO 时撤 OCO，不会平掉已有持仓
+func (m *OrderManager) cancelBracket(id string) error {
+	m.mu.Lock()
+	b, ok := m.brackets[id]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("bracket: unknown id %s", id)
+	}
+	if b.busy {
+		m.mu.Unlock()
+		return fmt.Errorf("bracket: %s has a request in flight, retry later", id)
+	}
+	status := b.state.Status
+	entryOrderId, orderListId := b.state.EntryOrderId, b.state.OrderListId
+	switch status {
+	case ManagedPending:
+		delete(m.byOrderId, entryOrderId)
+	case ManagedActive:
+		m.forgetLegs(b)
+	default:
+		m.mu.Unlock()
+		return fmt.Errorf("bracket: %s is already %s", id, status)
+	}
+	b.busy = true
+	m.mu.Unlock()
+
+	var err error
+	if status == ManagedPending {
+		_, err = m.api.cancelOrder(b.config.symbol, CancelOrder{orderId: &entryOrderId})
+	} else {
+		_, err = m.cancelOCO(b.config.symbol, orderListId)
+	}
+
+	m.mu.Lock()
+	b.busy = false
+	if err == nil {
+		b.state.Status = ManagedCanceled
+		m.mu.Unlock()
+		return nil
+	}
+	// 撤单失败通常是已经成交，恢复映射并补上期间错过的推送
+	var exit *bracketOrder
+	if status == ManagedPending {
+		m.byOrderId[entryOrderId] = b.state.Id
+		exit = m.catchUpLocked(entryOrderId)
+	} else {
+		m.restoreLegs(b)
+	}
+	m.mu.Unlock()
+	if exit != nil {
+		m.openExit(exit)
+	}
+	return err
+}
+
+// 开仓单到终态：没有成交时括号单取消；有成交时记录成交并返回需要挂 OCO 的括号单，由调用方在锁外调用 openExit
+func (m *OrderManager) onEntryFinalLocked(b *bracketOrder, executedQty, avgPrice Decimal) *bracketOrder {
+	delete(m.byOrderId, b.state.EntryOrderId)
+	if executedQty.Sign() <= 0 {
+		b.state.Status = ManagedCanceled
+		return nil
+	}
+	b.state.FilledQty = executedQty
+	b.state.EntryPrice = avgPrice
+	b.busy = true
+	return b
+}
+
+// 按成交数量挂出 OCO，失败时括号单失败
+func (m *OrderManager) openExit(b *bracketOrder) {
+	list, err := m.placeExit(b)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b.busy = false
+	if err != nil {
+		b.state.Status = ManagedFailed
+		b.state.Error = err.Error()
+		return
+	}
+	b.state.Status = ManagedActive
+	m.applyExitLocked(b, list)
+}
+
+// 按当前止损价挂出止盈 + 止损的 OCO，在锁外调用
+func (m *OrderManager) placeExit(b *bracketOrder) (*OrderListResponse, error) {
+	m.mu.Lock()
+	takeProfit := b.config.takeProfit
+	stopLoss := b.state.StopLoss
+	quantity := b.state.FilledQty.Sub(b.exitedQty)
+	takeProfitLeg := OrderListLeg{orderType: OrderTypeLimitMaker, price: &takeProfit}
+	stopLossLeg := OrderListLeg{orderType: OrderTypeStopLoss, stopPrice: &stopLoss}
+	if b.stopLimitPrice != nil {
+		timeInForce := TimeInForceGTC
+		stopLimitPrice := *b.stopLimitPrice
+		stopLossLeg = OrderListLeg{orderType: OrderTypeStopLossLimit, stopPrice: &stopLoss, price: &stopLimitPrice, timeInForce: &timeInForce}
+	}
+	m.mu.Unlock()
+
+	oco := NewOCO{above: takeProfitLeg, below: stopLossLeg}
+	if b.config.side == SideSell {
+		oco = NewOCO{above: stopLossLeg, below: takeProfitLeg}
+	}
+	return m.api.createNewOCO(b.config.symbol, b.config.side.opposite(), quantity, oco)
+}
+
+// 记录新挂出的 OCO，成交推送可能先于返回到达
+func (m *OrderManager) applyExitLocked(b *bracketOrder, list *OrderListResponse) {
+	b.state.OrderListId = list.OrderListId
+	b.legs = b.legs[:0]
+	for _, order := range list.Orders {
+		b.legs = append(b.legs, order.OrderId)
+	}
+	m.restoreLegs(b)
+}
+
+func (m *OrderManager) cancelOCO(symbol string, orderListId int64) (*OrderListResponse, error) {
+	return m.api.cancelOrderList(symbol, CancelOrderList{orderListId: &orderListId})
+}
+
+// 已撤 OCO 各条腿的成交数量，撤单回报和 tracker 中取较大的一个
+func (m *OrderManager) legsExecutedLocked(b *bracketOrder, canceled *OrderListResponse) Decimal {
+	var total Decimal
+	for _, orderId := range b.legs {
+		var executed Decimal
+		if canceled != nil {
+			for _, report := range canceled.OrderReports {
+				if report.OrderId == orderId {
+					executed = report.ExecutedQty
+				}
+			}
+		}
+		if order, ok := m.tracker.get(orderId); ok && order.ExecutedQty.GreaterThan(executed) {
+			executed = order.ExecutedQty
+		}
+		total = total.Add(executed)
+	}
+	return total
+}
+
+func (m *OrderManager) forgetLegs(b *bracketOrder) {
+	for _, orderId := range b.legs {
+		delete(m.byOrderId, orderId)
+	}
+}
+
+// 恢复 OCO 两条腿的映射，并补上映射解除期间错过的推送
+func (m *OrderManager) restoreLegs(b *bracketOrder) {
+	for _, orderId := range b.legs {
+		m.byOrderId[orderId] = b.state.Id
+	}
+	for _, orderId := range b.legs {
+		m.catchUpLocked(orderId)
+	}
+}
+
+// 价格更新，驱动跟踪止损和保本移动
+func (m *OrderManager) onPrice(symbol string, price Decimal) {
+	m.mu.Lock()
+	var trailing []*trailingStop
+	var brackets []*bracketOrder
+	for _, ts := range m.trailing {
+		if ts.config.symbol == symbol && ts.state.Status == ManagedActive && !ts.busy {
+			trailing = append(trailing, ts)
+		}
+	}
+	for _, b := range m.brackets {
+		if b.config.symbol == symbol && b.state.Status == ManagedActive && !b.busy {
+			brackets = append(brackets, b)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, ts := range trailing {
+		m.updateTrailing(ts, price)
+	}
+	for _, b := range brackets {
+		m.checkBreakEven(b, price)
+	}
+}
+
+func (m *OrderManager) updateTrailing(ts *trailingStop, price Decimal) {
+	m.mu.Lock()
+	// 本地模式已经下了市价单，等它到终态
+	if ts.busy || ts.state.Status != ManagedActive || (!ts.config.resting && ts.state.OrderId != 0) {
+		m.mu.Unlock()
+		return
+	}
+	sell := ts.config.side == SideSell
+	if !ts.state.Activated {
+		if activation := ts.config.activationPrice; activation != nil &&
+			((sell && price.LessThan(*activation)) || (!sell && price.GreaterThan(*activation))) {
+			m.mu.Unlock()
+			return
+		}
+		ts.state.Activated = true
+		ts.state.Extreme = price
+	}
+	if (sell && price.GreaterThan(ts.state.Extreme)) || (!sell && price.LessThan(ts.state.Extreme)) {
+		ts.state.Extreme = price
+	}
+
+	// SELL 止损在最高价下方，BUY 止损在最低价上方
+	var stop Decimal
+	if ts.config.percent != nil {
+		offset := ts.state.Extreme.Mul(*ts.config.percent)
+		if sell {
+			stop = ts.state.Extreme.Sub(offset)
+		} else {
+			stop = ts.state.Extreme.Add(offset)
+		}
+	} else if sell {
+		stop = ts.state.Extreme.Sub(*ts.config.distance)
+	} else {
+		stop = ts.state.Extreme.Add(*ts.config.distance)
+	}
+	stop = m.roundPrice(ts.config.symbol, stop, !sell)
+	improved := ts.state.StopPrice.IsZero() || (sell && stop.GreaterThan(ts.state.StopPrice)) || (!sell && stop.LessThan(ts.state.StopPrice))
+
+	if ts.config.resting {
+		if !improved {
+			m.mu.Unlock()
+			return
+		}
+		ts.busy = true
+		m.mu.Unlock()
+		err := m.moveRestingStop(ts, stop)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		ts.busy = false
+		if err != nil {
+			ts.state.Error = err.Error()
+			return
+		}
+		ts.state.StopPrice = stop
+		return
+	}
+
+	if improved {
+		ts.state.StopPrice = stop
+	}
+	if (sell && price.GreaterThan(ts.state.StopPrice)) || (!sell && price.LessThan(ts.state.StopPrice)) {
+		m.mu.Unlock()
+		return
+	}
+	ts.busy = true
+	m.mu.Unlock()
+
+	quantity := ts.config.quantity
+	exit, err := m.api.createNewOrder(ts.config.symbol, ts.config.side, OrderTypeMarket, m.assign(NewOrder{quantity: &quantity}))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ts.busy = false
+	if err != nil {
+		ts.state.Status = ManagedFailed
+		ts.state.Error = err.Error()
+		return
+	}
+	// 和 resting 模式一样由 applyFinalLocked 按成交结果关闭
+	ts.state.OrderId = exit.OrderId
+	m.byOrderId[exit.OrderId] = ts.state.Id
+	m.catchUpLocked(exit.OrderId)
+}
+
+// resting 模式：第一次挂 STOP_LOSS 单，之后用 cancelReplace 移动止损价，在锁外调用
+func (m *OrderManager) moveRestingStop(ts *trailingStop, stop Decimal) error {
+	quantity := ts.config.quantity
+	m.mu.Lock()
+	cancelOrderId := ts.state.OrderId
+	// 先解除映射，避免把旧单被撤的推送当成外部撤单
+	if cancelOrderId != 0 {
+		delete(m.byOrderId, cancelOrderId)
+	}
+	m.mu.Unlock()
+
+	if cancelOrderId == 0 {
+		order, err := m.api.createNewOrder(ts.config.symbol, ts.config.side, OrderTypeStopLoss, m.assign(NewOrder{quantity: &quantity, stopPrice: &stop}))
+		if err != nil {
+			return err
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		ts.state.OrderId = order.OrderId
+		m.byOrderId[order.OrderId] = ts.state.Id
+		m.catchUpLocked(order.OrderId)
+		return nil
+	}
+
+	cr := CancelReplace{quantity: &quantity, stopPrice: &stop, cancelOrderId: &cancelOrderId}
+	if m.ids != nil {
+		cr = m.ids.assignCancelReplace(cr)
+	}
+	replaced, err := m.api.cancelReplace(ts.config.symbol, ts.config.side, OrderTypeStopLoss, CancelReplaceStopOnFailure, cr)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil || replaced.NewOrderResponse == nil {
+		// 撤单失败说明止损单可能已经触发，恢复映射并补上期间错过的推送
+		m.byOrderId[cancelOrderId] = ts.state.Id
+		m.catchUpLocked(cancelOrderId)
+		if err == nil {
+			err = fmt.Errorf("trailing stop: cancelReplace returned no new order")
+		}
+		return err
+	}
+	ts.state.OrderId = replaced.NewOrderResponse.OrderId
+	m.byOrderId[ts.state.OrderId] = ts.state.Id
+	m.catchUpLocked(ts.state.OrderId)
+	return nil
+}
+
+// 价格到达 breakEvenTrigger 后撤掉 OCO，按开仓均价重新挂止损
+// 新 OCO 挂不上时恢复原来的止损，保证持仓一直有保护
+func (m *OrderManager) checkBreakEven(b *bracketOrder, price Decimal) {
+	m.mu.Lock()
+	trigger := b.config.breakEvenTrigger
+	if trigger == nil || b.busy || b.state.Status != ManagedActive || b.state.MovedToBreakEven || b.breakEvenTried {
+		m.mu.Unlock()
+		return
+	}
+	long := b.config.side == SideBuy
+	if (long && price.LessThan(*trigger)) || (!long && price.GreaterThan(*trigger)) {
+		m.mu.Unlock()
+		return
+	}
+
+	// 多头止损向上取整、空头向下取整，保证不亏
+	breakEven := m.roundPrice(b.config.symbol, b.state.EntryPrice, long)
+	if (long && !breakEven.GreaterThan(b.state.StopLoss)) || (!long && !breakEven.LessThan(b.state.StopLoss)) {
+		b.state.MovedToBreakEven = true
+		m.mu.Unlock()
+		return
+	}
+	b.busy = true
+	m.forgetLegs(b)
+	orderListId := b.state.OrderListId
+	m.mu.Unlock()
+
+	canceled, err := m.cancelOCO(b.config.symbol, orderListId)
+	if err != nil {
+		// 撤单失败通常是 OCO 已经成交，恢复映射并补上期间错过的推送
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		b.busy = false
+		b.state.Error = err.Error()
+		m.restoreLegs(b)
+		return
+	}
+
+	// 撤单前部分成交的数量已经平仓，新 OCO 只覆盖剩余持仓
+	m.mu.Lock()
+	b.exitedQty = b.exitedQty.Add(m.legsExecutedLocked(b, canceled))
+	if b.state.FilledQty.Sub(b.exitedQty).Sign() <= 0 {
+		b.busy = false
+		b.state.Status = ManagedClosed
+		m.mu.Unlock()
+		return
+	}
+
+	// STOP_LOSS_LIMIT 的限价随止损价平移
+	originalStop, originalLimit := b.state.StopLoss, b.stopLimitPrice
+	if b.stopLimitPrice != nil {
+		stopLimitPrice := b.stopLimitPrice.Add(breakEven.Sub(b.state.StopLoss))
+		b.stopLimitPrice = &stopLimitPrice
+	}
+	b.state.StopLoss = breakEven
+	m.mu.Unlock()
+
+	list, err := m.placeExit(b)
+	if err == nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		b.busy = false
+		b.state.MovedToBreakEven = true
+		m.applyExitLocked(b, list)
+		return
+	}
+
+	// 保本止损挂不上（例如价格已经回落到开仓价下方），恢复原来的 OCO
+	m.mu.Lock()
+	b.state.StopLoss, b.stopLimitPrice = originalStop, originalLimit
+	b.breakEvenTried = true
+	b.state.Error = err.Error()
+	m.mu.Unlock()
+	list, restoreErr := m.placeExit(b)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b.busy = false
+	if restoreErr != nil {
+		b.state.Status = ManagedFailed
+		b.state.Error = fmt.Sprintf("break-even: %v; restore original OCO: %v", err, restoreErr)
+		return
+	}
+	m.applyExitLocked(b, list)
+}
+
+// OrderTracker 的订单变化
+func (m *OrderManager) onTransition(transition OrderTransition) {
+	if !transition.To.isFinal() {
+		return
+	}
+	m.mu.Lock()
+	exit := m.applyFinalLocked(transition.Order, transition.To)
+	m.mu.Unlock()
+	if exit != nil {
+		m.openExit(exit)
+	}
+}
+
+// 映射解除期间可能错过了终态推送，按 tracker 中的状态补上
+func (m *OrderManager) catchUpLocked(orderId int64) *bracketOrder {
+	order, ok := m.tracker.get(orderId)
+	if !ok || !order.Status.isFinal() {
+		return nil
+	}
+	return m.applyFinalLocked(order, order.Status)
+}
+
+// 订单到终态，返回需要在锁外挂 OCO 的括号单
+func (m *OrderManager) applyFinalLocked(order TrackedOrder, to OrderStatus) *bracketOrder {
+	id, ok := m.byOrderId[order.OrderId]
+	if !ok {
+		return nil
+	}
+
+	if ts, ok := m.trailing[id]; ok {
+		delete(m.byOrderId, order.OrderId)
+		if to == OrderStatusFilled {
+			ts.state.Status = ManagedClosed
+		} else {
+			ts.state.Status = ManagedFailed
+			ts.state.Error = fmt.Sprintf("stop order %d %s", order.OrderId, to)
+		}
+		return nil
+	}
+
+	b, ok := m.brackets[id]
+	if !ok {
+		return nil
+	}
+	if order.OrderId == b.state.EntryOrderId {
+		return m.onEntryFinalLocked(b, order.ExecutedQty, order.avgFillPrice())
+	}
+	// OCO 一条腿成交后另一条会被交易所过期
+	if to == OrderStatusFilled {
+		m.forgetLegs(b)
+		b.state.ExitOrderId = order.OrderId
+		b.state.ExitPrice = order.avgFillPrice()
+		b.state.Status = ManagedClosed
+	}
+	return nil
+}
+
+// 跟踪止损的快照
+func (m *OrderManager) trailingStops() []TrailingStop {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stops := make([]TrailingStop, 0, len(m.trailing))
+	for _, ts := range m.trailing {
+		stops = append(stops, ts.state)
+	}
+	sort.Slice(stops, func(i, j int) bool { return stops[i].Id < stops[j].Id })
+	return stops
+}
+
+// 括号单的快照
+func (m *OrderManager) bracketOrders() []Bracket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	brackets := make([]Bracket, 0, len(m.brackets))
+	for _, b := range m.brackets {
+		brackets = append(brackets, b.state)
+	}
+	sort.Slice(brackets, func(i, j int) bool { return brackets[i].Id < brackets[j].Id })
+	return brackets
+}
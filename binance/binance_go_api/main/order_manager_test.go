@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 只实现括号单用到的接口，开仓单立即成交
+type fakeBracketAPI struct {
+	TradingAPI
+	ocos          []NewOCO
+	ocoQuantities []Decimal
+	failOCO       func(oco NewOCO) bool
+	canceled      []int64
+	legExecuted   string // 撤掉的 OCO 止盈腿已经成交的数量
+	orders        int
+	nextOrderId   int64
+}
+
+func (f *fakeBracketAPI) createNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*CreateOrderResponse, error) {
+	f.orders++
+	f.nextOrderId++
+	return &CreateOrderResponse{
+		Symbol:             symbol,
+		OrderId:            f.nextOrderId,
+		Status:             string(OrderStatusFilled),
+		ExecutedQty:        *no.quantity,
+		CumulativeQuoteQty: no.quantity.Mul(mustDecimal("30000")),
+	}, nil
+}
+
+func (f *fakeBracketAPI) createNewOCO(symbol string, side OrderSide, quantity Decimal, oco NewOCO) (*OrderListResponse, error) {
+	if f.failOCO != nil && f.failOCO(oco) {
+		return nil, fmt.Errorf("rejected")
+	}
+	f.ocos = append(f.ocos, oco)
+	f.ocoQuantities = append(f.ocoQuantities, quantity)
+	list := &OrderListResponse{Symbol: symbol, OrderListId: int64(len(f.ocos))}
+	for i := 0; i < 2; i++ {
+		f.nextOrderId++
+		list.Orders = append(list.Orders, OrderListOrder{Symbol: symbol, OrderId: f.nextOrderId})
+	}
+	return list, nil
+}
+
+func (f *fakeBracketAPI) cancelOrderList(symbol string, col CancelOrderList) (*OrderListResponse, error) {
+	f.canceled = append(f.canceled, *col.orderListId)
+	list := &OrderListResponse{Symbol: symbol, OrderListId: *col.orderListId}
+	if f.legExecuted != "" {
+		// 止盈腿是列表中的第一条
+		list.OrderReports = []OrderListReport{{Symbol: symbol, OrderId: f.nextOrderId - 1, ExecutedQty: mustDecimal(f.legExecuted), Status: string(OrderStatusCanceled)}}
+	}
+	return list, nil
+}
+
+func TestOrderManagerRequiresTracker(t *testing.T) {
+	if _, err := newOrderManager(&fakeBracketAPI{}, nil, nil, nil); err == nil {
+		t.Error("newOrderManager without tracker should fail")
+	}
+}
+
+func TestOrderManagerBracketPlacesOCOThroughAPI(t *testing.T) {
+	api := &fakeBracketAPI{}
+	m, err := newOrderManager(api, nil, newOrderTracker(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := m.placeBracket(BracketConfig{
+		symbol: "BTCUSDT", side: SideBuy, quantity: mustDecimal("0.01"), entryType: OrderTypeMarket,
+		takeProfit: mustDecimal("31000"), stopLoss: mustDecimal("29000"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(api.ocos) != 1 {
+		t.Fatalf("placed %d OCOs, want 1", len(api.ocos))
+	}
+	oco := api.ocos[0]
+	if oco.above.orderType != OrderTypeLimitMaker || oco.above.price.String() != "31000" || oco.below.stopPrice.String() != "29000" {
+		t.Errorf("unexpected OCO legs: %+v", oco)
+	}
+	brackets := m.bracketOrders()
+	if len(brackets) != 1 || brackets[0].Id != id || brackets[0].Status != ManagedActive {
+		t.Errorf("brackets = %+v, want %s active", brackets, id)
+	}
+}
+
+func TestOrderManagerBreakEvenRestoresOriginalOCO(t *testing.T) {
+	// 保本止损被拒绝，应重新挂出原来的止损
+	api := &fakeBracketAPI{failOCO: func(oco NewOCO) bool {
+		return oco.below.stopPrice.String() == "30000"
+	}}
+	m, err := newOrderManager(api, nil, newOrderTracker(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.placeBracket(BracketConfig{
+		symbol: "BTCUSDT", side: SideBuy, quantity: mustDecimal("0.01"), entryType: OrderTypeMarket,
+		takeProfit: mustDecimal("31000"), stopLoss: mustDecimal("29000"), breakEvenTrigger: decimalPtr("30500"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.onPrice("BTCUSDT", mustDecimal("30600"))
+	if len(api.canceled) != 1 || len(api.ocos) != 2 {
+		t.Fatalf("canceled %v, placed %d OCOs, want 1 cancel and 2 OCOs", api.canceled, len(api.ocos))
+	}
+	if got := api.ocos[1].below.stopPrice.String(); got != "29000" {
+		t.Errorf("restored stop = %s, want 29000", got)
+	}
+	b := m.bracketOrders()[0]
+	if b.Status != ManagedActive || b.MovedToBreakEven || b.OrderListId != 2 || !b.StopLoss.Equal(mustDecimal("29000")) {
+		t.Errorf("bracket = %+v, want active on the restored OCO", b)
+	}
+
+	// 失败后不再反复撤单重挂
+	m.onPrice("BTCUSDT", mustDecimal("30700"))
+	if len(api.canceled) != 1 {
+		t.Errorf("canceled %v after retry, want a single cancel", api.canceled)
+	}
+}
+
+func TestOrderManagerBreakEvenSubtractsLegFills(t *testing.T) {
+	// 止盈腿在撤单前成交了 0.004，保本 OCO 只覆盖剩余的 0.006
+	api := &fakeBracketAPI{legExecuted: "0.004"}
+	m, err := newOrderManager(api, nil, newOrderTracker(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.placeBracket(BracketConfig{
+		symbol: "BTCUSDT", side: SideBuy, quantity: mustDecimal("0.01"), entryType: OrderTypeMarket,
+		takeProfit: mustDecimal("31000"), stopLoss: mustDecimal("29000"), breakEvenTrigger: decimalPtr("30500"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.onPrice("BTCUSDT", mustDecimal("30600"))
+	if len(api.ocoQuantities) != 2 || api.ocoQuantities[1].String() != "0.006" {
+		t.Fatalf("OCO quantities = %v, want the break-even OCO for 0.006", api.ocoQuantities)
+	}
+	if b := m.bracketOrders()[0]; b.Status != ManagedActive || !b.MovedToBreakEven {
+		t.Errorf("bracket = %+v, want active at break-even", b)
+	}
+}
+
+func TestOrderManagerLocalTrailingClosesOnFill(t *testing.T) {
+	api := &fakeBracketAPI{}
+	tracker := newOrderTracker()
+	m, err := newOrderManager(api, nil, tracker, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := m.addTrailingStop(TrailingStopConfig{symbol: "BTCUSDT", side: SideSell, quantity: mustDecimal("0.01"), distance: decimalPtr("100")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.onPrice("BTCUSDT", mustDecimal("30000"))
+	m.onPrice("BTCUSDT", mustDecimal("29800"))
+	ts := m.trailingStops()[0]
+	if api.orders != 1 || ts.Status != ManagedActive || ts.OrderId == 0 {
+		t.Fatalf("sent %d orders, trailing stop = %+v, want 1 market order still active", api.orders, ts)
+	}
+	// 市价单到终态前不重复下单，也不能取消
+	m.onPrice("BTCUSDT", mustDecimal("29700"))
+	if api.orders != 1 {
+		t.Errorf("sent %d orders, want 1", api.orders)
+	}
+	if err := m.cancelTrailingStop(id); err == nil {
+		t.Error("canceling after the exit order was sent should fail")
+	}
+
+	tracker.onExecutionReport(&ExecutionReportEvent{
+		Symbol: "BTCUSDT", OrderId: ts.OrderId, Side: string(SideSell), OrderType: string(OrderTypeMarket),
+		Quantity: "0.01", CumulativeFilledQty: "0.01", CumulativeQuoteQty: "297.9",
+		ExecutionType: "TRADE", OrderStatus: string(OrderStatusFilled), TradeId: 1,
+		LastExecutedPrice: "29790", LastExecutedQty: "0.01",
+	})
+	if ts := m.trailingStops()[0]; ts.Status != ManagedClosed {
+		t.Errorf("trailing stop = %+v, want closed after the fill", ts)
+	}
+}
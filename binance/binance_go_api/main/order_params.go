@@ -0,0 +1,154 @@
+package main
+
+import "strconv"
+
+// 把 NewOrder 转成请求参数，REST 测试下单和 WebSocket API 共用
+func newOrderParams(
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	no NewOrder,
+) map[string]string {
+	params := map[string]string{
+		"symbol": symbol,
+		"side":   string(side),
+		"type":   string(orderType),
+	}
+	if no.icebergQty != nil {
+		params["icebergQty"] = no.icebergQty.String()
+	}
+	if no.newClientOrderId != nil {
+		params["newClientOrderId"] = *no.newClientOrderId
+	}
+	if no.newOrderRespType != nil {
+		params["newOrderRespType"] = string(*no.newOrderRespType)
+	}
+	if no.price != nil {
+		params["price"] = no.price.String()
+	}
+	if no.quantity != nil {
+		params["quantity"] = no.quantity.String()
+	}
+	if no.quoteOrderQty != nil {
+		params["quoteOrderQty"] = no.quoteOrderQty.String()
+	}
+	if no.selfTradePreventionMode != nil {
+		params["selfTradePreventionMode"] = string(*no.selfTradePreventionMode)
+	}
+	if no.stopPrice != nil {
+		params["stopPrice"] = no.stopPrice.String()
+	}
+	if no.strategyId != nil {
+		params["strategyId"] = strconv.Itoa(*no.strategyId)
+	}
+	if no.strategyType != nil {
+		params["strategyType"] = strconv.Itoa(*no.strategyType)
+	}
+	if no.timeInForce != nil {
+		params["timeInForce"] = string(*no.timeInForce)
+	}
+	if no.trailingDelta != nil {
+		params["trailingDelta"] = strconv.Itoa(*no.trailingDelta)
+	}
+	if no.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*no.recvWindow)
+	}
+	return params
+}
+
+// 把 CancelReplace 转成请求参数
+func cancelReplaceParams(
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	cancelReplaceMode CancelReplaceMode,
+	cr CancelReplace,
+) map[string]string {
+	params := map[string]string{
+		"symbol":            symbol,
+		"side":              string(side),
+		"type":              string(orderType),
+		"cancelReplaceMode": string(cancelReplaceMode),
+	}
+	if cr.cancelRestrictions != nil {
+		params["cancelRestrictions"] = *cr.cancelRestrictions
+	}
+	if cr.cancelOrderId != nil {
+		params["cancelOrderId"] = strconv.FormatInt(*cr.cancelOrderId, 10)
+	}
+	if cr.cancelNewClientOrderId != nil {
+		params["cancelNewClientOrderId"] = *cr.cancelNewClientOrderId
+	}
+	if cr.cancelOrigClientOrderId != nil {
+		params["cancelOrigClientOrderId"] = *cr.cancelOrigClientOrderId
+	}
+	if cr.timeInForce != nil {
+		params["timeInForce"] = string(*cr.timeInForce)
+	}
+	if cr.icebergQty != nil {
+		params["icebergQty"] = cr.icebergQty.String()
+	}
+	if cr.quantity != nil {
+		params["quantity"] = cr.quantity.String()
+	}
+	if cr.quoteOrderQty != nil {
+		params["quoteOrderQty"] = cr.quoteOrderQty.String()
+	}
+	if cr.price != nil {
+		params["price"] = cr.price.String()
+	}
+	if cr.newOrderRespType != nil {
+		params["newOrderRespType"] = string(*cr.newOrderRespType)
+	}
+	if cr.newClientOrderId != nil {
+		params["newClientOrderId"] = *cr.newClientOrderId
+	}
+	if cr.selfTradePreventionMode != nil {
+		params["selfTradePreventionMode"] = string(*cr.selfTradePreventionMode)
+	}
+	if cr.strategyId != nil {
+		params["strategyId"] = strconv.FormatInt(int64(*cr.strategyId), 10)
+	}
+	if cr.strategyType != nil {
+		params["strategyType"] = strconv.FormatInt(int64(*cr.strategyType), 10)
+	}
+	if cr.stopPrice != nil {
+		params["stopPrice"] = cr.stopPrice.String()
+	}
+	if cr.trailingDelta != nil {
+		params["trailingDelta"] = strconv.FormatInt(*cr.trailingDelta, 10)
+	}
+	if cr.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*cr.recvWindow)
+	}
+	return params
+}
+
+// 取出 CancelReplace 中新订单的部分
+func cancelReplaceNewOrder(cr CancelReplace) NewOrder {
+	no := NewOrder{
+		timeInForce:             cr.timeInForce,
+		quantity:                cr.quantity,
+		quoteOrderQty:           cr.quoteOrderQty,
+		price:                   cr.price,
+		newClientOrderId:        cr.newClientOrderId,
+		stopPrice:               cr.stopPrice,
+		icebergQty:              cr.icebergQty,
+		newOrderRespType:        cr.newOrderRespType,
+		selfTradePreventionMode: cr.selfTradePreventionMode,
+		recvWindow:              cr.recvWindow,
+	}
+	if cr.strategyId != nil {
+		strategyId := int(*cr.strategyId)
+		no.strategyId = &strategyId
+	}
+	if cr.strategyType != nil {
+		strategyType := int(*cr.strategyType)
+		no.strategyType = &strategyType
+	}
+	if cr.trailingDelta != nil {
+		trailingDelta := int(*cr.trailingDelta)
+		no.trailingDelta = &trailingDelta
+	}
+	return no
+}
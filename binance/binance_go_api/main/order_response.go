@@ -0,0 +1,100 @@
+package main
+
+// 下单返回，ACK/RESULT/FULL 共用一个结构，未返回的字段保持零值
+//
+//	ACK    symbol, orderId, orderListId, clientOrderId, transactTime
+//	RESULT ACK + 价格、数量、状态等订单详情
+//	FULL   RESULT + fills 和 preventedMatches
+type CreateOrderResponse struct {
+	Symbol        string `json:"symbol"`
+	OrderId       int64  `json:"orderId"`
+	OrderListId   int64  `json:"orderListId"`
+	ClientOrderId string `json:"clientOrderId"`
+	TransactTime  uint64 `json:"transactTime"`
+
+	Price                   Decimal `json:"price"`
+	OrigQty                 Decimal `json:"origQty"`
+	ExecutedQty             Decimal `json:"executedQty"`
+	OrigQuoteOrderQty       Decimal `json:"origQuoteOrderQty"`
+	CumulativeQuoteQty      Decimal `json:"cummulativeQuoteQty"`
+	Status                  string  `json:"status,omitempty"`
+	TimeInForce             string  `json:"timeInForce,omitempty"`
+	Type                    string  `json:"type,omitempty"`
+	Side                    string  `json:"side,omitempty"`
+	StopPrice               Decimal `json:"stopPrice"`
+	IcebergQty              Decimal `json:"icebergQty"`
+	TrailingDelta           int64   `json:"trailingDelta,omitempty"`
+	TrailingTime            int64   `json:"trailingTime,omitempty"`
+	StrategyId              int64   `json:"strategyId,omitempty"`
+	StrategyType            int64   `json:"strategyType,omitempty"`
+	WorkingTime             int64   `json:"workingTime,omitempty"`
+	SelfTradePreventionMode string  `json:"selfTradePreventionMode,omitempty"`
+	PreventedMatchId        int64   `json:"preventedMatchId,omitempty"`
+	PreventedQuantity       Decimal `json:"preventedQuantity"`
+
+	Fills            []OrderFill      `json:"fills,omitempty"`
+	PreventedMatches []PreventedMatch `json:"preventedMatches,omitempty"`
+
+	// 请求时实际使用的 newOrderRespType
+	RespType NewOrderRespType `json:"-"`
+}
+
+// FULL 返回中的成交明细
+type OrderFill struct {
+	Price           Decimal `json:"price"`
+	Qty             Decimal `json:"qty"`
+	Commission      Decimal `json:"commission"`
+	CommissionAsset string  `json:"commissionAsset"`
+	TradeId         int64   `json:"tradeId"`
+}
+
+// 因防自成交被阻止的撮合
+type PreventedMatch struct {
+	Symbol                  string  `json:"symbol,omitempty"`
+	PreventedMatchId        int64   `json:"preventedMatchId"`
+	TakerOrderId            int64   `json:"takerOrderId"`
+	MakerSymbol             string  `json:"makerSymbol"`
+	MakerOrderId            int64   `json:"makerOrderId"`
+	TradeGroupId            int64   `json:"tradeGroupId"`
+	SelfTradePreventionMode string  `json:"selfTradePreventionMode"`
+	Price                   Decimal `json:"price"`
+	MakerPreventedQuantity  Decimal `json:"makerPreventedQuantity"`
+	TransactTime            uint64  `json:"transactTime"`
+}
+
+// 未指定 newOrderRespType 时，MARKET 和 LIMIT 默认 FULL，其余默认 ACK
+func resolveNewOrderRespType(orderType OrderType, no NewOrder) NewOrderRespType {
+	if no.newOrderRespType != nil {
+		return *no.newOrderRespType
+	}
+	if orderType == OrderTypeMarket || orderType == OrderTypeLimit {
+		return NewOrderRespFULL
+	}
+	return NewOrderRespACK
+}
+
+// 成交均价：有 fills 时按成交明细加权，否则用 cummulativeQuoteQty / executedQty
+// ACK 返回或尚未成交时返回 0
+func (r *CreateOrderResponse) AvgFillPrice() Decimal {
+	qty, quote := r.ExecutedQty, r.CumulativeQuoteQty
+	if len(r.Fills) > 0 {
+		qty, quote = Decimal{}, Decimal{}
+		for _, fill := range r.Fills {
+			qty = qty.Add(fill.Qty)
+			quote = quote.Add(fill.Price.Mul(fill.Qty))
+		}
+	}
+	if qty.IsZero() {
+		return Decimal{}
+	}
+	return quote.Div(qty)
+}
+
+// 按手续费币种汇总的手续费，只有 FULL 返回才有数据
+func (r *CreateOrderResponse) TotalCommission() map[string]Decimal {
+	commissions := make(map[string]Decimal)
+	for _, fill := range r.Fills {
+		commissions[fill.CommissionAsset] = commissions[fill.CommissionAsset].Add(fill.Commission)
+	}
+	return commissions
+}
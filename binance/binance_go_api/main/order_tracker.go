@@ -0,0 +1,527 @@
+package main
+
+import (
+	"binance_connector"
+	"sort"
+	"sync"
+)
+
+// 订单状态
+type OrderStatus string
+
+const (
+	OrderStatusPendingNew      OrderStatus = "PENDING_NEW"
+	OrderStatusNew             OrderStatus = "NEW"
+	OrderStatusPartiallyFilled OrderStatus = "PARTIALLY_FILLED"
+	OrderStatusFilled          OrderStatus = "FILLED"
+	OrderStatusPendingCancel   OrderStatus = "PENDING_CANCEL"
+	OrderStatusCanceled        OrderStatus = "CANCELED"
+	OrderStatusRejected        OrderStatus = "REJECTED"
+	OrderStatusExpired         OrderStatus = "EXPIRED"
+	OrderStatusExpiredInMatch  OrderStatus = "EXPIRED_IN_MATCH"
+)
+
+// 终态之后订单不会再变化
+func (s OrderStatus) isFinal() bool {
+	switch s {
+	case OrderStatusFilled, OrderStatusCanceled, OrderStatusRejected, OrderStatusExpired, OrderStatusExpiredInMatch:
+		return true
+	}
+	return false
+}
+
+// 状态的先后顺序，用来丢弃比本地更旧的 REST 快照或乱序事件
+func (s OrderStatus) rank() int {
+	switch s {
+	case OrderStatusPendingNew:
+		return 0
+	case OrderStatusNew:
+		return 1
+	case OrderStatusPartiallyFilled, OrderStatusPendingCancel:
+		return 2
+	}
+	if s.isFinal() {
+		return 3
+	}
+	return -1
+}
+
+// 一笔成交
+type TrackedFill struct {
+	TradeId         int64
+	Price           Decimal
+	Qty             Decimal
+	Commission      Decimal
+	CommissionAsset string
+	Time            int64
+	IsMaker         bool
+}
+
+// 本地跟踪的订单
+type TrackedOrder struct {
+	Symbol             string
+	OrderId            int64
+	ClientOrderId      string
+	Side               OrderSide
+	Type               OrderType
+	Price              Decimal
+	OrigQty            Decimal
+	ExecutedQty        Decimal
+	CumulativeQuoteQty Decimal
+	Status             OrderStatus
+	UpdateTime         int64
+	Fills              []TrackedFill
+}
+
+// 未成交数量
+func (o *TrackedOrder) remainingQty() Decimal {
+	remaining := o.OrigQty.Sub(o.ExecutedQty)
+	if remaining.Sign() < 0 {
+		return Decimal{}
+	}
+	return remaining
+}
+
+// 成交均价，未成交时为 0
+func (o *TrackedOrder) avgFillPrice() Decimal {
+	if o.ExecutedQty.IsZero() {
+		return Decimal{}
+	}
+	return o.CumulativeQuoteQty.Div(o.ExecutedQty)
+}
+
+// 订单状态变化，部分成交后再次成交时 From 和 To 都是 PARTIALLY_FILLED，Fill 为新增的成交
+type OrderTransition struct {
+	Order TrackedOrder
+	From  OrderStatus
+	To    OrderStatus
+	Fill  *TrackedFill
+}
+
+// 各数据源统一转换成的订单更新
+type orderUpdate struct {
+	symbol             string
+	orderId            int64
+	clientOrderId      string
+	side               OrderSide
+	orderType          OrderType
+	price              Decimal
+	origQty            Decimal
+	executedQty        Decimal
+	cumulativeQuoteQty Decimal
+	hasExecution       bool // executedQty/cumulativeQuoteQty 是否有效，ACK 返回中没有
+	status             OrderStatus
+	time               int64
+	fills              []TrackedFill
+}
+
+// 订单跟踪器，由 REST 返回（通过 newTrackingTradingAPI）和 executionReport 驱动，每次状态变化都会通知订阅者
+type OrderTracker struct {
+	mu            sync.Mutex
+	orders        map[int64]*TrackedOrder
+	byClientOrder map[string]int64
+	subscribers   []func(OrderTransition)
+}
+
+func newOrderTracker() *OrderTracker {
+	return &OrderTracker{
+		orders:        make(map[int64]*TrackedOrder),
+		byClientOrder: make(map[string]int64),
+	}
+}
+
+// 订阅状态变化，回调在锁外同步执行
+func (t *OrderTracker) subscribe(subscriber func(OrderTransition)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subscribers = append(t.subscribers, subscriber)
+}
+
+// 下单返回，dry-run 的返回没有 orderId，不跟踪
+func (t *OrderTracker) onCreateOrder(newOrder *CreateOrderResponse) {
+	if newOrder.Status == dryRunResult || newOrder.OrderId == 0 {
+		return
+	}
+	update := orderUpdate{
+		symbol:             newOrder.Symbol,
+		orderId:            newOrder.OrderId,
+		clientOrderId:      newOrder.ClientOrderId,
+		side:               OrderSide(newOrder.Side),
+		orderType:          OrderType(newOrder.Type),
+		price:              newOrder.Price,
+		origQty:            newOrder.OrigQty,
+		executedQty:        newOrder.ExecutedQty,
+		cumulativeQuoteQty: newOrder.CumulativeQuoteQty,
+		hasExecution:       newOrder.RespType != NewOrderRespACK,
+		status:             OrderStatus(newOrder.Status),
+		time:               int64(newOrder.TransactTime),
+	}
+	// ACK 返回没有状态，下单成功即视为 NEW
+	if update.status == "" {
+		update.status = OrderStatusNew
+	}
+	for _, fill := range newOrder.Fills {
+		update.fills = append(update.fills, TrackedFill{
+			TradeId:         fill.TradeId,
+			Price:           fill.Price,
+			Qty:             fill.Qty,
+			Commission:      fill.Commission,
+			CommissionAsset: fill.CommissionAsset,
+			Time:            int64(newOrder.TransactTime),
+		})
+	}
+	t.apply(update)
+}
+
+// 查询订单返回
+func (t *OrderTracker) onQueryOrder(order *binance_connector.GetOrderResponse) {
+	t.apply(orderUpdate{
+		symbol:             order.Symbol,
+		orderId:            order.OrderId,
+		clientOrderId:      order.ClientOrderId,
+		side:               OrderSide(order.Side),
+		orderType:          OrderType(order.Type),
+		price:              decimalOrZero(order.Price),
+		origQty:            decimalOrZero(order.OrigQty),
+		executedQty:        decimalOrZero(order.ExecutedQty),
+		cumulativeQuoteQty: decimalOrZero(order.CumulativeQuoteQty),
+		hasExecution:       true,
+		status:             OrderStatus(order.Status),
+		time:               int64(order.UpdateTime),
+	})
+}
+
+// 当前挂单返回
+func (t *OrderTracker) onOpenOrder(order *binance_connector.NewOpenOrdersResponse) {
+	t.apply(orderUpdate{
+		symbol:             order.Symbol,
+		orderId:            order.OrderId,
+		clientOrderId:      order.ClientOrderId,
+		side:               OrderSide(order.Side),
+		orderType:          OrderType(order.Type),
+		price:              decimalOrZero(order.Price),
+		origQty:            decimalOrZero(order.OrigQty),
+		executedQty:        decimalOrZero(order.ExecutedQty),
+		cumulativeQuoteQty: decimalOrZero(order.CumulativeQuoteQty),
+		hasExecution:       true,
+		status:             OrderStatus(order.Status),
+		time:               int64(order.UpdateTime),
+	})
+}
+
+// 历史订单返回
+func (t *OrderTracker) onAllOrder(order *binance_connector.NewAllOrdersResponse) {
+	t.apply(orderUpdate{
+		symbol:             order.Symbol,
+		orderId:            order.OrderId,
+		clientOrderId:      order.ClientOrderId,
+		side:               OrderSide(order.Side),
+		orderType:          OrderType(order.Type),
+		price:              decimalOrZero(order.Price),
+		origQty:            decimalOrZero(order.OrigQty),
+		executedQty:        decimalOrZero(order.ExecutedQty),
+		cumulativeQuoteQty: decimalOrZero(order.CumulativeQuoteQty),
+		hasExecution:       true,
+		status:             OrderStatus(order.Status),
+		time:               int64(order.UpdateTime),
+	})
+}
+
+// 成交记录只补充成交明细，不改变订单状态；订单未被跟踪时忽略
+func (t *OrderTracker) onTrade(trade *binance_connector.AccountTradeListResponse) {
+	if _, ok := t.get(trade.OrderId); !ok {
+		return
+	}
+	t.apply(orderUpdate{
+		symbol:  trade.Symbol,
+		orderId: trade.OrderId,
+		fills: []TrackedFill{{
+			TradeId:         trade.Id,
+			Price:           decimalOrZero(trade.Price),
+			Qty:             decimalOrZero(trade.Quantity),
+			Commission:      decimalOrZero(trade.Commission),
+			CommissionAsset: trade.CommissionAsset,
+			Time:            int64(trade.Time),
+			IsMaker:         trade.IsMaker,
+		}},
+	})
+}
+
+// 撤单返回，clientOrderId 是撤单请求的 id，原订单的 id 在 origClientOrderId
+func (t *OrderTracker) onCancelOrder(order *binance_connector.CancelOrderResponse) {
+	t.apply(orderUpdate{
+		symbol:             order.Symbol,
+		orderId:            order.OrderId,
+		clientOrderId:      order.OrigClientOrderId,
+		side:               OrderSide(order.Side),
+		orderType:          OrderType(order.Type),
+		price:              decimalOrZero(order.Price),
+		origQty:            decimalOrZero(order.OrigQty),
+		executedQty:        decimalOrZero(order.ExecutedQty),
+		cumulativeQuoteQty: decimalOrZero(order.CumulativeQuoteQty),
+		hasExecution:       true,
+		status:             OrderStatus(order.Status),
+	})
+}
+
+// 用户数据流的 executionReport，可以直接设置为 UserDataHandlers.onExecutionReport
+func (t *OrderTracker) onExecutionReport(event *ExecutionReportEvent) {
+	clientOrderId := event.ClientOrderId
+	if event.ExecutionType == "CANCELED" && event.OrigClientOrderId != "" {
+		clientOrderId = event.OrigClientOrderId
+	}
+	update := orderUpdate{
+		symbol:             event.Symbol,
+		orderId:            event.OrderId,
+		clientOrderId:      clientOrderId,
+		side:               OrderSide(event.Side),
+		orderType:          OrderType(event.OrderType),
+		price:              decimalOrZero(event.Price),
+		origQty:            decimalOrZero(event.Quantity),
+		executedQty:        decimalOrZero(event.CumulativeFilledQty),
+		cumulativeQuoteQty: decimalOrZero(event.CumulativeQuoteQty),
+		hasExecution:       true,
+		status:             OrderStatus(event.OrderStatus),
+		time:               event.TransactionTime,
+	}
+	if event.ExecutionType == "TRADE" {
+		update.fills = append(update.fills, TrackedFill{
+			TradeId:         event.TradeId,
+			Price:           decimalOrZero(event.LastExecutedPrice),
+			Qty:             decimalOrZero(event.LastExecutedQty),
+			Commission:      decimalOrZero(event.Commission),
+			CommissionAsset: event.CommissionAsset,
+			Time:            event.TransactionTime,
+			IsMaker:         event.IsMaker,
+		})
+	}
+	t.apply(update)
+}
+
+// 合并一次更新，过期或乱序的更新会被丢弃
+func (t *OrderTracker) apply(update orderUpdate) {
+	t.mu.Lock()
+	order, ok := t.orders[update.orderId]
+	if !ok {
+		order = &TrackedOrder{
+			Symbol:  update.symbol,
+			OrderId: update.orderId,
+		}
+		t.orders[update.orderId] = order
+	}
+	from := order.Status
+	fromQty := order.ExecutedQty
+
+	// 终态不再变化；状态回退、成交量减少或更新时间更早说明是旧数据
+	stale := from.isFinal() ||
+		update.status.rank() < from.rank() ||
+		(update.hasExecution && update.executedQty.LessThan(order.ExecutedQty)) ||
+		(update.time > 0 && update.time < order.UpdateTime)
+
+	var newFills []TrackedFill
+	if !stale {
+		if update.clientOrderId != "" && order.ClientOrderId == "" {
+			order.ClientOrderId = update.clientOrderId
+			t.byClientOrder[update.clientOrderId] = order.OrderId
+		}
+		if update.side != "" {
+			order.Side = update.side
+		}
+		if update.orderType != "" {
+			order.Type = update.orderType
+		}
+		if !update.price.IsZero() {
+			order.Price = update.price
+		}
+		if !update.origQty.IsZero() {
+			order.OrigQty = update.origQty
+		}
+		if update.hasExecution {
+			order.ExecutedQty = update.executedQty
+			order.CumulativeQuoteQty = update.cumulativeQuoteQty
+		}
+		if update.time > order.UpdateTime {
+			order.UpdateTime = update.time
+		}
+		order.Status = update.status
+	}
+	// 成交明细按 tradeId 去重，即使状态已是终态也要补齐，例如 FILLED 的 REST 返回先于 executionReport 到达
+	for _, fill := range update.fills {
+		if !order.hasFill(fill.TradeId) {
+			order.Fills = append(order.Fills, fill)
+			newFills = append(newFills, fill)
+		}
+	}
+	sort.Slice(order.Fills, func(i, j int) bool { return order.Fills[i].TradeId < order.Fills[j].TradeId })
+
+	var transitions []OrderTransition
+	if !stale && (order.Status != from || (len(newFills) == 0 && order.ExecutedQty.GreaterThan(fromQty))) {
+		transitions = append(transitions, OrderTransition{Order: order.snapshot(), From: from, To: order.Status})
+	}
+	for i := range newFills {
+		fill := newFills[i]
+		if len(transitions) > 0 && transitions[0].Fill == nil {
+			transitions[0].Fill = &fill
+			continue
+		}
+		transitions = append(transitions, OrderTransition{Order: order.snapshot(), From: order.Status, To: order.Status, Fill: &fill})
+	}
+	subscribers := append([]func(OrderTransition){}, t.subscribers...)
+	t.mu.Unlock()
+
+	for _, transition := range transitions {
+		for _, subscriber := range subscribers {
+			subscriber(transition)
+		}
+	}
+}
+
+func (o *TrackedOrder) hasFill(tradeId int64) bool {
+	for _, fill := range o.Fills {
+		if fill.TradeId == tradeId {
+			return true
+		}
+	}
+	return false
+}
+
+// 拷贝一份，避免调用方拿到内部状态
+func (o *TrackedOrder) snapshot() TrackedOrder {
+	copied := *o
+	copied.Fills = append([]TrackedFill(nil), o.Fills...)
+	return copied
+}
+
+// 按 orderId 查询
+func (t *OrderTracker) get(orderId int64) (TrackedOrder, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	order, ok := t.orders[orderId]
+	if !ok {
+		return TrackedOrder{}, false
+	}
+	return order.snapshot(), true
+}
+
+// 按 clientOrderId 查询
+func (t *OrderTracker) getByClientOrderId(clientOrderId string) (TrackedOrder, bool) {
+	t.mu.Lock()
+	orderId, ok := t.byClientOrder[clientOrderId]
+	t.mu.Unlock()
+	if !ok {
+		return TrackedOrder{}, false
+	}
+	return t.get(orderId)
+}
+
+// 未到终态的订单，symbol 为空时返回所有交易对
+func (t *OrderTracker) openOrders(symbol string) []TrackedOrder {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var orders []TrackedOrder
+	for _, order := range t.orders {
+		if order.Status.isFinal() || (symbol != "" && order.Symbol != symbol) {
+			continue
+		}
+		orders = append(orders, order.snapshot())
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderId < orders[j].OrderId })
+	return orders
+}
+
+// 删除已到终态的订单，释放内存
+func (t *OrderTracker) prune() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for orderId, order := range t.orders {
+		if order.Status.isFinal() {
+			delete(t.orders, orderId)
+			delete(t.byClientOrder, order.ClientOrderId)
+		}
+	}
+}
+
+// 把下单、撤单和查询的返回同步给 OrderTracker 的交易接口，其他请求直接转发
+// 成交推送仍需要由用户数据流的 executionReport 提供
+type trackingTradingAPI struct {
+	TradingAPI
+	tracker *OrderTracker
+}
+
+func newTrackingTradingAPI(api TradingAPI, tracker *OrderTracker) TradingAPI {
+	return &trackingTradingAPI{TradingAPI: api, tracker: tracker}
+}
+
+func (a *trackingTradingAPI) createNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*CreateOrderResponse, error) {
+	newOrder, err := a.TradingAPI.createNewOrder(symbol, side, orderType, no)
+	if err == nil {
+		a.tracker.onCreateOrder(newOrder)
+	}
+	return newOrder, err
+}
+
+func (a *trackingTradingAPI) cancelOrder(symbol string, co CancelOrder) (*binance_connector.CancelOrderResponse, error) {
+	canceled, err := a.TradingAPI.cancelOrder(symbol, co)
+	if err == nil {
+		a.tracker.onCancelOrder(canceled)
+	}
+	return canceled, err
+}
+
+func (a *trackingTradingAPI) cancelReplace(symbol string, side OrderSide, orderType OrderType, cancelReplaceMode CancelReplaceMode, cr CancelReplace) (*binance_connector.CancelReplaceResponse, error) {
+	replaced, err := a.TradingAPI.cancelReplace(symbol, side, orderType, cancelReplaceMode, cr)
+	if err != nil {
+		return replaced, err
+	}
+	if c := replaced.CancelResponse; c != nil && c.OrderId != 0 {
+		a.tracker.apply(orderUpdate{
+			symbol:             c.Symbol,
+			orderId:            c.OrderId,
+			clientOrderId:      c.OrigClientOrderId,
+			side:               OrderSide(c.Side),
+			orderType:          OrderType(c.Type),
+			price:              decimalOrZero(c.Price),
+			origQty:            decimalOrZero(c.OrigQty),
+			executedQty:        decimalOrZero(c.ExecutedQty),
+			cumulativeQuoteQty: decimalOrZero(c.CumulativeQuoteQty),
+			hasExecution:       true,
+			status:             OrderStatus(c.Status),
+		})
+	}
+	// 新订单的返回没有成交明细，成交由 executionReport 补充
+	if n := replaced.NewOrderResponse; n != nil && n.OrderId != 0 && n.Status != "" {
+		a.tracker.apply(orderUpdate{
+			symbol:             n.Symbol,
+			orderId:            n.OrderId,
+			clientOrderId:      n.ClientOrderId,
+			side:               OrderSide(n.Side),
+			orderType:          OrderType(n.Type),
+			price:              decimalOrZero(n.Price),
+			origQty:            decimalOrZero(n.OrigQty),
+			executedQty:        decimalOrZero(n.ExecutedQty),
+			cumulativeQuoteQty: decimalOrZero(n.CumulativeQuoteQty),
+			hasExecution:       true,
+			status:             OrderStatus(n.Status),
+			time:               int64(n.TransactTime),
+		})
+	}
+	return replaced, nil
+}
+
+func (a *trackingTradingAPI) getQueryOrder(symbol string, qo QueryOrder) (*binance_connector.GetOrderResponse, error) {
+	order, err := a.TradingAPI.getQueryOrder(symbol, qo)
+	if err == nil {
+		a.tracker.onQueryOrder(order)
+	}
+	return order, err
+}
+
+func (a *trackingTradingAPI) getCurrentOpenOrders(symbol string) ([]*binance_connector.NewOpenOrdersResponse, error) {
+	openOrders, err := a.TradingAPI.getCurrentOpenOrders(symbol)
+	if err == nil {
+		for _, order := range openOrders {
+			a.tracker.onOpenOrder(order)
+		}
+	}
+	return openOrders, err
+}
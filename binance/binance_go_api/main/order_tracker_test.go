@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOrderTrackerSkipsDryRun(t *testing.T) {
+	tracker := newOrderTracker()
+	tracker.onCreateOrder(&CreateOrderResponse{Symbol: "BTCUSDT", Side: string(SideBuy), Type: string(OrderTypeLimit), Status: dryRunResult})
+	if open := tracker.openOrders("BTCUSDT"); len(open) != 0 {
+		t.Errorf("dry-run order tracked as open: %+v", open)
+	}
+
+	tracker.onCreateOrder(&CreateOrderResponse{Symbol: "BTCUSDT", OrderId: 1, Side: string(SideBuy), Type: string(OrderTypeLimit), Status: string(OrderStatusNew)})
+	if open := tracker.openOrders("BTCUSDT"); len(open) != 1 || open[0].OrderId != 1 {
+		t.Errorf("open orders = %+v, want order 1", open)
+	}
+}
+
+func testTrackerUpdate(status OrderStatus, executedQty string, time int64, tradeIds ...int64) orderUpdate {
+	update := orderUpdate{
+		symbol: "BTCUSDT", orderId: 1, clientOrderId: "c1", side: SideBuy, orderType: OrderTypeLimit,
+		price: mustDecimal("30000"), origQty: mustDecimal("0.3"),
+		executedQty: mustDecimal(executedQty), cumulativeQuoteQty: mustDecimal(executedQty).Mul(mustDecimal("30000")),
+		hasExecution: true, status: status, time: time,
+	}
+	for _, tradeId := range tradeIds {
+		update.fills = append(update.fills, TrackedFill{TradeId: tradeId, Price: mustDecimal("30000"), Qty: mustDecimal("0.1"), Time: time})
+	}
+	return update
+}
+
+func TestOrderTrackerApply(t *testing.T) {
+	tests := []struct {
+		name            string
+		updates         []orderUpdate
+		wantStatus      OrderStatus
+		wantQty         string
+		wantFills       int
+		wantTransitions []string // From->To，成交附带的 tradeId 用 #id 表示
+	}{
+		{
+			name:            "new then partial fills",
+			updates:         []orderUpdate{testTrackerUpdate(OrderStatusNew, "0", 1), testTrackerUpdate(OrderStatusPartiallyFilled, "0.1", 2, 10), testTrackerUpdate(OrderStatusPartiallyFilled, "0.2", 3, 11)},
+			wantStatus:      OrderStatusPartiallyFilled,
+			wantQty:         "0.2",
+			wantFills:       2,
+			wantTransitions: []string{"->NEW", "NEW->PARTIALLY_FILLED#10", "PARTIALLY_FILLED->PARTIALLY_FILLED#11"},
+		},
+		{
+			name:            "partial fill without fill detail",
+			updates:         []orderUpdate{testTrackerUpdate(OrderStatusPartiallyFilled, "0.1", 1), testTrackerUpdate(OrderStatusPartiallyFilled, "0.2", 2)},
+			wantStatus:      OrderStatusPartiallyFilled,
+			wantQty:         "0.2",
+			wantTransitions: []string{"->PARTIALLY_FILLED", "PARTIALLY_FILLED->PARTIALLY_FILLED"},
+		},
+		{
+			name:            "status going back is stale",
+			updates:         []orderUpdate{testTrackerUpdate(OrderStatusPartiallyFilled, "0.1", 2, 10), testTrackerUpdate(OrderStatusNew, "0", 1)},
+			wantStatus:      OrderStatusPartiallyFilled,
+			wantQty:         "0.1",
+			wantFills:       1,
+			wantTransitions: []string{"->PARTIALLY_FILLED#10"},
+		},
+		{
+			name:            "older updateTime is stale",
+			updates:         []orderUpdate{testTrackerUpdate(OrderStatusPartiallyFilled, "0.2", 5), testTrackerUpdate(OrderStatusPartiallyFilled, "0.2", 3)},
+			wantStatus:      OrderStatusPartiallyFilled,
+			wantQty:         "0.2",
+			wantTransitions: []string{"->PARTIALLY_FILLED"},
+		},
+		{
+			name:            "smaller executedQty is stale",
+			updates:         []orderUpdate{testTrackerUpdate(OrderStatusPartiallyFilled, "0.2", 2), testTrackerUpdate(OrderStatusPartiallyFilled, "0.1", 2)},
+			wantStatus:      OrderStatusPartiallyFilled,
+			wantQty:         "0.2",
+			wantTransitions: []string{"->PARTIALLY_FILLED"},
+		},
+		{
+			name:            "fills deduplicated by tradeId",
+			updates:         []orderUpdate{testTrackerUpdate(OrderStatusPartiallyFilled, "0.1", 1, 10), testTrackerUpdate(OrderStatusPartiallyFilled, "0.1", 1, 10)},
+			wantStatus:      OrderStatusPartiallyFilled,
+			wantQty:         "0.1",
+			wantFills:       1,
+			wantTransitions: []string{"->PARTIALLY_FILLED#10"},
+		},
+		{
+			name:            "final status does not change",
+			updates:         []orderUpdate{testTrackerUpdate(OrderStatusCanceled, "0", 1), testTrackerUpdate(OrderStatusNew, "0", 2), testTrackerUpdate(OrderStatusPartiallyFilled, "0.1", 3)},
+			wantStatus:      OrderStatusCanceled,
+			wantQty:         "0",
+			wantTransitions: []string{"->CANCELED"},
+		},
+		{
+			name:            "fill detail after final status",
+			updates:         []orderUpdate{testTrackerUpdate(OrderStatusFilled, "0.3", 1), testTrackerUpdate(OrderStatusFilled, "0.3", 1, 10, 11, 12)},
+			wantStatus:      OrderStatusFilled,
+			wantQty:         "0.3",
+			wantFills:       3,
+			wantTransitions: []string{"->FILLED", "FILLED->FILLED#10", "FILLED->FILLED#11", "FILLED->FILLED#12"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newOrderTracker()
+			var transitions []string
+			tracker.subscribe(func(transition OrderTransition) {
+				s := string(transition.From) + "->" + string(transition.To)
+				if transition.Fill != nil {
+					s += fmt.Sprintf("#%d", transition.Fill.TradeId)
+				}
+				transitions = append(transitions, s)
+			})
+			for _, update := range tt.updates {
+				tracker.apply(update)
+			}
+			order, ok := tracker.get(1)
+			if !ok {
+				t.Fatal("order not tracked")
+			}
+			if order.Status != tt.wantStatus || order.ExecutedQty.String() != tt.wantQty || len(order.Fills) != tt.wantFills {
+				t.Errorf("order = %s %s with %d fills, want %s %s with %d fills",
+					order.Status, order.ExecutedQty, len(order.Fills), tt.wantStatus, tt.wantQty, tt.wantFills)
+			}
+			if fmt.Sprint(transitions) != fmt.Sprint(tt.wantTransitions) {
+				t.Errorf("transitions = %v, want %v", transitions, tt.wantTransitions)
+			}
+		})
+	}
+}
+
+func TestTrackingTradingAPI(t *testing.T) {
+	tracker := newOrderTracker()
+	api := newTrackingTradingAPI(&fakeGridAPI{}, tracker)
+	gtc := TimeInForceGTC
+	order, err := api.createNewOrder("BTCUSDT", SideBuy, OrderTypeLimit, NewOrder{price: decimalPtr("30000"), quantity: decimalPtr("0.001"), timeInForce: &gtc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracked, ok := tracker.get(order.OrderId); !ok || tracked.Status != OrderStatusNew {
+		t.Fatalf("tracked = %+v, want NEW", tracked)
+	}
+	orderId := order.OrderId
+	if _, err := api.cancelOrder("BTCUSDT", CancelOrder{orderId: &orderId}); err != nil {
+		t.Fatal(err)
+	}
+	if tracked, _ := tracker.get(orderId); tracked.Status != OrderStatusCanceled {
+		t.Errorf("status after cancel = %s, want CANCELED", tracked.Status)
+	}
+}
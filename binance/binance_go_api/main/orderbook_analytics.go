@@ -0,0 +1,298 @@
+package main
+
+import (
+	"binance_connector"
+	"fmt"
+	"math/big"
+)
+
+// 订单簿中的一档价格
+type BookLevel struct {
+	price    float64
+	quantity float64
+}
+
+// 按给定数量吃单的预估成交结果
+type FillEstimate struct {
+	side          OrderSide
+	filledBase    float64
+	filledQuote   float64
+	avgPrice      float64
+	bestPrice     float64
+	worstPrice    float64
+	midPrice      float64
+	slippageBps   float64 // 相对于最优价的滑点
+	impactBps     float64 // 相对于中间价的冲击成本
+	levelsTouched int
+	fullyFilled   bool
+}
+
+// 买一卖一价差统计
+type SpreadStats struct {
+	bestBid    float64
+	bestBidQty float64
+	bestAsk    float64
+	bestAskQty float64
+	midPrice   float64
+	spread     float64
+	spreadBps  float64
+	microPrice float64 // 按买一卖一数量加权的中间价
+}
+
+// 中间价上下 X bps 以内的累计深度
+type DepthWithinBps struct {
+	bps        float64
+	bidBase    float64
+	bidQuote   float64
+	askBase    float64
+	askQuote   float64
+	lowerPrice float64
+	upperPrice float64
+}
+
+// 把 getOrderBookDepth 返回的 [][]*big.Float 转成 BookLevel
+func toBookLevels(raw [][]*big.Float) ([]BookLevel, error) {
+	levels := make([]BookLevel, 0, len(raw))
+	for i, row := range raw {
+		if len(row) < 2 || row[0] == nil || row[1] == nil {
+			return nil, fmt.Errorf("orderbook: malformed level %d", i)
+		}
+		price, _ := row[0].Float64()
+		quantity, _ := row[1].Float64()
+		levels = append(levels, BookLevel{price: price, quantity: quantity})
+	}
+	return levels, nil
+}
+
+// 订单簿中的一档价格，精确值
+type DecimalLevel struct {
+	price    Decimal
+	quantity Decimal
+}
+
+// 把 [][]*big.Float 按原始十进制值转成 DecimalLevel
+func toDecimalLevels(raw [][]*big.Float) ([]DecimalLevel, error) {
+	levels := make([]DecimalLevel, 0, len(raw))
+	for i, row := range raw {
+		if len(row) < 2 || row[0] == nil || row[1] == nil {
+			return nil, fmt.Errorf("orderbook: malformed level %d", i)
+		}
+		price, err := newDecimalFromString(row[0].Text('f', -1))
+		if err != nil {
+			return nil, err
+		}
+		quantity, err := newDecimalFromString(row[1].Text('f', -1))
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, DecimalLevel{price: price, quantity: quantity})
+	}
+	return levels, nil
+}
+
+// 拆分订单簿的买卖两边，并确认两边都不为空
+func bookSides(orderBook *binance_connector.OrderBookResponse) (bids, asks []BookLevel, err error) {
+	if orderBook == nil {
+		return nil, nil, fmt.Errorf("orderbook: nil order book")
+	}
+	bids, err = toBookLevels(orderBook.Bids)
+	if err != nil {
+		return nil, nil, err
+	}
+	asks, err = toBookLevels(orderBook.Asks)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(bids) == 0 || len(asks) == 0 {
+		return nil, nil, fmt.Errorf("orderbook: empty side (bids=%d, asks=%d)", len(bids), len(asks))
+	}
+	return bids, asks, nil
+}
+
+// 买一卖一、价差、中间价
+func getSpreadStats(orderBook *binance_connector.OrderBookResponse) (*SpreadStats, error) {
+	bids, asks, err := bookSides(orderBook)
+	if err != nil {
+		return nil, err
+	}
+	bid, ask := bids[0], asks[0]
+	mid := (bid.price + ask.price) / 2
+	stats := &SpreadStats{
+		bestBid:    bid.price,
+		bestBidQty: bid.quantity,
+		bestAsk:    ask.price,
+		bestAskQty: ask.quantity,
+		midPrice:   mid,
+		spread:     ask.price - bid.price,
+		microPrice: mid,
+	}
+	if mid > 0 {
+		stats.spreadBps = stats.spread / mid * 10000
+	}
+	if bid.quantity+ask.quantity > 0 {
+		stats.microPrice = (bid.price*ask.quantity + ask.price*bid.quantity) / (bid.quantity + ask.quantity)
+	}
+	return stats, nil
+}
+
+// 预估按 baseQty（基础币数量）或 quoteQty（计价币金额）吃单的平均成交价和滑点
+// side 为 BUY 时吃卖单，为 SELL 时吃买单；baseQty 和 quoteQty 只能传一个
+// 累计成交用 Decimal 计算，避免 float64 的残差多吃一档
+func estimateFill(
+	orderBook *binance_connector.OrderBookResponse,
+	side OrderSide,
+	baseQty *float64,
+	quoteQty *float64,
+) (*FillEstimate, error) {
+	if (baseQty == nil) == (quoteQty == nil) {
+		return nil, fmt.Errorf("orderbook: exactly one of baseQty and quoteQty must be set")
+	}
+	if orderBook == nil {
+		return nil, fmt.Errorf("orderbook: nil order book")
+	}
+	bids, err := toDecimalLevels(orderBook.Bids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := toDecimalLevels(orderBook.Asks)
+	if err != nil {
+		return nil, err
+	}
+	if len(bids) == 0 || len(asks) == 0 {
+		return nil, fmt.Errorf("orderbook: empty side (bids=%d, asks=%d)", len(bids), len(asks))
+	}
+
+	var levels []DecimalLevel
+	switch side {
+	case SideBuy:
+		levels = asks
+	case SideSell:
+		levels = bids
+	default:
+		return nil, fmt.Errorf("orderbook: unknown side %q", side)
+	}
+
+	amount := baseQty
+	if amount == nil {
+		amount = quoteQty
+	}
+	target, err := decimalFromFloat(*amount)
+	if err != nil {
+		return nil, fmt.Errorf("orderbook: %w", err)
+	}
+	if target.Sign() <= 0 {
+		return nil, fmt.Errorf("orderbook: quantity must be positive, got %s", target)
+	}
+
+	var filledBase, filledQuote, worstPrice Decimal
+	levelsTouched := 0
+	for _, level := range levels {
+		if level.quantity.Sign() <= 0 || level.price.Sign() <= 0 {
+			continue
+		}
+		take, quote := level.quantity, level.quantity.Mul(level.price)
+		if baseQty != nil {
+			if remaining := target.Sub(filledBase); remaining.LessThan(take) {
+				take, quote = remaining, remaining.Mul(level.price)
+			}
+		} else if remaining := target.Sub(filledQuote); !quote.LessThan(remaining) {
+			// 这一档能吃完剩余金额，金额直接记为剩余金额，不留除法残差
+			take, quote = remaining.Div(level.price), remaining
+		}
+		filledBase = filledBase.Add(take)
+		filledQuote = filledQuote.Add(quote)
+		worstPrice = level.price
+		levelsTouched++
+		filled := filledQuote
+		if baseQty != nil {
+			filled = filledBase
+		}
+		if !filled.LessThan(target) {
+			break
+		}
+	}
+
+	estimate := &FillEstimate{
+		side:          side,
+		filledBase:    filledBase.Float64(),
+		filledQuote:   filledQuote.Float64(),
+		bestPrice:     levels[0].price.Float64(),
+		worstPrice:    worstPrice.Float64(),
+		midPrice:      bids[0].price.Add(asks[0].price).Div(newDecimalFromInt(2)).Float64(),
+		levelsTouched: levelsTouched,
+	}
+	if filledBase.Sign() > 0 {
+		estimate.avgPrice = filledQuote.Div(filledBase).Float64()
+	}
+	if baseQty != nil {
+		estimate.fullyFilled = !filledBase.LessThan(target)
+	} else {
+		estimate.fullyFilled = !filledQuote.LessThan(target)
+	}
+
+	// 买单价格越高越差，卖单价格越低越差，统一成正数表示成本
+	direction := 1.0
+	if side == SideSell {
+		direction = -1.0
+	}
+	if estimate.avgPrice > 0 {
+		estimate.slippageBps = direction * (estimate.avgPrice - estimate.bestPrice) / estimate.bestPrice * 10000
+		estimate.impactBps = direction * (estimate.avgPrice - estimate.midPrice) / estimate.midPrice * 10000
+	}
+	return estimate, nil
+}
+
+// 中间价上下 bps 以内的累计挂单量
+func getDepthWithinBps(orderBook *binance_connector.OrderBookResponse, bps float64) (*DepthWithinBps, error) {
+	if bps < 0 {
+		return nil, fmt.Errorf("orderbook: bps must not be negative, got %v", bps)
+	}
+	bids, asks, err := bookSides(orderBook)
+	if err != nil {
+		return nil, err
+	}
+	mid := (bids[0].price + asks[0].price) / 2
+	depth := &DepthWithinBps{
+		bps:        bps,
+		lowerPrice: mid * (1 - bps/10000),
+		upperPrice: mid * (1 + bps/10000),
+	}
+	for _, level := range bids {
+		if level.price < depth.lowerPrice {
+			break
+		}
+		depth.bidBase += level.quantity
+		depth.bidQuote += level.quantity * level.price
+	}
+	for _, level := range asks {
+		if level.price > depth.upperPrice {
+			break
+		}
+		depth.askBase += level.quantity
+		depth.askQuote += level.quantity * level.price
+	}
+	return depth, nil
+}
+
+// 买卖盘不平衡度，取前 levels 档（levels<=0 表示全部），结果在 [-1, 1]，正数表示买盘更厚
+func getBookImbalance(orderBook *binance_connector.OrderBookResponse, levels int) (float64, error) {
+	bids, asks, err := bookSides(orderBook)
+	if err != nil {
+		return 0, err
+	}
+	if levels > 0 {
+		bids = bids[:min(levels, len(bids))]
+		asks = asks[:min(levels, len(asks))]
+	}
+	var bidQty, askQty float64
+	for _, level := range bids {
+		bidQty += level.quantity
+	}
+	for _, level := range asks {
+		askQty += level.quantity
+	}
+	if bidQty+askQty == 0 {
+		return 0, nil
+	}
+	return (bidQty - askQty) / (bidQty + askQty), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"binance_connector"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func testBookSide(t *testing.T, levels [][2]string) [][]*big.Float {
+	t.Helper()
+	side := make([][]*big.Float, 0, len(levels))
+	for _, level := range levels {
+		price, ok1 := new(big.Float).SetString(level[0])
+		qty, ok2 := new(big.Float).SetString(level[1])
+		if !ok1 || !ok2 {
+			t.Fatalf("bad level %v", level)
+		}
+		side = append(side, []*big.Float{price, qty})
+	}
+	return side
+}
+
+func testOrderBook(t *testing.T, bids, asks [][2]string) *binance_connector.OrderBookResponse {
+	t.Helper()
+	return &binance_connector.OrderBookResponse{Bids: testBookSide(t, bids), Asks: testBookSide(t, asks)}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestEstimateFill(t *testing.T) {
+	book := testOrderBook(t,
+		[][2]string{{"2.9", "1"}, {"2.8", "2"}},
+		[][2]string{{"3", "0.1"}, {"3.1", "2"}, {"3.3", "5"}},
+	)
+	tests := []struct {
+		name          string
+		side          OrderSide
+		baseQty       *float64
+		quoteQty      *float64
+		filledBase    float64
+		filledQuote   float64
+		worstPrice    float64
+		levelsTouched int
+		fullyFilled   bool
+	}{
+		{"base within first level", SideBuy, floatPtr(0.05), nil, 0.05, 0.15, 3, 1, true},
+		{"base across levels", SideBuy, floatPtr(1.1), nil, 1.1, 3.4, 3.1, 2, true},
+		// 0.1 * 3 用 float64 计算是 0.30000000000000004，残差不能再碰下一档
+		{"quote exactly one level", SideBuy, nil, floatPtr(0.3), 0.1, 0.3, 3, 1, true},
+		{"quote with non-terminating division", SideBuy, nil, floatPtr(1), 0.1 + 0.7/3.1, 1, 3.1, 2, true},
+		{"sell uses bids", SideSell, floatPtr(1.5), nil, 1.5, 4.3, 2.8, 2, true},
+		{"not enough depth", SideSell, floatPtr(10), nil, 3, 8.5, 2.8, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, err := estimateFill(book, tt.side, tt.baseQty, tt.quoteQty)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(estimate.filledBase-tt.filledBase) > 1e-12 {
+				t.Errorf("filledBase = %v, want %v", estimate.filledBase, tt.filledBase)
+			}
+			if math.Abs(estimate.filledQuote-tt.filledQuote) > 1e-12 {
+				t.Errorf("filledQuote = %v, want %v", estimate.filledQuote, tt.filledQuote)
+			}
+			if estimate.worstPrice != tt.worstPrice {
+				t.Errorf("worstPrice = %v, want %v", estimate.worstPrice, tt.worstPrice)
+			}
+			if estimate.levelsTouched != tt.levelsTouched {
+				t.Errorf("levelsTouched = %d, want %d", estimate.levelsTouched, tt.levelsTouched)
+			}
+			if estimate.fullyFilled != tt.fullyFilled {
+				t.Errorf("fullyFilled = %v, want %v", estimate.fullyFilled, tt.fullyFilled)
+			}
+		})
+	}
+}
+
+func TestEstimateFillErrors(t *testing.T) {
+	book := testOrderBook(t, [][2]string{{"1", "1"}}, [][2]string{{"2", "1"}})
+	if _, err := estimateFill(book, SideBuy, nil, nil); err == nil {
+		t.Error("expected error without baseQty and quoteQty")
+	}
+	if _, err := estimateFill(book, SideBuy, floatPtr(1), floatPtr(1)); err == nil {
+		t.Error("expected error with both baseQty and quoteQty")
+	}
+	if _, err := estimateFill(book, OrderSide("HOLD"), floatPtr(1), nil); err == nil {
+		t.Error("expected error for unknown side")
+	}
+	empty := testOrderBook(t, nil, [][2]string{{"2", "1"}})
+	if _, err := estimateFill(empty, SideBuy, floatPtr(1), nil); err == nil {
+		t.Error("expected error for empty bids")
+	}
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := estimateFill(book, SideBuy, floatPtr(f), nil); err == nil {
+			t.Errorf("expected error for baseQty %v", f)
+		}
+		if _, err := estimateFill(book, SideSell, nil, floatPtr(f)); err == nil {
+			t.Errorf("expected error for quoteQty %v", f)
+		}
+	}
+}
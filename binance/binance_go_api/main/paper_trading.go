@@ -0,0 +1,1230 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"binance_connector"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 模拟盘配置
+type PaperTradingConfig struct {
+	balances        map[string]Decimal // 初始余额
+	makerCommission *Decimal           // 挂单手续费率，为空时使用 PAPER_MAKER_COMMISSION
+	takerCommission *Decimal           // 吃单手续费率，为空时使用 PAPER_TAKER_COMMISSION
+	depthLimit      int                // 吃单时读取的盘口档数，为 0 时使用 PAPER_DEPTH_LIMIT
+}
+
+// 虚拟余额
+type paperBalance struct {
+	free   Decimal
+	locked Decimal
+}
+
+// 盘口一档
+type paperLevel struct {
+	price Decimal
+	qty   Decimal
+}
+
+// 模拟订单
+type paperOrder struct {
+	symbol        string
+	baseAsset     string
+	quoteAsset    string
+	orderId       int64
+	orderListId   int64 // 不属于订单列表时为 -1
+	clientOrderId string
+	side          OrderSide
+	orderType     OrderType
+	timeInForce   TimeInForce
+	price         Decimal
+	stopPrice     Decimal
+	origQty       Decimal
+	quoteOrderQty Decimal
+	stepSize      Decimal
+
+	executedQty        Decimal
+	cumulativeQuoteQty Decimal
+	locked             Decimal // 剩余冻结，买单为计价币，卖单为基础币
+	status             OrderStatus
+	working            bool // 止损/止盈单触发前为 false
+	time               uint64
+	updateTime         uint64
+	workingTime        uint64
+	stpMode            string
+	fills              []OrderFill
+	linked             *paperOrder // OCO 的另一条腿，任一条腿触发或成交后解除
+}
+
+func (o *paperOrder) remainingQty() Decimal {
+	return o.origQty.Sub(o.executedQty)
+}
+
+func (o *paperOrder) isOpen() bool {
+	return !o.status.isFinal()
+}
+
+// 挂单需要冻结的数量：买单按价格（市价止损/止盈按触发价）冻结计价币，卖单冻结基础币
+func paperLockNeed(side OrderSide, price, stopPrice, qty Decimal) Decimal {
+	if side == SideSell {
+		return qty
+	}
+	if price.Sign() > 0 {
+		return price.Mul(qty)
+	}
+	return stopPrice.Mul(qty)
+}
+
+// 模拟的 OCO 订单列表
+type paperOrderList struct {
+	orderListId       int64
+	listClientOrderId string
+	symbol            string
+	legs              []*paperOrder
+	time              uint64
+}
+
+// 模拟盘：实现 TradingAPI，订单不发到交易所
+// 吃单按下单时的实时盘口逐档成交；挂单由 sync 按之后的市场成交撮合，成交价必须穿过挂单价才算成交（只触及不算）
+// 止损/止盈单按市场成交价触发，市价类在触发价成交
+// 手续费从收到的币种中扣除，下单前用 SymbolRegistry 做与交易所相同的过滤器校验
+type PaperTradingAPI struct {
+	registry *SymbolRegistry
+	proxyURL string
+	config   PaperTradingConfig
+
+	mu             sync.Mutex
+	nextOrderId    int64
+	nextTradeId    int64
+	nextListId     int64
+	orders         map[int64]*paperOrder
+	orderLists     map[int64]*paperOrderList
+	clientOrderIds map[string]int64 // symbol + clientOrderId -> orderId
+	balances       map[string]*paperBalance
+	lastTradeIds   map[string]uint64
+	events         []*ExecutionReportEvent // 等待投递的 executionReport
+	onReport       func(event *ExecutionReportEvent)
+	reportCond     *sync.Cond // 有新的 executionReport 或投递完成
+	delivering     bool
+	orderBook      func(symbol string) (bids, asks []paperLevel, err error) // 默认读取实时盘口
+	stopCh         chan struct{}
+	doneCh         chan struct{}
+}
+
+func newPaperTradingAPI(registry *SymbolRegistry, config PaperTradingConfig, proxyURL string) (*PaperTradingAPI, error) {
+	if registry == nil {
+		return nil, fmt.Errorf("paper: symbol registry required")
+	}
+	if config.makerCommission == nil {
+		rate := newDecimalFromFloat(initConfig.PAPER_MAKER_COMMISSION)
+		config.makerCommission = &rate
+	}
+	if config.takerCommission == nil {
+		rate := newDecimalFromFloat(initConfig.PAPER_TAKER_COMMISSION)
+		config.takerCommission = &rate
+	}
+	if config.depthLimit <= 0 {
+		config.depthLimit = initConfig.PAPER_DEPTH_LIMIT
+	}
+	p := &PaperTradingAPI{
+		registry:       registry,
+		proxyURL:       proxyURL,
+		config:         config,
+		orders:         make(map[int64]*paperOrder),
+		orderLists:     make(map[int64]*paperOrderList),
+		clientOrderIds: make(map[string]int64),
+		balances:       make(map[string]*paperBalance),
+		lastTradeIds:   make(map[string]uint64),
+	}
+	for asset, amount := range config.balances {
+		p.balances[asset] = &paperBalance{free: amount}
+	}
+	p.reportCond = sync.NewCond(&p.mu)
+	p.orderBook = p.liveOrderBook
+	go p.deliver()
+	return p, nil
+}
+
+// 模拟的 executionReport，可以设置为 OrderTracker.onExecutionReport
+// 在投递协程中按顺序回调，与真实的用户数据流一样晚于下单返回
+func (p *PaperTradingAPI) setOnExecutionReport(onReport func(event *ExecutionReportEvent)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onReport = onReport
+}
+
+// 在后台每 PAPER_TRADING_POLL_INTERVAL 撮合一次挂单
+func (p *PaperTradingAPI) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopCh != nil {
+		return
+	}
+	p.stopCh = make(chan struct{})
+	p.doneCh = make(chan struct{})
+	go p.run(p.stopCh, p.doneCh)
+}
+
+func (p *PaperTradingAPI) stop() {
+	p.mu.Lock()
+	stopCh, doneCh := p.stopCh, p.doneCh
+	p.stopCh, p.doneCh = nil, nil
+	p.mu.Unlock()
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	<-doneCh
+}
+
+func (p *PaperTradingAPI) run(stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+	ticker := time.NewTicker(initConfig.PAPER_TRADING_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if err := p.sync(); err != nil {
+				fmt.Println("paper:", err)
+			}
+		}
+	}
+}
+
+// 拉取有挂单的交易对的最近成交，撮合挂单和触发止损单
+func (p *PaperTradingAPI) sync() error {
+	p.mu.Lock()
+	symbols := make(map[string]bool)
+	for _, o := range p.orders {
+		if o.isOpen() {
+			symbols[o.symbol] = true
+		}
+	}
+	p.mu.Unlock()
+
+	limit := initConfig.RECENT_TRADES_LIMIT
+	for symbol := range symbols {
+		trades, err := getRecentTradeList("", "", symbol, &limit, p.proxyURL)
+		if err != nil {
+			return err
+		}
+		marketTrades := make([]MarketTrade, 0, len(trades))
+		for _, trade := range trades {
+			marketTrades = append(marketTrades, MarketTrade{
+				Id:    trade.Id,
+				Price: decimalOrZero(trade.Price),
+				Qty:   decimalOrZero(trade.Qty),
+				Time:  trade.Time,
+			})
+		}
+		p.onTrades(symbol, marketTrades)
+	}
+	return nil
+}
+
+// 按市场成交撮合，也可以由成交推送直接调用
+func (p *PaperTradingAPI) onTrades(symbol string, trades []MarketTrade) {
+	defer p.flush()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sort.Slice(trades, func(i, j int) bool { return trades[i].Id < trades[j].Id })
+	var open []*paperOrder
+	for _, o := range p.orders {
+		if o.symbol == symbol && o.isOpen() {
+			open = append(open, o)
+		}
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].orderId < open[j].orderId })
+
+	for _, trade := range trades {
+		if trade.Id <= p.lastTradeIds[symbol] {
+			continue
+		}
+		p.lastTradeIds[symbol] = trade.Id
+		available := trade.Qty
+		for _, o := range open {
+			if !o.isOpen() || trade.Time < o.time {
+				continue
+			}
+			if !o.working {
+				if !stopTriggered(o, trade.Price) {
+					continue
+				}
+				o.working = true
+				o.workingTime = trade.Time
+				p.detachLocked(o)
+				if o.orderType == OrderTypeStopLoss || o.orderType == OrderTypeTakeProfit {
+					p.fillTriggeredMarket(o, trade.Price)
+					continue
+				}
+			}
+			if available.Sign() <= 0 || !tradesThrough(o, trade.Price) {
+				continue
+			}
+			qty := o.remainingQty()
+			if available.LessThan(qty) {
+				qty = available
+			}
+			available = available.Sub(qty)
+			p.fill(o, o.price, qty, true)
+		}
+	}
+}
+
+// 止损：买单价格涨到 stopPrice 以上触发，卖单跌到 stopPrice 以下触发；止盈相反
+func stopTriggered(o *paperOrder, price Decimal) bool {
+	rising := o.side == SideBuy
+	if o.orderType == OrderTypeTakeProfit || o.orderType == OrderTypeTakeProfitLimit {
+		rising = !rising
+	}
+	if rising {
+		return !price.LessThan(o.stopPrice)
+	}
+	return !price.GreaterThan(o.stopPrice)
+}
+
+// 市场成交价穿过挂单价
+func tradesThrough(o *paperOrder, price Decimal) bool {
+	if o.side == SideBuy {
+		return price.LessThan(o.price)
+	}
+	return price.GreaterThan(o.price)
+}
+
+// 市价止损/止盈触发后按触发价全部成交，余额不足时过期
+func (p *PaperTradingAPI) fillTriggeredMarket(o *paperOrder, price Decimal) {
+	qty := o.remainingQty()
+	if o.side == SideBuy {
+		cost := price.Mul(qty)
+		if p.balance(o.quoteAsset).free.Add(o.locked).LessThan(cost) {
+			p.finish(o, OrderStatusExpired, "EXPIRED")
+			return
+		}
+	}
+	p.fill(o, price, qty, false)
+}
+
+func (p *PaperTradingAPI) balance(asset string) *paperBalance {
+	b, ok := p.balances[asset]
+	if !ok {
+		b = &paperBalance{}
+		p.balances[asset] = b
+	}
+	return b
+}
+
+// 一笔成交：释放对应的冻结，扣除成本，收到的币种扣除手续费
+func (p *PaperTradingAPI) fill(o *paperOrder, price, qty Decimal, maker bool) {
+	p.detachLocked(o)
+	remaining := o.remainingQty()
+	release := o.locked
+	if qty.LessThan(remaining) && o.locked.Sign() > 0 {
+		if part, err := o.locked.Mul(qty).CheckedDiv(remaining); err == nil {
+			release = part
+		}
+	}
+	o.locked = o.locked.Sub(release)
+
+	rate := *p.config.takerCommission
+	if maker {
+		rate = *p.config.makerCommission
+	}
+	quote := price.Mul(qty)
+	base, quoteBalance := p.balance(o.baseAsset), p.balance(o.quoteAsset)
+	var commission Decimal
+	var commissionAsset string
+	if o.side == SideBuy {
+		quoteBalance.locked = quoteBalance.locked.Sub(release)
+		quoteBalance.free = quoteBalance.free.Add(release).Sub(quote)
+		commission, commissionAsset = qty.Mul(rate), o.baseAsset
+		base.free = base.free.Add(qty).Sub(commission)
+	} else {
+		base.locked = base.locked.Sub(release)
+		base.free = base.free.Add(release).Sub(qty)
+		commission, commissionAsset = quote.Mul(rate), o.quoteAsset
+		quoteBalance.free = quoteBalance.free.Add(quote).Sub(commission)
+	}
+
+	p.nextTradeId++
+	fill := OrderFill{
+		Price:           price,
+		Qty:             qty,
+		Commission:      commission,
+		CommissionAsset: commissionAsset,
+		TradeId:         p.nextTradeId,
+	}
+	o.fills = append(o.fills, fill)
+	o.executedQty = o.executedQty.Add(qty)
+	o.cumulativeQuoteQty = o.cumulativeQuoteQty.Add(quote)
+	o.updateTime = uint64(time.Now().UnixMilli())
+	o.status = OrderStatusPartiallyFilled
+	if o.remainingQty().Sign() <= 0 {
+		o.status = OrderStatusFilled
+	}
+	p.report(o, "TRADE", &fill, maker)
+}
+
+// OCO 的一条腿触发或成交：接管另一条腿的冻结，另一条腿过期
+func (p *PaperTradingAPI) detachLocked(o *paperOrder) {
+	sibling := o.linked
+	if sibling == nil {
+		return
+	}
+	o.linked, sibling.linked = nil, nil
+	o.locked = o.locked.Add(sibling.locked)
+	sibling.locked = Decimal{}
+	if sibling.isOpen() {
+		p.finish(sibling, OrderStatusExpired, "EXPIRED")
+	}
+}
+
+// 冻结 need，记在订单上，成交或结束时释放
+func (p *PaperTradingAPI) lockLocked(o *paperOrder, need Decimal) {
+	asset := o.baseAsset
+	if o.side == SideBuy {
+		asset = o.quoteAsset
+	}
+	b := p.balance(asset)
+	b.free = b.free.Sub(need)
+	b.locked = b.locked.Add(need)
+	o.locked = o.locked.Add(need)
+}
+
+// 结束订单并释放剩余冻结
+func (p *PaperTradingAPI) finish(o *paperOrder, status OrderStatus, executionType string) {
+	if o.locked.Sign() > 0 {
+		asset := o.baseAsset
+		if o.side == SideBuy {
+			asset = o.quoteAsset
+		}
+		b := p.balance(asset)
+		b.locked = b.locked.Sub(o.locked)
+		b.free = b.free.Add(o.locked)
+		o.locked = Decimal{}
+	}
+	o.status = status
+	o.updateTime = uint64(time.Now().UnixMilli())
+	p.report(o, executionType, nil, false)
+}
+
+func (p *PaperTradingAPI) report(o *paperOrder, executionType string, fill *OrderFill, maker bool) {
+	event := &ExecutionReportEvent{
+		EventType:               "executionReport",
+		EventTime:               time.Now().UnixMilli(),
+		Symbol:                  o.symbol,
+		ClientOrderId:           o.clientOrderId,
+		Side:                    string(o.side),
+		OrderType:               string(o.orderType),
+		TimeInForce:             string(o.timeInForce),
+		Quantity:                o.origQty.String(),
+		Price:                   o.price.String(),
+		StopPrice:               o.stopPrice.String(),
+		OrderListId:             o.orderListId,
+		ExecutionType:           executionType,
+		OrderStatus:             string(o.status),
+		RejectReason:            "NONE",
+		OrderId:                 o.orderId,
+		LastExecutedQty:         "0",
+		CumulativeFilledQty:     o.executedQty.String(),
+		LastExecutedPrice:       "0",
+		Commission:              "0",
+		TransactionTime:         int64(o.updateTime),
+		TradeId:                 -1,
+		IsOnBook:                o.isOpen() && o.working,
+		IsMaker:                 maker,
+		CreationTime:            int64(o.time),
+		CumulativeQuoteQty:      o.cumulativeQuoteQty.String(),
+		LastQuoteQty:            "0",
+		QuoteOrderQty:           o.quoteOrderQty.String(),
+		WorkingTime:             int64(o.workingTime),
+		SelfTradePreventionMode: o.stpMode,
+	}
+	if fill != nil {
+		event.LastExecutedQty = fill.Qty.String()
+		event.LastExecutedPrice = fill.Price.String()
+		event.Commission = fill.Commission.String()
+		event.CommissionAsset = fill.CommissionAsset
+		event.TradeId = fill.TradeId
+		event.LastQuoteQty = fill.Price.Mul(fill.Qty).String()
+	}
+	p.events = append(p.events, event)
+}
+
+// 唤醒投递协程；调用方（例如网格）下单时可能持有自己的锁，同步回调会在订阅者中再次加锁而死锁
+func (p *PaperTradingAPI) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.events) > 0 {
+		p.reportCond.Broadcast()
+	}
+}
+
+// 投递协程，随模拟盘一直运行；在锁外依次回调积累的 executionReport
+func (p *PaperTradingAPI) deliver() {
+	p.mu.Lock()
+	for {
+		for len(p.events) == 0 {
+			p.delivering = false
+			p.reportCond.Broadcast()
+			p.reportCond.Wait()
+		}
+		events, onReport := p.events, p.onReport
+		p.events = nil
+		p.delivering = true
+		p.mu.Unlock()
+		if onReport != nil {
+			for _, event := range events {
+				onReport(event)
+			}
+		}
+		p.mu.Lock()
+	}
+}
+
+// 等待已产生的 executionReport 全部投递完成，不能在回调中调用
+func (p *PaperTradingAPI) waitReports() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.events) > 0 || p.delivering {
+		p.reportCond.Wait()
+	}
+}
+
+// 实时盘口，转成 Decimal
+func (p *PaperTradingAPI) liveOrderBook(symbol string) (bids, asks []paperLevel, err error) {
+	limit := p.config.depthLimit
+	orderBook, err := getOrderBookDepth("", "", symbol, &limit, p.proxyURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bids, err = toPaperLevels(orderBook.Bids); err != nil {
+		return nil, nil, err
+	}
+	if asks, err = toPaperLevels(orderBook.Asks); err != nil {
+		return nil, nil, err
+	}
+	return bids, asks, nil
+}
+
+func toPaperLevels(raw [][]*big.Float) ([]paperLevel, error) {
+	levels := make([]paperLevel, 0, len(raw))
+	for i, row := range raw {
+		if len(row) < 2 || row[0] == nil || row[1] == nil {
+			return nil, fmt.Errorf("paper: malformed order book level %d", i)
+		}
+		price, err := newDecimalFromString(row[0].Text('f', -1))
+		if err != nil {
+			return nil, err
+		}
+		qty, err := newDecimalFromString(row[1].Text('f', -1))
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, paperLevel{price: price, qty: qty})
+	}
+	return levels, nil
+}
+
+// 下单前的准备：参数和过滤器校验，需要吃单时读取盘口
+type paperRequest struct {
+	symbolInfo *SymbolDetail
+	side       OrderSide
+	orderType  OrderType
+	no         NewOrder
+	bids       []paperLevel
+	asks       []paperLevel
+	listId     int64 // OCO 的腿，冻结由 createNewOCO 统一处理
+}
+
+func (p *PaperTradingAPI) prepare(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*paperRequest, error) {
+	if err := validateNewOrder(side, orderType, no); err != nil {
+		return nil, err
+	}
+	if no.trailingDelta != nil || no.icebergQty != nil {
+		return nil, fmt.Errorf("paper: trailingDelta and icebergQty are not supported")
+	}
+	symbolInfo, ok := p.registry.symbol(symbol)
+	if !ok {
+		return nil, &APIError{StatusCode: 400, Code: -1121, Message: "Invalid symbol."}
+	}
+	no, err := p.registry.checkOrder(symbol, side, orderType, no, FilterCheck{})
+	if err != nil {
+		return nil, err
+	}
+	req := &paperRequest{symbolInfo: symbolInfo, side: side, orderType: orderType, no: no}
+	switch orderType {
+	case OrderTypeMarket, OrderTypeLimit, OrderTypeLimitMaker:
+		if req.bids, req.asks, err = p.orderBook(symbol); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func errInsufficientBalance() error {
+	return &APIError{StatusCode: 400, Code: -2010, Message: "Account has insufficient balance for requested action."}
+}
+
+// 在盘口上吃单，返回按档的成交，不修改任何状态
+func (p *PaperTradingAPI) match(o *paperOrder, levels []paperLevel) []paperLevel {
+	var fills []paperLevel
+	qtyLeft, quoteLeft := o.origQty, o.quoteOrderQty
+	byQuote := o.quoteOrderQty.Sign() > 0
+	for _, level := range levels {
+		if o.orderType != OrderTypeMarket {
+			if o.side == SideBuy && level.price.GreaterThan(o.price) {
+				break
+			}
+			if o.side == SideSell && level.price.LessThan(o.price) {
+				break
+			}
+		}
+		qty := qtyLeft
+		if byQuote {
+			var err error
+			if qty, err = quoteLeft.CheckedDiv(level.price); err != nil {
+				continue
+			}
+			if o.stepSize.Sign() > 0 {
+				qty = qty.RoundDownToStep(o.stepSize)
+			}
+		}
+		if level.qty.LessThan(qty) {
+			qty = level.qty
+		}
+		if qty.Sign() <= 0 {
+			break
+		}
+		fills = append(fills, paperLevel{price: level.price, qty: qty})
+		qtyLeft = qtyLeft.Sub(qty)
+		quoteLeft = quoteLeft.Sub(qty.Mul(level.price))
+	}
+	return fills
+}
+
+// 在锁内创建订单：冻结余额，先吃盘口，剩余部分按 timeInForce 挂单或过期
+func (p *PaperTradingAPI) placeLocked(req *paperRequest) (*paperOrder, error) {
+	no := req.no
+	symbolInfo := req.symbolInfo
+	now := uint64(time.Now().UnixMilli())
+	o := &paperOrder{
+		symbol:      symbolInfo.Symbol,
+		baseAsset:   symbolInfo.BaseAsset,
+		quoteAsset:  symbolInfo.QuoteAsset,
+		orderListId: -1,
+		side:        req.side,
+		orderType:   req.orderType,
+		status:      OrderStatusNew,
+		time:        now,
+		updateTime:  now,
+		workingTime: now,
+		working:     true,
+		stpMode:     string(STPModeNone),
+	}
+	if no.quantity != nil {
+		o.origQty = *no.quantity
+	}
+	if no.quoteOrderQty != nil {
+		o.quoteOrderQty = *no.quoteOrderQty
+	}
+	if no.price != nil {
+		o.price = *no.price
+	}
+	if no.stopPrice != nil {
+		o.stopPrice = *no.stopPrice
+		o.working = false
+		o.workingTime = 0
+	}
+	if no.timeInForce != nil {
+		o.timeInForce = *no.timeInForce
+	}
+	if req.orderType == OrderTypeLimitMaker {
+		o.timeInForce = TimeInForceGTC
+	}
+	if no.selfTradePreventionMode != nil {
+		o.stpMode = string(*no.selfTradePreventionMode)
+	}
+	for _, filterType := range []string{FilterMarketLotSize, FilterLotSize} {
+		if f := symbolInfo.filter(filterType); f != nil && f.StepSize.Sign() > 0 && o.stepSize.Sign() == 0 {
+			o.stepSize = f.StepSize
+		}
+	}
+
+	levels := req.asks
+	if req.side == SideSell {
+		levels = req.bids
+	}
+	var fills []paperLevel
+	if req.orderType != OrderTypeStopLoss && req.orderType != OrderTypeTakeProfit &&
+		req.orderType != OrderTypeStopLossLimit && req.orderType != OrderTypeTakeProfitLimit {
+		fills = p.match(o, levels)
+	}
+	if req.orderType == OrderTypeLimitMaker && len(fills) > 0 {
+		return nil, &APIError{StatusCode: 400, Code: -2010, Message: "Order would immediately match and take."}
+	}
+	var filledQty, filledQuote Decimal
+	for _, f := range fills {
+		filledQty = filledQty.Add(f.qty)
+		filledQuote = filledQuote.Add(f.qty.Mul(f.price))
+	}
+	if req.orderType == OrderTypeMarket && o.quoteOrderQty.Sign() > 0 {
+		// quoteOrderQty 订单的数量就是实际成交数量
+		o.origQty = filledQty
+	}
+	if o.timeInForce == TimeInForceFOK && filledQty.LessThan(o.origQty) {
+		fills = nil
+	}
+
+	// 冻结：限价和止损单冻结全部数量，市价只检查本次成交所需
+	if req.listId > 0 {
+		o.orderListId = req.listId
+	} else {
+		need := paperLockNeed(o.side, o.price, o.stopPrice, o.origQty)
+		asset := o.baseAsset
+		if o.side == SideBuy {
+			asset = o.quoteAsset
+			if req.orderType == OrderTypeMarket {
+				need = filledQuote
+			}
+		}
+		if p.balance(asset).free.LessThan(need) {
+			return nil, errInsufficientBalance()
+		}
+		if req.orderType != OrderTypeMarket {
+			p.lockLocked(o, need)
+		}
+	}
+
+	p.nextOrderId++
+	o.orderId = p.nextOrderId
+	o.clientOrderId = fmt.Sprintf("paper_%d", o.orderId)
+	if no.newClientOrderId != nil {
+		o.clientOrderId = *no.newClientOrderId
+	}
+	p.orders[o.orderId] = o
+	p.clientOrderIds[o.symbol+" "+o.clientOrderId] = o.orderId
+	p.report(o, "NEW", nil, false)
+
+	for _, f := range fills {
+		p.fill(o, f.price, f.qty, false)
+	}
+	if o.isOpen() {
+		switch {
+		case req.orderType == OrderTypeMarket:
+			// 盘口深度不够时剩余部分过期
+			p.finish(o, OrderStatusExpired, "EXPIRED")
+		case o.timeInForce == TimeInForceIOC || o.timeInForce == TimeInForceFOK:
+			p.finish(o, OrderStatusExpired, "EXPIRED")
+		}
+	}
+	return o, nil
+}
+
+func (p *PaperTradingAPI) findLocked(symbol string, orderId *int64, origClientOrderId *string) (*paperOrder, bool) {
+	if orderId != nil {
+		o, ok := p.orders[*orderId]
+		if ok && o.symbol == symbol {
+			return o, true
+		}
+		return nil, false
+	}
+	if origClientOrderId != nil {
+		if id, ok := p.clientOrderIds[symbol+" "+*origClientOrderId]; ok {
+			return p.orders[id], true
+		}
+	}
+	return nil, false
+}
+
+func (p *PaperTradingAPI) cancelLocked(symbol string, orderId *int64, origClientOrderId *string) (*paperOrder, error) {
+	if orderId == nil && origClientOrderId == nil {
+		return nil, fmt.Errorf("paper: orderId or origClientOrderId required")
+	}
+	o, ok := p.findLocked(symbol, orderId, origClientOrderId)
+	if !ok || !o.isOpen() {
+		return nil, &APIError{StatusCode: 400, Code: -2011, Message: "Unknown order sent."}
+	}
+	// 撤销 OCO 的一条腿会同时撤销另一条
+	if sibling := o.linked; sibling != nil {
+		o.linked, sibling.linked = nil, nil
+		p.finish(sibling, OrderStatusCanceled, "CANCELED")
+	}
+	p.finish(o, OrderStatusCanceled, "CANCELED")
+	return o, nil
+}
+
+func (p *PaperTradingAPI) createNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*CreateOrderResponse, error) {
+	req, err := p.prepare(symbol, side, orderType, no)
+	if err != nil {
+		return nil, err
+	}
+	defer p.flush()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	o, err := p.placeLocked(req)
+	if err != nil {
+		return nil, err
+	}
+	return o.createResponse(resolveNewOrderRespType(orderType, no)), nil
+}
+
+func (p *PaperTradingAPI) cancelOrder(symbol string, co CancelOrder) (*binance_connector.CancelOrderResponse, error) {
+	defer p.flush()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	o, err := p.cancelLocked(symbol, co.orderId, co.origClientOrderId)
+	if err != nil {
+		return nil, err
+	}
+	response := o.cancelResponse()
+	if co.newClientOrderId != nil {
+		response.ClientOrderId = *co.newClientOrderId
+	}
+	return response, nil
+}
+
+// 撤单和下单在同一次加锁内完成，结果按交易所的格式返回
+func (p *PaperTradingAPI) cancelReplace(symbol string, side OrderSide, orderType OrderType, cancelReplaceMode CancelReplaceMode, cr CancelReplace) (*binance_connector.CancelReplaceResponse, error) {
+	if err := validateCancelReplace(side, orderType, cancelReplaceMode, cr); err != nil {
+		return nil, err
+	}
+	no := cancelReplaceNewOrder(cr)
+	req, prepareErr := p.prepare(symbol, side, orderType, no)
+
+	defer p.flush()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// 匿名结构体无法直接构造，先通过 JSON 分配
+	response := new(binance_connector.CancelReplaceResponse)
+	if err := json.Unmarshal([]byte(`{"cancelResponse":{},"newOrderResponse":{}}`), response); err != nil {
+		return nil, err
+	}
+
+	canceled, cancelErr := p.cancelLocked(symbol, cr.cancelOrderId, cr.cancelOrigClientOrderId)
+	if cancelErr != nil {
+		response.CancelResult = "FAILURE"
+		response.CancelResponse.Msg = cancelErr.Error()
+		if cancelReplaceMode == CancelReplaceStopOnFailure {
+			return nil, cancelErr
+		}
+	} else {
+		response.CancelResult = "SUCCESS"
+		c := canceled.cancelResponse()
+		response.CancelResponse.Symbol = c.Symbol
+		response.CancelResponse.OrigClientOrderId = c.OrigClientOrderId
+		response.CancelResponse.OrderId = c.OrderId
+		response.CancelResponse.OrderListId = c.OrderListId
+		response.CancelResponse.ClientOrderId = c.ClientOrderId
+		if cr.cancelNewClientOrderId != nil {
+			response.CancelResponse.ClientOrderId = *cr.cancelNewClientOrderId
+		}
+		response.CancelResponse.Price = c.Price
+		response.CancelResponse.OrigQty = c.OrigQty
+		response.CancelResponse.ExecutedQty = c.ExecutedQty
+		response.CancelResponse.CumulativeQuoteQty = c.CumulativeQuoteQty
+		response.CancelResponse.Status = c.Status
+		response.CancelResponse.TimeInForce = c.TimeInForce
+		response.CancelResponse.Type = c.Type
+		response.CancelResponse.Side = c.Side
+		response.CancelResponse.SelfTradePreventionMode = c.SelfTradePrevention
+	}
+
+	newErr := prepareErr
+	var o *paperOrder
+	if newErr == nil {
+		o, newErr = p.placeLocked(req)
+	}
+	if newErr != nil {
+		response.NewOrderResult = "FAILURE"
+		response.NewOrderResponse.Msg = newErr.Error()
+	} else {
+		response.NewOrderResult = "SUCCESS"
+		n := o.createResponse(NewOrderRespRESULT)
+		response.NewOrderResponse.Symbol = n.Symbol
+		response.NewOrderResponse.OrderId = n.OrderId
+		response.NewOrderResponse.OrderListId = n.OrderListId
+		response.NewOrderResponse.ClientOrderId = n.ClientOrderId
+		response.NewOrderResponse.TransactTime = n.TransactTime
+		response.NewOrderResponse.Price = n.Price.String()
+		response.NewOrderResponse.OrigQty = n.OrigQty.String()
+		response.NewOrderResponse.ExecutedQty = n.ExecutedQty.String()
+		response.NewOrderResponse.CumulativeQuoteQty = n.CumulativeQuoteQty.String()
+		response.NewOrderResponse.Status = n.Status
+		response.NewOrderResponse.TimeInForce = n.TimeInForce
+		response.NewOrderResponse.Type = n.Type
+		response.NewOrderResponse.Side = n.Side
+		response.NewOrderResponse.SelfTradePreventionMode = n.SelfTradePreventionMode
+	}
+
+	if cancelErr != nil || newErr != nil {
+		response.Code = -2021
+		response.Msg = "Order cancel-replace partially failed."
+		if cancelErr != nil && newErr != nil {
+			response.Code = -2022
+			response.Msg = "Order cancel-replace failed."
+		}
+		return response, &APIError{StatusCode: 409, Code: response.Code, Message: response.Msg}
+	}
+	return response, nil
+}
+
+// 只能减少数量，排队位置不变
+func (p *PaperTradingAPI) amendOrderKeepPriority(symbol string, newQty Decimal, ao AmendOrder) (*AmendOrderResponse, error) {
+	defer p.flush()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	o, ok := p.findLocked(symbol, ao.orderId, ao.origClientOrderId)
+	if !ok || !o.isOpen() {
+		return nil, &APIError{StatusCode: 400, Code: -2013, Message: "Order does not exist."}
+	}
+	if !newQty.LessThan(o.origQty) || !newQty.GreaterThan(o.executedQty) {
+		return nil, fmt.Errorf("paper: newQty %s must be below origQty %s and above executedQty %s", newQty, o.origQty, o.executedQty)
+	}
+
+	if o.locked.Sign() > 0 {
+		keep := newQty.Sub(o.executedQty)
+		if o.side == SideBuy {
+			var err error
+			if keep, err = o.locked.Mul(keep).CheckedDiv(o.remainingQty()); err != nil {
+				return nil, err
+			}
+		}
+		release := o.locked.Sub(keep)
+		asset := o.baseAsset
+		if o.side == SideBuy {
+			asset = o.quoteAsset
+		}
+		b := p.balance(asset)
+		b.locked = b.locked.Sub(release)
+		b.free = b.free.Add(release)
+		o.locked = keep
+	}
+	origClientOrderId := o.clientOrderId
+	if ao.newClientOrderId != nil {
+		delete(p.clientOrderIds, o.symbol+" "+o.clientOrderId)
+		o.clientOrderId = *ao.newClientOrderId
+		p.clientOrderIds[o.symbol+" "+o.clientOrderId] = o.orderId
+	}
+	o.origQty = newQty
+	o.updateTime = uint64(time.Now().UnixMilli())
+	p.report(o, "REPLACED", nil, false)
+
+	response := &AmendOrderResponse{TransactTime: o.updateTime}
+	response.AmendedOrder.Symbol = o.symbol
+	response.AmendedOrder.OrderId = o.orderId
+	response.AmendedOrder.OrderListId = o.orderListId
+	response.AmendedOrder.OrigClientOrderId = origClientOrderId
+	response.AmendedOrder.ClientOrderId = o.clientOrderId
+	response.AmendedOrder.Price = o.price
+	response.AmendedOrder.Qty = o.origQty
+	response.AmendedOrder.ExecutedQty = o.executedQty
+	response.AmendedOrder.QuoteOrderQty = o.quoteOrderQty
+	response.AmendedOrder.CumulativeQuoteQty = o.cumulativeQuoteQty
+	response.AmendedOrder.Status = string(o.status)
+	response.AmendedOrder.TimeInForce = string(o.timeInForce)
+	response.AmendedOrder.Type = string(o.orderType)
+	response.AmendedOrder.Side = string(o.side)
+	response.AmendedOrder.WorkingTime = o.workingTime
+	response.AmendedOrder.SelfTradePreventionMode = o.stpMode
+	return response, nil
+}
+
+func (p *PaperTradingAPI) getQueryOrder(symbol string, qo QueryOrder) (*binance_connector.GetOrderResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	o, ok := p.findLocked(symbol, qo.orderId, qo.origClientOrderId)
+	if !ok {
+		return nil, &APIError{StatusCode: 400, Code: -2013, Message: "Order does not exist."}
+	}
+	return o.queryResponse(), nil
+}
+
+// symbol 为空时返回所有交易对的挂单
+func (p *PaperTradingAPI) getCurrentOpenOrders(symbol string) ([]*binance_connector.NewOpenOrdersResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var open []*paperOrder
+	for _, o := range p.orders {
+		if o.isOpen() && (symbol == "" || o.symbol == symbol) {
+			open = append(open, o)
+		}
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].orderId < open[j].orderId })
+	openOrders := make([]*binance_connector.NewOpenOrdersResponse, 0, len(open))
+	for _, o := range open {
+		q := o.queryResponse()
+		openOrders = append(openOrders, &binance_connector.NewOpenOrdersResponse{
+			Symbol:                  q.Symbol,
+			OrderId:                 q.OrderId,
+			OrderListId:             q.OrderListId,
+			ClientOrderId:           q.ClientOrderId,
+			Price:                   q.Price,
+			OrigQty:                 q.OrigQty,
+			ExecutedQty:             q.ExecutedQty,
+			CumulativeQuoteQty:      q.CumulativeQuoteQty,
+			Status:                  q.Status,
+			TimeInForce:             q.TimeInForce,
+			Type:                    q.Type,
+			Side:                    q.Side,
+			StopPrice:               q.StopPrice,
+			Time:                    q.Time,
+			UpdateTime:              q.UpdateTime,
+			IsWorking:               q.IsWorking,
+			WorkingTime:             q.WorkingTime,
+			OrigQuoteOrderQty:       q.OrigQuoteOrderQty,
+			SelfTradePreventionMode: q.SelfTradePreventionMode,
+		})
+	}
+	return openOrders, nil
+}
+
+// 虚拟余额，手续费率按 bps 的万分之一返回，与交易所一致
+func (p *PaperTradingAPI) getAccountInformation(ai AccountInformation) (*binance_connector.AccountResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	assets := make([]string, 0, len(p.balances))
+	for asset := range p.balances {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+
+	bps := newDecimalFromInt(10000)
+	account := &binance_connector.AccountResponse{
+		MakerCommission: int64(p.config.makerCommission.Mul(bps).Float64()),
+		TakerCommission: int64(p.config.takerCommission.Mul(bps).Float64()),
+		CanTrade:        true,
+		UpdateTime:      uint64(time.Now().UnixMilli()),
+		AccountType:     "SPOT",
+		Permissions:     []string{"SPOT"},
+	}
+	for _, asset := range assets {
+		b := p.balances[asset]
+		if ai.omitZeroBalances && b.free.IsZero() && b.locked.IsZero() {
+			continue
+		}
+		account.Balances = append(account.Balances, binance_connector.Balance{
+			Asset:  asset,
+			Free:   b.free.String(),
+			Locked: b.locked.String(),
+		})
+	}
+	return account, nil
+}
+
+// OCO：两条腿共用一份冻结（取两者较大值），一条腿触发或成交后另一条过期
+func (p *PaperTradingAPI) createNewOCO(symbol string, side OrderSide, quantity Decimal, oco NewOCO) (*OrderListResponse, error) {
+	legs := []OrderListLeg{oco.above, oco.below}
+	reqs := make([]*paperRequest, 0, len(legs))
+	for _, leg := range legs {
+		req, err := p.prepare(symbol, side, leg.orderType, NewOrder{
+			quantity:                &quantity,
+			price:                   leg.price,
+			stopPrice:               leg.stopPrice,
+			timeInForce:             leg.timeInForce,
+			newClientOrderId:        leg.clientOrderId,
+			selfTradePreventionMode: oco.selfTradePreventionMode,
+		})
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+
+	defer p.flush()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var need Decimal
+	for _, req := range reqs {
+		var price, stopPrice Decimal
+		if req.no.price != nil {
+			price = *req.no.price
+		}
+		if req.no.stopPrice != nil {
+			stopPrice = *req.no.stopPrice
+		}
+		if legNeed := paperLockNeed(side, price, stopPrice, quantity); legNeed.GreaterThan(need) {
+			need = legNeed
+		}
+	}
+	asset := reqs[0].symbolInfo.BaseAsset
+	if side == SideBuy {
+		asset = reqs[0].symbolInfo.QuoteAsset
+	}
+	if p.balance(asset).free.LessThan(need) {
+		return nil, errInsufficientBalance()
+	}
+
+	// 第二条腿被拒绝时撤回第一条腿，不留下任何状态
+	nextOrderId, events := p.nextOrderId, len(p.events)
+	list := &paperOrderList{
+		orderListId: p.nextListId + 1,
+		symbol:      symbol,
+		time:        uint64(time.Now().UnixMilli()),
+	}
+	for _, req := range reqs {
+		req.listId = list.orderListId
+		o, err := p.placeLocked(req)
+		if err != nil {
+			for _, placed := range list.legs {
+				delete(p.orders, placed.orderId)
+				delete(p.clientOrderIds, placed.symbol+" "+placed.clientOrderId)
+			}
+			p.nextOrderId, p.events = nextOrderId, p.events[:events]
+			return nil, err
+		}
+		list.legs = append(list.legs, o)
+	}
+	p.nextListId = list.orderListId
+	list.listClientOrderId = fmt.Sprintf("paper_list_%d", list.orderListId)
+	if oco.listClientOrderId != nil {
+		list.listClientOrderId = *oco.listClientOrderId
+	}
+	p.orderLists[list.orderListId] = list
+	list.legs[0].linked, list.legs[1].linked = list.legs[1], list.legs[0]
+	p.lockLocked(list.legs[0], need)
+	return list.response("EXEC_STARTED", "EXECUTING"), nil
+}
+
+// 撤销整个订单列表
+func (p *PaperTradingAPI) cancelOrderList(symbol string, col CancelOrderList) (*OrderListResponse, error) {
+	if col.orderListId == nil && col.listClientOrderId == nil {
+		return nil, fmt.Errorf("paper: orderListId or listClientOrderId required")
+	}
+	defer p.flush()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var list *paperOrderList
+	for _, l := range p.orderLists {
+		if l.symbol == symbol && ((col.orderListId != nil && l.orderListId == *col.orderListId) ||
+			(col.orderListId == nil && l.listClientOrderId == *col.listClientOrderId)) {
+			list = l
+			break
+		}
+	}
+	if list == nil || !list.legs[0].isOpen() && !list.legs[1].isOpen() {
+		return nil, &APIError{StatusCode: 400, Code: -2011, Message: "Unknown order list sent."}
+	}
+	list.legs[0].linked, list.legs[1].linked = nil, nil
+	for _, o := range list.legs {
+		if o.isOpen() {
+			p.finish(o, OrderStatusCanceled, "CANCELED")
+		}
+	}
+	return list.response("ALL_DONE", "ALL_DONE"), nil
+}
+
+func (l *paperOrderList) response(listStatusType, listOrderStatus string) *OrderListResponse {
+	response := &OrderListResponse{
+		OrderListId:       l.orderListId,
+		ContingencyType:   "OCO",
+		ListStatusType:    listStatusType,
+		ListOrderStatus:   listOrderStatus,
+		ListClientOrderId: l.listClientOrderId,
+		TransactionTime:   uint64(time.Now().UnixMilli()),
+		Symbol:            l.symbol,
+	}
+	for _, o := range l.legs {
+		response.Orders = append(response.Orders, OrderListOrder{Symbol: o.symbol, OrderId: o.orderId, ClientOrderId: o.clientOrderId})
+		response.OrderReports = append(response.OrderReports, OrderListReport{
+			Symbol:                  o.symbol,
+			OrderId:                 o.orderId,
+			OrderListId:             o.orderListId,
+			ClientOrderId:           o.clientOrderId,
+			TransactTime:            o.updateTime,
+			Price:                   o.price,
+			OrigQty:                 o.origQty,
+			ExecutedQty:             o.executedQty,
+			CumulativeQuoteQty:      o.cumulativeQuoteQty,
+			Status:                  string(o.status),
+			TimeInForce:             string(o.timeInForce),
+			Type:                    string(o.orderType),
+			Side:                    string(o.side),
+			StopPrice:               o.stopPrice,
+			WorkingTime:             int64(o.workingTime),
+			SelfTradePreventionMode: o.stpMode,
+		})
+	}
+	return response
+}
+
+func (o *paperOrder) createResponse(respType NewOrderRespType) *CreateOrderResponse {
+	response := &CreateOrderResponse{
+		Symbol:        o.symbol,
+		OrderId:       o.orderId,
+		OrderListId:   o.orderListId,
+		ClientOrderId: o.clientOrderId,
+		TransactTime:  o.time,
+		RespType:      respType,
+	}
+	if respType == NewOrderRespACK {
+		return response
+	}
+	response.Price = o.price
+	response.OrigQty = o.origQty
+	response.ExecutedQty = o.executedQty
+	response.OrigQuoteOrderQty = o.quoteOrderQty
+	response.CumulativeQuoteQty = o.cumulativeQuoteQty
+	response.Status = string(o.status)
+	response.TimeInForce = string(o.timeInForce)
+	response.Type = string(o.orderType)
+	response.Side = string(o.side)
+	response.StopPrice = o.stopPrice
+	response.WorkingTime = int64(o.workingTime)
+	response.SelfTradePreventionMode = o.stpMode
+	if respType == NewOrderRespFULL {
+		response.Fills = append([]OrderFill{}, o.fills...)
+	}
+	return response
+}
+
+func (o *paperOrder) cancelResponse() *binance_connector.CancelOrderResponse {
+	return &binance_connector.CancelOrderResponse{
+		Symbol:              o.symbol,
+		OrigClientOrderId:   o.clientOrderId,
+		OrderId:             o.orderId,
+		OrderListId:         o.orderListId,
+		ClientOrderId:       o.clientOrderId,
+		Price:               o.price.String(),
+		OrigQty:             o.origQty.String(),
+		ExecutedQty:         o.executedQty.String(),
+		CumulativeQuoteQty:  o.cumulativeQuoteQty.String(),
+		Status:              string(o.status),
+		TimeInForce:         string(o.timeInForce),
+		Type:                string(o.orderType),
+		Side:                string(o.side),
+		SelfTradePrevention: o.stpMode,
+		StopPrice:           o.stopPrice.String(),
+	}
+}
+
+func (o *paperOrder) queryResponse() *binance_connector.GetOrderResponse {
+	return &binance_connector.GetOrderResponse{
+		Symbol:                  o.symbol,
+		OrderId:                 o.orderId,
+		OrderListId:             o.orderListId,
+		ClientOrderId:           o.clientOrderId,
+		Price:                   o.price.String(),
+		OrigQty:                 o.origQty.String(),
+		ExecutedQty:             o.executedQty.String(),
+		CumulativeQuoteQty:      o.cumulativeQuoteQty.String(),
+		Status:                  string(o.status),
+		TimeInForce:             string(o.timeInForce),
+		Type:                    string(o.orderType),
+		Side:                    string(o.side),
+		StopPrice:               o.stopPrice.String(),
+		Time:                    o.time,
+		UpdateTime:              o.updateTime,
+		IsWorking:               o.working,
+		WorkingTime:             o.workingTime,
+		OrigQuoteOrderQty:       o.quoteOrderQty.String(),
+		SelfTradePreventionMode: o.stpMode,
+	}
+}
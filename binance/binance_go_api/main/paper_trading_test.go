@@ -0,0 +1,163 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 固定盘口的模拟盘，不访问网络
+func testPaperTradingAPI(t *testing.T, balances map[string]string, bids, asks [][2]string) *PaperTradingAPI {
+	t.Helper()
+	config := PaperTradingConfig{balances: make(map[string]Decimal)}
+	for asset, amount := range balances {
+		config.balances[asset] = mustDecimal(amount)
+	}
+	p, err := newPaperTradingAPI(testSymbolRegistry(testSymbolDetail()), config, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	toLevels := func(rows [][2]string) []paperLevel {
+		levels := make([]paperLevel, 0, len(rows))
+		for _, row := range rows {
+			levels = append(levels, paperLevel{price: mustDecimal(row[0]), qty: mustDecimal(row[1])})
+		}
+		return levels
+	}
+	p.orderBook = func(symbol string) ([]paperLevel, []paperLevel, error) {
+		return toLevels(bids), toLevels(asks), nil
+	}
+	return p
+}
+
+func assertPaperBalance(t *testing.T, p *PaperTradingAPI, asset, free, locked string) {
+	t.Helper()
+	p.mu.Lock()
+	b := p.balance(asset)
+	p.mu.Unlock()
+	if !b.free.Equal(mustDecimal(free)) || !b.locked.Equal(mustDecimal(locked)) {
+		t.Errorf("%s free %s locked %s, want %s / %s", asset, b.free, b.locked, free, locked)
+	}
+}
+
+// 市场成交时间要晚于下单时间才会撮合
+func testMarketTrade(id uint64, price, qty string) MarketTrade {
+	return MarketTrade{Id: id, Price: mustDecimal(price), Qty: mustDecimal(qty), Time: uint64(time.Now().UnixMilli() + 1000)}
+}
+
+func TestPaperTradingLimitOrderBalances(t *testing.T) {
+	p := testPaperTradingAPI(t, map[string]string{"USDT": "1000"},
+		[][2]string{{"29990", "1"}}, [][2]string{{"30000", "0.001"}, {"30010", "1"}})
+	gtc := TimeInForceGTC
+
+	// 吃掉 30000 的 0.001，剩余 0.002 挂单，冻结剩余部分的计价币
+	order, err := p.createNewOrder("BTCUSDT", SideBuy, OrderTypeLimit, NewOrder{price: decimalPtr("30000"), quantity: decimalPtr("0.003"), timeInForce: &gtc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if OrderStatus(order.Status) != OrderStatusPartiallyFilled || order.ExecutedQty.String() != "0.001" {
+		t.Fatalf("order = %s executed %s, want PARTIALLY_FILLED 0.001", order.Status, order.ExecutedQty)
+	}
+	assertPaperBalance(t, p, "USDT", "910", "60")
+	assertPaperBalance(t, p, "BTC", "0.000999", "0") // 吃单手续费 0.1% 从收到的 BTC 中扣除
+
+	// 只触及挂单价不成交，穿过后按挂单价成交
+	p.onTrades("BTCUSDT", []MarketTrade{testMarketTrade(1, "30000", "1")})
+	assertPaperBalance(t, p, "USDT", "910", "60")
+	p.onTrades("BTCUSDT", []MarketTrade{testMarketTrade(2, "29999.99", "1")})
+	assertPaperBalance(t, p, "USDT", "910", "0")
+	assertPaperBalance(t, p, "BTC", "0.002997", "0")
+
+	// 卖单吃买一，手续费从收到的 USDT 中扣除
+	if _, err := p.createNewOrder("BTCUSDT", SideSell, OrderTypeLimit, NewOrder{price: decimalPtr("29990"), quantity: decimalPtr("0.001"), timeInForce: &gtc}); err != nil {
+		t.Fatal(err)
+	}
+	assertPaperBalance(t, p, "USDT", "939.96001", "0")
+	assertPaperBalance(t, p, "BTC", "0.001997", "0")
+
+	// 余额不足时拒绝，不改变余额
+	if _, err := p.createNewOrder("BTCUSDT", SideBuy, OrderTypeLimit, NewOrder{price: decimalPtr("29000"), quantity: decimalPtr("1"), timeInForce: &gtc}); err == nil {
+		t.Error("order above balance should be rejected")
+	}
+	assertPaperBalance(t, p, "USDT", "939.96001", "0")
+}
+
+func TestPaperTradingMarketOrderWalksBook(t *testing.T) {
+	p := testPaperTradingAPI(t, map[string]string{"USDT": "1000"},
+		[][2]string{{"29990", "1"}}, [][2]string{{"30000", "0.001"}, {"30010", "1"}})
+	order, err := p.createNewOrder("BTCUSDT", SideBuy, OrderTypeMarket, NewOrder{quantity: decimalPtr("0.002")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if OrderStatus(order.Status) != OrderStatusFilled || order.CumulativeQuoteQty.String() != "60.01" {
+		t.Fatalf("order = %s quote %s, want FILLED 60.01", order.Status, order.CumulativeQuoteQty)
+	}
+	assertPaperBalance(t, p, "USDT", "939.99", "0")
+	assertPaperBalance(t, p, "BTC", "0.001998", "0")
+}
+
+func TestPaperTradingOCO(t *testing.T) {
+	p := testPaperTradingAPI(t, map[string]string{"BTC": "0.01"},
+		[][2]string{{"29990", "1"}}, [][2]string{{"30010", "1"}})
+	list, err := p.createNewOCO("BTCUSDT", SideSell, mustDecimal("0.01"), NewOCO{
+		above: OrderListLeg{orderType: OrderTypeLimitMaker, price: decimalPtr("31000")},
+		below: OrderListLeg{orderType: OrderTypeStopLoss, stopPrice: decimalPtr("29000")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Orders) != 2 {
+		t.Fatalf("orders = %+v, want 2 legs", list.Orders)
+	}
+	// 两条腿共用一份冻结
+	assertPaperBalance(t, p, "BTC", "0", "0.01")
+
+	// 止损触发后按触发价成交，止盈腿过期
+	p.onTrades("BTCUSDT", []MarketTrade{testMarketTrade(1, "28990", "1")})
+	assertPaperBalance(t, p, "BTC", "0", "0")
+	assertPaperBalance(t, p, "USDT", "289.6101", "0")
+	open, err := p.getCurrentOpenOrders("BTCUSDT")
+	if err != nil || len(open) != 0 {
+		t.Errorf("open orders = %d (%v), want 0", len(open), err)
+	}
+	if _, err := p.cancelOrderList("BTCUSDT", CancelOrderList{orderListId: &list.OrderListId}); err == nil {
+		t.Error("canceling a finished OCO should fail")
+	}
+}
+
+// 模拟盘的推送异步投递，网格在回调中下单不会死锁
+func TestPaperTradingGridStop(t *testing.T) {
+	p := testPaperTradingAPI(t, map[string]string{"USDT": "1000", "BTC": "0.01"},
+		[][2]string{{"29995", "1"}}, [][2]string{{"30005", "1"}})
+	tracker := newOrderTracker()
+	p.setOnExecutionReport(tracker.onExecutionReport)
+	g := testGridBot(t, p, tracker)
+	if err := g.start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 29500 的买单成交后网格在 30000 挂卖单
+	p.onTrades("BTCUSDT", []MarketTrade{testMarketTrade(1, "29400", "0.001")})
+	p.waitReports()
+	if report := g.report(); report.Buys != 1 || report.OpenBuys != 1 || report.OpenSells != 3 {
+		t.Fatalf("report = %+v, want 1 buy filled, 1 open buy and 3 open sells", report)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- g.stop(true) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("grid stop deadlocked")
+	}
+	p.waitReports()
+
+	open, err := p.getCurrentOpenOrders("BTCUSDT")
+	if err != nil || len(open) != 0 {
+		t.Errorf("open orders = %d (%v), want 0", len(open), err)
+	}
+	assertPaperBalance(t, p, "USDT", "970.5", "0")
+	assertPaperBalance(t, p, "BTC", "0.010999", "0")
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 市场成交，来自 getRecentTradeList 或成交推送
+type MarketTrade struct {
+	Id    uint64
+	Price Decimal
+	Qty   Decimal
+	Time  uint64
+}
+
+// POV 配置
+type POVConfig struct {
+	symbol         string
+	side           OrderSide
+	quantity       Decimal
+	participation  Decimal       // 目标参与率，例如 0.1 表示成交量占其他参与者成交量的 10%
+	maxDuration    time.Duration // 到期后停止，为 0 时直到成交完
+	orderType      OrderType     // LIMIT 或 MARKET
+	limitPrice     *Decimal      // 买入最高价/卖出最低价，超出的市场成交不计入参与量
+	marketOnFinish bool          // 到期时剩余数量用市价单补齐
+	streamTrades   bool          // 为 true 时不轮询 getRecentTradeList，由调用方通过 onTrade 推送成交
+}
+
+// POV 执行器：按开始后市场已实现的成交量跟随下单，已成交数量保持在 participation * 市场成交量
+// 市场成交量不包括自己子单的成交，自己的成交 id 从 tracker 的成交回报中取得
+type POVExecutor struct {
+	*algoExecution
+	config POVConfig
+
+	tradesMu       sync.Mutex
+	startMs        uint64
+	lastTradeId    uint64
+	eligibleVolume Decimal                 // 限价内的市场成交量，用于计算参与量
+	marketVolume   Decimal                 // 全部市场成交量
+	marketQuote    Decimal                 // 全部市场成交额，用于计算区间 VWAP
+	recent         map[uint64]MarketTrade  // POV_OWN_FILL_WINDOW 内已计入的市场成交，自己的成交回报晚到时从中扣除
+	ownTrades      map[uint64]bool         // 自己子单的成交 id，不计入市场成交量
+	fills          map[int64][]TrackedFill // 该交易对同方向的成交回报，按 orderId 暂存，匹配到子单后扣除
+}
+
+// tracker 需要接入用户数据流，用来识别自己子单的成交
+func newPOVExecutor(
+	api TradingAPI,
+	registry *SymbolRegistry,
+	ids *ClientOrderIdGenerator,
+	tracker *OrderTracker,
+	config POVConfig,
+	proxyURL string,
+) (*POVExecutor, error) {
+	if tracker == nil {
+		return nil, fmt.Errorf("pov: tracker is required to exclude own fills from market volume")
+	}
+	if config.quantity.Sign() <= 0 {
+		return nil, fmt.Errorf("pov: quantity must be positive")
+	}
+	if config.participation.Sign() <= 0 || !config.participation.LessThan(newDecimalFromInt(1)) {
+		return nil, fmt.Errorf("pov: participation must be between 0 and 1, got %s", config.participation)
+	}
+	if config.orderType != OrderTypeLimit && config.orderType != OrderTypeMarket {
+		return nil, fmt.Errorf("pov: orderType must be LIMIT or MARKET, got %s", config.orderType)
+	}
+	if !config.side.isValid() {
+		return nil, fmt.Errorf("pov: invalid side %q", config.side)
+	}
+	p := &POVExecutor{
+		algoExecution: newAlgoExecution(api, registry, ids, config.symbol, config.side, config.quantity, proxyURL),
+		config:        config,
+		recent:        make(map[uint64]MarketTrade),
+		ownTrades:     make(map[uint64]bool),
+		fills:         make(map[int64][]TrackedFill),
+	}
+	tracker.subscribe(p.onTransition)
+	return p, nil
+}
+
+// 暂存成交回报；回报可能早于 createNewOrder 返回，所以在 run 中再按子单匹配
+func (p *POVExecutor) onTransition(transition OrderTransition) {
+	if transition.Fill == nil || transition.Order.Symbol != p.config.symbol || transition.Order.Side != p.config.side {
+		return
+	}
+	p.tradesMu.Lock()
+	defer p.tradesMu.Unlock()
+	if p.startMs == 0 {
+		return
+	}
+	p.fills[transition.Order.OrderId] = append(p.fills[transition.Order.OrderId], *transition.Fill)
+}
+
+// 把子单的成交从市场成交量中扣除，丢弃过期的市场成交和其他订单的成交回报
+func (p *POVExecutor) excludeOwnFills() {
+	p.mu.Lock()
+	children := make(map[int64]bool, len(p.children))
+	for _, child := range p.children {
+		children[child.OrderId] = true
+	}
+	p.mu.Unlock()
+
+	p.tradesMu.Lock()
+	defer p.tradesMu.Unlock()
+	expire := time.Now().Add(-initConfig.POV_OWN_FILL_WINDOW).UnixMilli()
+	for id, trade := range p.recent {
+		if int64(trade.Time) < expire {
+			delete(p.recent, id)
+		}
+	}
+	for orderId, fills := range p.fills {
+		if !children[orderId] {
+			if fills[len(fills)-1].Time < expire {
+				delete(p.fills, orderId)
+			}
+			continue
+		}
+		for _, fill := range fills {
+			p.excludeLocked(uint64(fill.TradeId))
+		}
+		delete(p.fills, orderId)
+	}
+}
+
+func (p *POVExecutor) excludeLocked(tradeId uint64) {
+	if p.ownTrades[tradeId] {
+		return
+	}
+	p.ownTrades[tradeId] = true
+	trade, ok := p.recent[tradeId]
+	if !ok {
+		return
+	}
+	delete(p.recent, tradeId)
+	p.marketVolume = p.marketVolume.Sub(trade.Qty)
+	p.marketQuote = p.marketQuote.Sub(trade.Qty.Mul(trade.Price))
+	if p.config.limitPrice == nil || p.withinLimit(trade.Price, *p.config.limitPrice) {
+		p.eligibleVolume = p.eligibleVolume.Sub(trade.Qty)
+	}
+}
+
+// 在后台开始执行，开始前的市场成交不计入
+func (p *POVExecutor) start() error {
+	p.tradesMu.Lock()
+	p.startMs = uint64(time.Now().UnixMilli())
+	p.tradesMu.Unlock()
+	return p.launch(p.run)
+}
+
+// 推送一笔市场成交，按成交 id 去重
+func (p *POVExecutor) onTrade(trade MarketTrade) {
+	p.tradesMu.Lock()
+	defer p.tradesMu.Unlock()
+	if p.startMs == 0 || trade.Time < p.startMs || trade.Id <= p.lastTradeId {
+		return
+	}
+	p.lastTradeId = trade.Id
+	if p.ownTrades[trade.Id] {
+		return
+	}
+	p.marketVolume = p.marketVolume.Add(trade.Qty)
+	p.marketQuote = p.marketQuote.Add(trade.Qty.Mul(trade.Price))
+	if p.config.limitPrice == nil || p.withinLimit(trade.Price, *p.config.limitPrice) {
+		p.eligibleVolume = p.eligibleVolume.Add(trade.Qty)
+	}
+	p.recent[trade.Id] = trade
+}
+
+// 轮询最近成交
+func (p *POVExecutor) pollTrades() error {
+	limit := initConfig.RECENT_TRADES_LIMIT
+	trades, err := getRecentTradeList("", "", p.config.symbol, &limit, p.proxyURL)
+	if err != nil {
+		return err
+	}
+	for _, trade := range trades {
+		p.onTrade(MarketTrade{
+			Id:    trade.Id,
+			Price: decimalOrZero(trade.Price),
+			Qty:   decimalOrZero(trade.Qty),
+			Time:  trade.Time,
+		})
+	}
+	return nil
+}
+
+func (p *POVExecutor) run() error {
+	startTime := time.Now()
+	for {
+		if !p.waitUntil(time.Now().Add(initConfig.POV_POLL_INTERVAL)) {
+			return nil
+		}
+		if !p.config.streamTrades {
+			// 行情请求失败时等下一轮，不中断执行
+			if err := p.pollTrades(); err != nil {
+				fmt.Println("pov:", err)
+			}
+		}
+
+		p.excludeOwnFills()
+		p.tradesMu.Lock()
+		due := p.eligibleVolume.Mul(p.config.participation)
+		p.tradesMu.Unlock()
+		if due.GreaterThan(p.config.quantity) {
+			due = p.config.quantity
+		}
+		if err := p.catchUp(due, p.config.orderType, p.config.limitPrice); err != nil {
+			return err
+		}
+		if !p.filled().LessThan(p.config.quantity) {
+			return nil
+		}
+		if p.config.maxDuration > 0 && time.Since(startTime) >= p.config.maxDuration {
+			return p.finishRemaining(p.config.marketOnFinish, p.config.limitPrice)
+		}
+	}
+}
+
+// 等待执行结束，返回以执行期间市场成交 VWAP 为基准的报告，同时给出实际参与率
+// 基准和参与率都只按其他参与者的成交计算
+func (p *POVExecutor) report() (AlgoReport, Decimal) {
+	<-p.doneCh
+	p.tradesMu.Lock()
+	marketVolume, marketQuote := p.marketVolume, p.marketQuote
+	p.tradesMu.Unlock()
+
+	var benchmark Decimal
+	if !marketVolume.IsZero() {
+		benchmark = marketQuote.Div(marketVolume)
+	}
+	report := p.buildReport("INTERVAL_VWAP", benchmark)
+	var participation Decimal
+	if !marketVolume.IsZero() {
+		participation = report.FilledQty.Div(marketVolume)
+	}
+	return report, participation
+}
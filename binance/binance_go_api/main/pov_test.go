@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPOVExcludesOwnFills(t *testing.T) {
+	tracker := newOrderTracker()
+	p, err := newPOVExecutor(nil, nil, nil, tracker, POVConfig{
+		symbol:        "BTCUSDT",
+		side:          SideBuy,
+		quantity:      mustDecimal("10"),
+		participation: mustDecimal("0.1"),
+		orderType:     OrderTypeMarket,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := uint64(time.Now().UnixMilli())
+	p.startMs = now - 1000
+	p.children = []*ChildOrder{{OrderId: 7}}
+
+	own := func(tradeId int64, qty string) {
+		p.onTransition(OrderTransition{
+			Order: TrackedOrder{Symbol: "BTCUSDT", OrderId: 7, Side: SideBuy},
+			Fill:  &TrackedFill{TradeId: tradeId, Price: mustDecimal("100"), Qty: mustDecimal(qty), Time: int64(now)},
+		})
+	}
+	// 2 号成交先作为市场成交计入，之后才收到自己的成交回报
+	p.onTrade(MarketTrade{Id: 1, Price: mustDecimal("100"), Qty: mustDecimal("5"), Time: now})
+	p.onTrade(MarketTrade{Id: 2, Price: mustDecimal("100"), Qty: mustDecimal("1"), Time: now})
+	own(2, "1")
+	// 3 号成交的回报先到，市场成交到达时直接跳过
+	own(3, "2")
+	p.excludeOwnFills()
+	p.onTrade(MarketTrade{Id: 3, Price: mustDecimal("100"), Qty: mustDecimal("2"), Time: now})
+	p.onTrade(MarketTrade{Id: 4, Price: mustDecimal("101"), Qty: mustDecimal("3"), Time: now})
+	// 其他订单的成交不扣除
+	p.onTransition(OrderTransition{
+		Order: TrackedOrder{Symbol: "BTCUSDT", OrderId: 8, Side: SideBuy},
+		Fill:  &TrackedFill{TradeId: 4, Price: mustDecimal("101"), Qty: mustDecimal("3"), Time: int64(now)},
+	})
+	p.excludeOwnFills()
+
+	if !p.marketVolume.Equal(mustDecimal("8")) || !p.eligibleVolume.Equal(mustDecimal("8")) {
+		t.Errorf("market volume = %s, eligible = %s, want 8", p.marketVolume, p.eligibleVolume)
+	}
+	if !p.marketQuote.Equal(mustDecimal("803")) {
+		t.Errorf("market quote = %s, want 803", p.marketQuote)
+	}
+}
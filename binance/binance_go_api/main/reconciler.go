@@ -0,0 +1,217 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"binance_connector"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 启动对账配置
+type ReconcileConfig struct {
+	symbols             []string
+	clientOrderIdPrefix string        // 本程序下单时使用的 newClientOrderId 前缀，其他前缀的挂单视为孤儿
+	lookback            time.Duration // 回看历史订单和成交的时长，为 0 时使用 RECONCILE_LOOKBACK
+	cancelOrphans       bool          // 是否撤销孤儿挂单
+}
+
+// 账户余额
+type ReconciledBalance struct {
+	Free   Decimal
+	Locked Decimal
+}
+
+// 对账结果
+type ReconcileResult struct {
+	OpenOrders      map[string][]TrackedOrder                                // 本程序的挂单
+	Orphans         map[string][]*binance_connector.NewOpenOrdersResponse    // 不属于本程序的挂单
+	CanceledOrphans []*binance_connector.CancelOrderResponse                 // 已撤销的孤儿挂单
+	Trades          map[string][]*binance_connector.AccountTradeListResponse // 本程序订单在回看期内的成交
+	Balances        map[string]ReconciledBalance                             // 非零余额
+}
+
+// 重启后根据交易所数据重建本地状态
+type Reconciler struct {
+	apiKey    string
+	secretKey string
+	proxyURL  string
+	config    ReconcileConfig
+	tracker   *OrderTracker
+}
+
+// tracker 为空时新建一个；没有前缀时所有挂单都是孤儿，不允许撤销孤儿
+func newReconciler(
+	thisApiKey,
+	thisSecretKey string,
+	config ReconcileConfig,
+	tracker *OrderTracker,
+	proxyURL string,
+) (*Reconciler, error) {
+	if config.cancelOrphans && config.clientOrderIdPrefix == "" {
+		return nil, fmt.Errorf("reconcile: cancelOrphans requires a clientOrderIdPrefix")
+	}
+	if config.lookback <= 0 {
+		config.lookback = initConfig.RECONCILE_LOOKBACK
+	}
+	if tracker == nil {
+		tracker = newOrderTracker()
+	}
+	return &Reconciler{
+		apiKey:    thisApiKey,
+		secretKey: thisSecretKey,
+		proxyURL:  proxyURL,
+		config:    config,
+		tracker:   tracker,
+	}, nil
+}
+
+// 是否是本程序下的订单
+func (r *Reconciler) owns(clientOrderId string) bool {
+	return r.config.clientOrderIdPrefix != "" && strings.HasPrefix(clientOrderId, r.config.clientOrderIdPrefix)
+}
+
+// 对所有配置的交易对执行一次对账
+func (r *Reconciler) reconcile() (*ReconcileResult, error) {
+	result := &ReconcileResult{
+		OpenOrders: make(map[string][]TrackedOrder),
+		Orphans:    make(map[string][]*binance_connector.NewOpenOrdersResponse),
+		Trades:     make(map[string][]*binance_connector.AccountTradeListResponse),
+		Balances:   make(map[string]ReconciledBalance),
+	}
+	for _, symbol := range r.config.symbols {
+		if err := r.reconcileSymbol(symbol, result); err != nil {
+			return result, fmt.Errorf("reconcile %s: %w", symbol, err)
+		}
+	}
+
+	account, err := getAccountInformation(r.apiKey, r.secretKey, time.Now().UnixMilli(), AccountInformation{omitZeroBalances: true}, r.proxyURL)
+	if err != nil {
+		return result, fmt.Errorf("reconcile account: %w", err)
+	}
+	for _, balance := range account.Balances {
+		free, locked := decimalOrZero(balance.Free), decimalOrZero(balance.Locked)
+		if free.IsZero() && locked.IsZero() {
+			continue
+		}
+		result.Balances[balance.Asset] = ReconciledBalance{Free: free, Locked: locked}
+	}
+	return result, nil
+}
+
+func (r *Reconciler) reconcileSymbol(symbol string, result *ReconcileResult) error {
+	now := time.Now()
+	startTime := uint64(now.Add(-r.config.lookback).UnixMilli())
+
+	// 回看期内的历史订单，先喂给 tracker，挂单再用最新数据覆盖
+	allOrders, err := r.allOrdersSince(symbol, startTime)
+	if err != nil {
+		return err
+	}
+	owned := make(map[int64]bool)
+	for _, order := range allOrders {
+		if r.owns(order.ClientOrderId) {
+			owned[order.OrderId] = true
+			r.tracker.onAllOrder(order)
+		}
+	}
+
+	openOrders, err := getCurrentOpenOrders(r.apiKey, r.secretKey, symbol, now.UnixMilli(), r.proxyURL)
+	if err != nil {
+		return err
+	}
+	for _, order := range openOrders {
+		if r.owns(order.ClientOrderId) {
+			owned[order.OrderId] = true
+			r.tracker.onOpenOrder(order)
+		} else {
+			result.Orphans[symbol] = append(result.Orphans[symbol], order)
+		}
+	}
+
+	trades, err := r.tradesSince(symbol, startTime)
+	if err != nil {
+		return err
+	}
+	for _, trade := range trades {
+		if owned[trade.OrderId] {
+			result.Trades[symbol] = append(result.Trades[symbol], trade)
+			r.tracker.onTrade(trade)
+		}
+	}
+
+	result.OpenOrders[symbol] = r.tracker.openOrders(symbol)
+
+	if r.config.cancelOrphans && len(result.Orphans[symbol]) > 0 {
+		canceled, err := r.cancelOrphans(symbol, len(openOrders), result.Orphans[symbol])
+		result.CanceledOrphans = append(result.CanceledOrphans, canceled...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startTime 之后的所有订单：第一页按时间查询，之后按 orderId 翻页，最新的订单不会因为 limit 被截掉
+func (r *Reconciler) allOrdersSince(symbol string, startTime uint64) ([]*binance_connector.NewAllOrdersResponse, error) {
+	limit := initConfig.RECONCILE_LIMIT
+	params := AllOrders{startTime: &startTime, limit: &limit}
+	var all []*binance_connector.NewAllOrdersResponse
+	for {
+		orders, err := getAllOrders(r.apiKey, r.secretKey, symbol, time.Now().UnixMilli(), params, r.proxyURL)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, orders...)
+		if len(orders) < limit {
+			return all, nil
+		}
+		nextOrderId := orders[len(orders)-1].OrderId + 1
+		params = AllOrders{orderId: &nextOrderId, limit: &limit}
+	}
+}
+
+// startTime 之后的所有成交：第一页按时间查询，之后按 fromId 翻页
+func (r *Reconciler) tradesSince(symbol string, startTime uint64) ([]*binance_connector.AccountTradeListResponse, error) {
+	limit := initConfig.RECONCILE_LIMIT
+	params := GetMyTrades{startTime: &startTime, limit: &limit}
+	var all []*binance_connector.AccountTradeListResponse
+	for {
+		trades, err := getGetMyTrades(r.apiKey, r.secretKey, symbol, params, r.proxyURL)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, trades...)
+		if len(trades) < limit {
+			return all, nil
+		}
+		fromId := trades[len(trades)-1].Id + 1
+		params = GetMyTrades{fromId: &fromId, limit: &limit}
+	}
+}
+
+// 挂单全是孤儿时一次撤销该交易对所有挂单，否则逐个撤销，保留本程序的挂单
+func (r *Reconciler) cancelOrphans(
+	symbol string,
+	openCount int,
+	orphans []*binance_connector.NewOpenOrdersResponse,
+) ([]*binance_connector.CancelOrderResponse, error) {
+	if len(orphans) == openCount {
+		canceled, err := cancelSymbolAllOpenOrders(r.apiKey, r.secretKey, symbol, time.Now().UnixMilli(), r.proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		return canceled, nil
+	}
+
+	var canceled []*binance_connector.CancelOrderResponse
+	for _, orphan := range orphans {
+		orderId := orphan.OrderId
+		cancel, err := cancelOrder(r.apiKey, r.secretKey, symbol, CancelOrder{orderId: &orderId}, r.proxyURL)
+		if err != nil {
+			return canceled, err
+		}
+		canceled = append(canceled, cancel)
+	}
+	return canceled, nil
+}
@@ -0,0 +1,622 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"binance_connector"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 规则触发后的处理方式
+type RiskAction string
+
+const (
+	RiskActionReject RiskAction = "REJECT" // 拒绝下单
+	RiskActionWarn   RiskAction = "WARN"   // 只打印警告，照常下单
+	RiskActionClamp  RiskAction = "CLAMP"  // 调整数量或价格后下单，无法调整时拒绝
+)
+
+func (a RiskAction) isValid() bool {
+	switch a {
+	case RiskActionReject, RiskActionWarn, RiskActionClamp:
+		return true
+	}
+	return false
+}
+
+// 风控检查中的订单
+type RiskOrder struct {
+	symbol    string
+	side      OrderSide
+	orderType OrderType
+	no        NewOrder
+	lastPrice Decimal // getTickersPrice 返回的最新价
+	replacing bool    // cancelReplace 会先撤掉一笔挂单
+}
+
+// 订单价格，市价单使用最新价
+func (o *RiskOrder) price() Decimal {
+	if o.no.price != nil {
+		return *o.no.price
+	}
+	return o.lastPrice
+}
+
+// 订单数量，按 quoteOrderQty 下单时按价格折算
+func (o *RiskOrder) quantity() Decimal {
+	if o.no.quantity != nil {
+		return *o.no.quantity
+	}
+	if o.no.quoteOrderQty != nil && o.price().Sign() > 0 {
+		return o.no.quoteOrderQty.Div(o.price())
+	}
+	return Decimal{}
+}
+
+// 订单名义价值（计价币）
+func (o *RiskOrder) notional() Decimal {
+	if o.no.quantity == nil && o.no.quoteOrderQty != nil {
+		return *o.no.quoteOrderQty
+	}
+	return o.quantity().Mul(o.price())
+}
+
+// 把数量调整为 qty 后的订单，qty 不为正时返回 nil
+func (o *RiskOrder) withQuantity(qty Decimal) *NewOrder {
+	if qty.Sign() <= 0 {
+		return nil
+	}
+	no := o.no
+	if no.quantity == nil && no.quoteOrderQty != nil {
+		quoteOrderQty := qty.Mul(o.price())
+		no.quoteOrderQty = &quoteOrderQty
+	} else {
+		no.quantity = &qty
+	}
+	return &no
+}
+
+// 把价格调整为 price 后的订单
+func (o *RiskOrder) withPrice(price Decimal) *NewOrder {
+	no := o.no
+	no.price = &price
+	return &no
+}
+
+// 规则违例
+type RiskViolation struct {
+	Rule    string
+	Message string
+	clamped *NewOrder // 调整后能满足规则的订单，为空时无法 clamp
+}
+
+// 被风控拒绝
+type RiskError struct {
+	Symbol     string
+	Violations []RiskViolation
+}
+
+func (e *RiskError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Rule+": "+v.Message)
+	}
+	return fmt.Sprintf("risk: %s rejected: %s", e.Symbol, strings.Join(messages, "; "))
+}
+
+// 风控规则，可以自行实现后通过 addRule 加入
+type RiskRule interface {
+	name() string
+	check(engine *RiskEngine, order *RiskOrder) *RiskViolation
+}
+
+type riskRuleEntry struct {
+	rule   RiskRule
+	action RiskAction
+}
+
+// 下单前的风控引擎，按加入顺序执行所有规则
+type RiskEngine struct {
+	registry *SymbolRegistry // 用于查找基础币和对齐调整后的数量，使用 CLAMP 或 dailyLoss 时必填
+	tracker  *OrderTracker   // 用于统计挂单、持仓变化和已实现盈亏，使用 dailyLoss 时必填
+	proxyURL string
+
+	// 最新价来源，默认使用 getTickersPrice
+	lastPrice func(symbol string) (Decimal, error)
+
+	mu         sync.Mutex
+	rules      []riskRuleEntry
+	positions  map[string]Decimal
+	costs      map[string]riskCost
+	prices     map[string]riskPrice
+	pnlDay     string
+	realizedPL Decimal
+}
+
+// 按成交记录的持仓成本，用于计算已实现盈亏
+type riskCost struct {
+	qty  Decimal
+	cost Decimal // 计价币
+}
+
+type riskPrice struct {
+	price Decimal
+	time  time.Time
+}
+
+func newRiskEngine(registry *SymbolRegistry, tracker *OrderTracker, proxyURL string) *RiskEngine {
+	e := &RiskEngine{
+		registry:  registry,
+		tracker:   tracker,
+		proxyURL:  proxyURL,
+		positions: make(map[string]Decimal),
+		costs:     make(map[string]riskCost),
+		prices:    make(map[string]riskPrice),
+	}
+	e.lastPrice = e.tickerPrice
+	if tracker != nil {
+		tracker.subscribe(e.onTransition)
+	}
+	return e
+}
+
+// 加入一条规则
+func (e *RiskEngine) addRule(rule RiskRule, action RiskAction) error {
+	if !action.isValid() {
+		return fmt.Errorf("risk: invalid action %q for %s", action, rule.name())
+	}
+	// 调整后的数量和价格要按交易对规则对齐，否则会带着 18 位小数发到交易所
+	if action == RiskActionClamp && e.registry == nil {
+		return fmt.Errorf("risk: %s with CLAMP requires a registry", rule.name())
+	}
+	// 已实现盈亏从 tracker 的成交中计算
+	if _, ok := rule.(*dailyLossRule); ok && (e.registry == nil || e.tracker == nil) {
+		return fmt.Errorf("risk: %s requires a registry and a tracker", rule.name())
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = append(e.rules, riskRuleEntry{rule: rule, action: action})
+	return nil
+}
+
+// 最新价，RISK_PRICE_CACHE_TTL 内复用
+func (e *RiskEngine) tickerPrice(symbol string) (Decimal, error) {
+	e.mu.Lock()
+	cached, ok := e.prices[symbol]
+	e.mu.Unlock()
+	if ok && time.Since(cached.time) < initConfig.RISK_PRICE_CACHE_TTL {
+		return cached.price, nil
+	}
+
+	ticker, err := getTickersPrice("", "", inputTokens{symbol: &symbol}, e.proxyURL)
+	if err != nil {
+		return Decimal{}, err
+	}
+	price, err := newDecimalFromString(ticker.Price)
+	if err != nil {
+		return Decimal{}, err
+	}
+	e.mu.Lock()
+	e.prices[symbol] = riskPrice{price: price, time: time.Now()}
+	e.mu.Unlock()
+	return price, nil
+}
+
+// 设置某个资产的持仓
+func (e *RiskEngine) setPosition(asset string, qty Decimal) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.positions[asset] = qty
+}
+
+// 用账户余额（free + locked）初始化持仓
+func (e *RiskEngine) loadPositions(api TradingAPI) error {
+	account, err := api.getAccountInformation(AccountInformation{omitZeroBalances: true})
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.positions = make(map[string]Decimal)
+	for _, balance := range account.Balances {
+		e.positions[balance.Asset] = decimalOrZero(balance.Free).Add(decimalOrZero(balance.Locked))
+	}
+	return nil
+}
+
+func (e *RiskEngine) position(asset string) Decimal {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.positions[asset]
+}
+
+// 成交后更新基础币持仓和已实现盈亏，计价币和手续费变化不影响持仓限额
+func (e *RiskEngine) onTransition(transition OrderTransition) {
+	if transition.Fill == nil || e.registry == nil {
+		return
+	}
+	symbolInfo, ok := e.registry.symbol(transition.Order.Symbol)
+	if !ok {
+		return
+	}
+	fill := transition.Fill
+	qty := fill.Qty
+	if transition.Order.Side == SideSell {
+		qty = qty.Neg()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.positions[symbolInfo.BaseAsset] = e.positions[symbolInfo.BaseAsset].Add(qty)
+	e.rollPnLDay()
+	e.realizedPL = e.realizedPL.Add(e.realizeLocked(symbolInfo, transition.Order.Side, fill))
+}
+
+// 按移动平均成本计算一笔成交的已实现盈亏（计价币）
+// 只有本程序买入的数量有成本，卖出超过这部分的数量不计盈亏；计价币手续费计入亏损
+func (e *RiskEngine) realizeLocked(symbolInfo *SymbolDetail, side OrderSide, fill *TrackedFill) Decimal {
+	var pnl Decimal
+	if fill.CommissionAsset == symbolInfo.QuoteAsset {
+		pnl = fill.Commission.Neg()
+	}
+	held := e.costs[symbolInfo.BaseAsset]
+	if side == SideBuy {
+		held.qty = held.qty.Add(fill.Qty)
+		held.cost = held.cost.Add(fill.Price.Mul(fill.Qty))
+		// 基础币手续费减少持仓，成本不变
+		if fill.CommissionAsset == symbolInfo.BaseAsset {
+			held.qty = held.qty.Sub(fill.Commission)
+		}
+		e.costs[symbolInfo.BaseAsset] = held
+		return pnl
+	}
+
+	matched := fill.Qty
+	if matched.GreaterThan(held.qty) {
+		matched = held.qty
+	}
+	if matched.Sign() > 0 {
+		avgCost := held.cost.Div(held.qty)
+		pnl = pnl.Add(fill.Price.Sub(avgCost).Mul(matched))
+		held.cost = held.cost.Sub(avgCost.Mul(matched))
+		held.qty = held.qty.Sub(matched)
+		e.costs[symbolInfo.BaseAsset] = held
+	}
+	return pnl
+}
+
+// 手动记录已实现盈亏（计价币），用于 tracker 之外的成交，按 UTC 日期每天清零
+func (e *RiskEngine) recordPnL(pnl Decimal) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rollPnLDay()
+	e.realizedPL = e.realizedPL.Add(pnl)
+}
+
+// 当天已实现盈亏
+func (e *RiskEngine) dailyPnL() Decimal {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rollPnLDay()
+	return e.realizedPL
+}
+
+func (e *RiskEngine) rollPnLDay() {
+	day := time.Now().UTC().Format("2006-01-02")
+	if day != e.pnlDay {
+		e.pnlDay = day
+		e.realizedPL = Decimal{}
+	}
+}
+
+// 检查新订单，返回可能被调整过的订单
+func (e *RiskEngine) checkNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (NewOrder, error) {
+	return e.evaluate(symbol, side, orderType, no, false)
+}
+
+// 检查 cancelReplace 中的新订单，返回可能被调整过的请求
+func (e *RiskEngine) checkCancelReplace(symbol string, side OrderSide, orderType OrderType, cr CancelReplace) (CancelReplace, error) {
+	no, err := e.evaluate(symbol, side, orderType, cancelReplaceNewOrder(cr), true)
+	if err != nil {
+		return cr, err
+	}
+	cr.quantity = no.quantity
+	cr.quoteOrderQty = no.quoteOrderQty
+	cr.price = no.price
+	return cr, nil
+}
+
+// 检查 OCO 的两条腿，返回可能被调整过的数量和订单；两条腿调整后的数量取较小值
+func (e *RiskEngine) checkOCO(symbol string, side OrderSide, quantity Decimal, oco NewOCO) (Decimal, NewOCO, error) {
+	for _, leg := range []*OrderListLeg{&oco.above, &oco.below} {
+		no := NewOrder{
+			quantity:      &quantity,
+			price:         leg.price,
+			stopPrice:     leg.stopPrice,
+			timeInForce:   leg.timeInForce,
+			trailingDelta: leg.trailingDelta,
+			icebergQty:    leg.icebergQty,
+		}
+		no, err := e.evaluate(symbol, side, leg.orderType, no, false)
+		if err != nil {
+			return quantity, oco, err
+		}
+		leg.price, leg.stopPrice = no.price, no.stopPrice
+		if no.quantity != nil && no.quantity.LessThan(quantity) {
+			quantity = *no.quantity
+		}
+	}
+	return quantity, oco, nil
+}
+
+func (e *RiskEngine) evaluate(symbol string, side OrderSide, orderType OrderType, no NewOrder, replacing bool) (NewOrder, error) {
+	lastPrice, err := e.lastPrice(symbol)
+	if err != nil {
+		// 拿不到最新价时无法判断风险，直接拒绝
+		return no, fmt.Errorf("risk: %s last price: %w", symbol, err)
+	}
+	order := &RiskOrder{
+		symbol:    symbol,
+		side:      side,
+		orderType: orderType,
+		no:        no,
+		lastPrice: lastPrice,
+		replacing: replacing,
+	}
+
+	e.mu.Lock()
+	rules := append([]riskRuleEntry(nil), e.rules...)
+	e.mu.Unlock()
+
+	var rejected []RiskViolation
+	clamped := false
+	for _, entry := range rules {
+		violation := entry.rule.check(e, order)
+		if violation == nil {
+			continue
+		}
+		violation.Rule = entry.rule.name()
+		switch {
+		case entry.action == RiskActionWarn:
+			fmt.Printf("risk warning: %s %s: %s\n", symbol, violation.Rule, violation.Message)
+		case entry.action == RiskActionClamp && violation.clamped != nil:
+			fmt.Printf("risk clamp: %s %s: %s\n", symbol, violation.Rule, violation.Message)
+			order.no = *violation.clamped
+			clamped = true
+		default:
+			rejected = append(rejected, *violation)
+		}
+	}
+	if len(rejected) > 0 {
+		return no, &RiskError{Symbol: symbol, Violations: rejected}
+	}
+
+	// 调整后的数量和价格按交易对规则对齐，addRule 保证 CLAMP 时 registry 不为空
+	// quoteOrderQty 不受 stepSize 约束，按计价币精度向下截断
+	if clamped {
+		if order.no.quoteOrderQty != nil {
+			if symbolInfo, ok := e.registry.symbol(symbol); ok && symbolInfo.QuoteAssetPrecision > 0 {
+				quoteOrderQty := order.no.quoteOrderQty.Truncate(int32(symbolInfo.QuoteAssetPrecision))
+				order.no.quoteOrderQty = &quoteOrderQty
+			}
+		}
+		return e.registry.checkOrder(symbol, side, orderType, order.no, FilterCheck{roundPrice: true, roundQuantity: true})
+	}
+	return order.no, nil
+}
+
+// 单笔订单最大名义价值
+type maxNotionalRule struct {
+	limit Decimal
+}
+
+func newMaxNotionalRule(limit Decimal) RiskRule {
+	return &maxNotionalRule{limit: limit}
+}
+
+func (r *maxNotionalRule) name() string { return "maxNotional" }
+
+func (r *maxNotionalRule) check(engine *RiskEngine, order *RiskOrder) *RiskViolation {
+	notional := order.notional()
+	if !notional.GreaterThan(r.limit) {
+		return nil
+	}
+	violation := &RiskViolation{Message: fmt.Sprintf("notional %s exceeds %s", notional, r.limit)}
+	if order.no.quantity == nil && order.no.quoteOrderQty != nil {
+		// 按金额下单时直接把金额调整为上限，避免由数量折算回金额产生多余的小数位
+		no := order.no
+		limit := r.limit
+		no.quoteOrderQty = &limit
+		violation.clamped = &no
+	} else if order.price().Sign() > 0 {
+		violation.clamped = order.withQuantity(r.limit.Div(order.price()))
+	}
+	return violation
+}
+
+// 每个资产的最大持仓（基础币数量），只限制买单，持仓包括未成交的买单
+type maxPositionRule struct {
+	limits map[string]Decimal
+}
+
+func newMaxPositionRule(limits map[string]Decimal) RiskRule {
+	return &maxPositionRule{limits: limits}
+}
+
+func (r *maxPositionRule) name() string { return "maxPosition" }
+
+func (r *maxPositionRule) check(engine *RiskEngine, order *RiskOrder) *RiskViolation {
+	if order.side != SideBuy || engine.registry == nil {
+		return nil
+	}
+	symbolInfo, ok := engine.registry.symbol(order.symbol)
+	if !ok {
+		return nil
+	}
+	limit, ok := r.limits[symbolInfo.BaseAsset]
+	if !ok {
+		return nil
+	}
+
+	exposure := engine.position(symbolInfo.BaseAsset)
+	if engine.tracker != nil {
+		for _, open := range engine.tracker.openOrders("") {
+			if open.Side != SideBuy {
+				continue
+			}
+			if info, ok := engine.registry.symbol(open.Symbol); ok && info.BaseAsset == symbolInfo.BaseAsset {
+				exposure = exposure.Add(open.remainingQty())
+			}
+		}
+	}
+	projected := exposure.Add(order.quantity())
+	if !projected.GreaterThan(limit) {
+		return nil
+	}
+	return &RiskViolation{
+		Message: fmt.Sprintf("%s position would be %s, max %s", symbolInfo.BaseAsset, projected, limit),
+		clamped: order.withQuantity(limit.Sub(exposure)),
+	}
+}
+
+// 每个交易对最多的挂单数，需要 tracker
+type maxOpenOrdersRule struct {
+	limit int
+}
+
+func newMaxOpenOrdersRule(limit int) RiskRule {
+	return &maxOpenOrdersRule{limit: limit}
+}
+
+func (r *maxOpenOrdersRule) name() string { return "maxOpenOrders" }
+
+func (r *maxOpenOrdersRule) check(engine *RiskEngine, order *RiskOrder) *RiskViolation {
+	if engine.tracker == nil {
+		return nil
+	}
+	count := len(engine.tracker.openOrders(order.symbol))
+	if order.replacing && count > 0 {
+		count--
+	}
+	if count < r.limit {
+		return nil
+	}
+	return &RiskViolation{Message: fmt.Sprintf("%d open orders, max %d", count, r.limit)}
+}
+
+// 价格偏离最新价的上限，例如 0.05 表示买价不高于最新价的 105%，卖价不低于 95%
+type priceCollarRule struct {
+	band Decimal
+}
+
+func newPriceCollarRule(band Decimal) RiskRule {
+	return &priceCollarRule{band: band}
+}
+
+func (r *priceCollarRule) name() string { return "priceCollar" }
+
+func (r *priceCollarRule) check(engine *RiskEngine, order *RiskOrder) *RiskViolation {
+	if order.no.price == nil || order.lastPrice.Sign() <= 0 {
+		return nil
+	}
+	price := *order.no.price
+	one := newDecimalFromInt(1)
+	if order.side == SideBuy {
+		upper := order.lastPrice.Mul(one.Add(r.band))
+		if price.GreaterThan(upper) {
+			return &RiskViolation{
+				Message: fmt.Sprintf("buy price %s above collar %s (last %s)", price, upper, order.lastPrice),
+				clamped: order.withPrice(upper),
+			}
+		}
+		return nil
+	}
+	lower := order.lastPrice.Mul(one.Sub(r.band))
+	if price.LessThan(lower) {
+		return &RiskViolation{
+			Message: fmt.Sprintf("sell price %s below collar %s (last %s)", price, lower, order.lastPrice),
+			clamped: order.withPrice(lower),
+		}
+	}
+	return nil
+}
+
+// 胖手指检查：每个交易对单笔订单的最大数量
+type maxQuantityRule struct {
+	limits map[string]Decimal
+}
+
+func newMaxQuantityRule(limits map[string]Decimal) RiskRule {
+	return &maxQuantityRule{limits: limits}
+}
+
+func (r *maxQuantityRule) name() string { return "maxQuantity" }
+
+func (r *maxQuantityRule) check(engine *RiskEngine, order *RiskOrder) *RiskViolation {
+	limit, ok := r.limits[order.symbol]
+	if !ok {
+		return nil
+	}
+	qty := order.quantity()
+	if !qty.GreaterThan(limit) {
+		return nil
+	}
+	return &RiskViolation{
+		Message: fmt.Sprintf("quantity %s exceeds %s", qty, limit),
+		clamped: order.withQuantity(limit),
+	}
+}
+
+// 当天亏损达到上限后停止下单，亏损从 tracker 的成交中计算，也可以通过 recordPnL 补充
+type dailyLossRule struct {
+	limit Decimal
+}
+
+func newDailyLossRule(limit Decimal) RiskRule {
+	return &dailyLossRule{limit: limit}
+}
+
+func (r *dailyLossRule) name() string { return "dailyLoss" }
+
+func (r *dailyLossRule) check(engine *RiskEngine, order *RiskOrder) *RiskViolation {
+	pnl := engine.dailyPnL()
+	if pnl.Neg().LessThan(r.limit) {
+		return nil
+	}
+	return &RiskViolation{Message: fmt.Sprintf("daily pnl %s reached loss limit %s", pnl, r.limit)}
+}
+
+// 带风控的交易接口，下单、cancelReplace 和 OCO 前先经过 RiskEngine，其他请求直接转发
+type riskTradingAPI struct {
+	TradingAPI
+	engine *RiskEngine
+}
+
+func newRiskTradingAPI(api TradingAPI, engine *RiskEngine) TradingAPI {
+	return &riskTradingAPI{TradingAPI: api, engine: engine}
+}
+
+func (r *riskTradingAPI) createNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*CreateOrderResponse, error) {
+	no, err := r.engine.checkNewOrder(symbol, side, orderType, no)
+	if err != nil {
+		return nil, err
+	}
+	return r.TradingAPI.createNewOrder(symbol, side, orderType, no)
+}
+
+func (r *riskTradingAPI) cancelReplace(symbol string, side OrderSide, orderType OrderType, cancelReplaceMode CancelReplaceMode, cr CancelReplace) (*binance_connector.CancelReplaceResponse, error) {
+	cr, err := r.engine.checkCancelReplace(symbol, side, orderType, cr)
+	if err != nil {
+		return nil, err
+	}
+	return r.TradingAPI.cancelReplace(symbol, side, orderType, cancelReplaceMode, cr)
+}
+
+func (r *riskTradingAPI) createNewOCO(symbol string, side OrderSide, quantity Decimal, oco NewOCO) (*OrderListResponse, error) {
+	quantity, oco, err := r.engine.checkOCO(symbol, side, quantity, oco)
+	if err != nil {
+		return nil, err
+	}
+	return r.TradingAPI.createNewOCO(symbol, side, quantity, oco)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRiskEngineRequiresRegistryForClamp(t *testing.T) {
+	e := newRiskEngine(nil, nil, "")
+	if err := e.addRule(newMaxNotionalRule(mustDecimal("100")), RiskActionClamp); err == nil {
+		t.Error("CLAMP without registry should be rejected")
+	}
+	if err := e.addRule(newMaxNotionalRule(mustDecimal("100")), RiskActionReject); err != nil {
+		t.Errorf("REJECT without registry: %v", err)
+	}
+	if err := e.addRule(newDailyLossRule(mustDecimal("100")), RiskActionReject); err == nil {
+		t.Error("dailyLoss without registry and tracker should be rejected")
+	}
+}
+
+func TestRiskEngineClampRoundsToFilters(t *testing.T) {
+	e := newRiskEngine(testSymbolRegistry(testSymbolDetail()), nil, "")
+	e.lastPrice = func(string) (Decimal, error) { return mustDecimal("30000"), nil }
+	if err := e.addRule(newMaxNotionalRule(mustDecimal("100")), RiskActionClamp); err != nil {
+		t.Fatal(err)
+	}
+	gtc := TimeInForceGTC
+	no, err := e.checkNewOrder("BTCUSDT", SideBuy, OrderTypeLimit, NewOrder{price: decimalPtr("30000"), quantity: decimalPtr("1"), timeInForce: &gtc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 100 / 30000 = 0.00333...，按 stepSize 向下取整
+	if no.quantity.String() != "0.00333" {
+		t.Errorf("clamped quantity = %s, want 0.00333", no.quantity)
+	}
+}
+
+func TestRiskEngineClampQuoteOrderQty(t *testing.T) {
+	tests := []struct {
+		name string
+		rule RiskRule
+		want string
+	}{
+		{"maxNotional uses the limit", newMaxNotionalRule(mustDecimal("100")), "100"},
+		// 0.0033333333333 * 29999.99 有十几位小数，按计价币精度截断
+		{"maxPosition truncates to quote precision", newMaxPositionRule(map[string]Decimal{"BTC": mustDecimal("0.0033333333333")}), "99.99996666"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newRiskEngine(testSymbolRegistry(testSymbolDetail()), nil, "")
+			e.lastPrice = func(string) (Decimal, error) { return mustDecimal("29999.99"), nil }
+			if err := e.addRule(tt.rule, RiskActionClamp); err != nil {
+				t.Fatal(err)
+			}
+			no, err := e.checkNewOrder("BTCUSDT", SideBuy, OrderTypeMarket, NewOrder{quoteOrderQty: decimalPtr("500")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if no.quantity != nil || no.quoteOrderQty == nil || no.quoteOrderQty.String() != tt.want {
+				t.Errorf("clamped order quantity %v quoteOrderQty %v, want quoteOrderQty %s", no.quantity, no.quoteOrderQty, tt.want)
+			}
+		})
+	}
+}
+
+func TestRiskEngineDailyLossFromFills(t *testing.T) {
+	tracker := newOrderTracker()
+	e := newRiskEngine(testSymbolRegistry(testSymbolDetail()), tracker, "")
+	e.lastPrice = func(string) (Decimal, error) { return mustDecimal("120"), nil }
+	if err := e.addRule(newDailyLossRule(mustDecimal("30")), RiskActionReject); err != nil {
+		t.Fatal(err)
+	}
+
+	fill := func(side OrderSide, price, qty, commission, commissionAsset string) {
+		e.onTransition(OrderTransition{
+			Order: TrackedOrder{Symbol: "BTCUSDT", Side: side},
+			Fill:  &TrackedFill{Price: mustDecimal(price), Qty: mustDecimal(qty), Commission: mustDecimal(commission), CommissionAsset: commissionAsset},
+		})
+	}
+	fill(SideBuy, "100", "1", "0.001", "BTC")
+	fill(SideBuy, "200", "1", "0", "USDT")
+	// 平均成本 300 / 1.999，卖出 1 的盈亏约为 -30.075
+	fill(SideSell, "120", "1", "0.12", "USDT")
+
+	avgCost := mustDecimal("300").Div(mustDecimal("1.999"))
+	pnl := e.dailyPnL()
+	want := mustDecimal("120").Sub(avgCost).Sub(mustDecimal("0.12"))
+	if pnl.Sub(want).Abs().GreaterThan(mustDecimal("0.000001")) {
+		t.Errorf("daily pnl = %s, want %s", pnl, want)
+	}
+
+	_, err := e.checkNewOrder("BTCUSDT", SideBuy, OrderTypeMarket, NewOrder{quantity: decimalPtr("0.001")})
+	var riskErr *RiskError
+	if !errors.As(err, &riskErr) || riskErr.Violations[0].Rule != "dailyLoss" {
+		t.Errorf("error = %v, want dailyLoss violation", err)
+	}
+
+	// 卖出超过已跟踪持仓的部分不计盈亏
+	fill(SideSell, "1", "5", "0", "BNB")
+	after := e.dailyPnL()
+	expected := pnl.Add(mustDecimal("1").Sub(avgCost).Mul(mustDecimal("0.999")))
+	if after.Sub(expected).Abs().GreaterThan(mustDecimal("0.000001")) {
+		t.Errorf("daily pnl after oversell = %s, want %s", after, expected)
+	}
+}
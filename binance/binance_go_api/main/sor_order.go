@@ -0,0 +1,198 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// exchangeInfo 中的 sors：同一个 baseAsset 下可以一起路由的交易对
+type SORInfo struct {
+	BaseAsset string   `json:"baseAsset"`
+	Symbols   []string `json:"symbols"`
+}
+
+// SOR 下单的返回
+type SOROrderResponse struct {
+	Symbol                  string         `json:"symbol"`
+	OrderId                 int64          `json:"orderId"`
+	OrderListId             int64          `json:"orderListId"`
+	ClientOrderId           string         `json:"clientOrderId"`
+	TransactTime            uint64         `json:"transactTime"`
+	Price                   Decimal        `json:"price"`
+	OrigQty                 Decimal        `json:"origQty"`
+	ExecutedQty             Decimal        `json:"executedQty"`
+	CumulativeQuoteQty      Decimal        `json:"cummulativeQuoteQty"`
+	Status                  string         `json:"status"`
+	TimeInForce             string         `json:"timeInForce"`
+	Type                    string         `json:"type"`
+	Side                    string         `json:"side"`
+	WorkingTime             uint64         `json:"workingTime"`
+	SelfTradePreventionMode string         `json:"selfTradePreventionMode"`
+	WorkingFloor            string         `json:"workingFloor"`
+	UsedSor                 bool           `json:"usedSor"`
+	Fills                   []SOROrderFill `json:"fills,omitempty"`
+}
+
+type SOROrderFill struct {
+	MatchType       string  `json:"matchType"`
+	Price           Decimal `json:"price"`
+	Qty             Decimal `json:"qty"`
+	Commission      Decimal `json:"commission"`
+	CommissionAsset string  `json:"commissionAsset"`
+	TradeId         int64   `json:"tradeId"`
+	AllocId         int64   `json:"allocId"`
+}
+
+// 查询 SOR 成交分配
+type MyAllocations struct {
+	startTime        *uint64
+	endTime          *uint64
+	fromAllocationId *int64
+	limit            *int
+	orderId          *int64
+	recvWindow       *int
+}
+
+// SOR 成交分配
+type AllocationResponse struct {
+	Symbol          string  `json:"symbol"`
+	AllocationId    int64   `json:"allocationId"`
+	AllocationType  string  `json:"allocationType"`
+	OrderId         int64   `json:"orderId"`
+	OrderListId     int64   `json:"orderListId"`
+	Price           Decimal `json:"price"`
+	Qty             Decimal `json:"qty"`
+	QuoteQty        Decimal `json:"quoteQty"`
+	Commission      Decimal `json:"commission"`
+	CommissionAsset string  `json:"commissionAsset"`
+	Time            uint64  `json:"time"`
+	IsBuyer         bool    `json:"isBuyer"`
+	IsMaker         bool    `json:"isMaker"`
+	IsAllocator     bool    `json:"isAllocator"`
+}
+
+// 得到 exchangeInfo 中所有支持 SOR 的 baseAsset 和交易对
+func getSORInfo(proxyURL string) ([]SORInfo, error) {
+	var exchangeInfo struct {
+		Sors []SORInfo `json:"sors"`
+	}
+	if err := publicRequestJSON(initConfig.PATH_EXCHANGE_INFO, nil, proxyURL, &exchangeInfo); err != nil {
+		return nil, err
+	}
+	return exchangeInfo.Sors, nil
+}
+
+// SOR 只支持 LIMIT 和 MARKET，并且不支持 quoteOrderQty、stopPrice、trailingDelta
+func sorOrderParams(symbol string, side OrderSide, orderType OrderType, no NewOrder) (map[string]string, error) {
+	if err := validateNewOrder(side, orderType, no); err != nil {
+		return nil, err
+	}
+	if orderType != OrderTypeLimit && orderType != OrderTypeMarket {
+		return nil, fmt.Errorf("sor: unsupported order type %q", orderType)
+	}
+	if no.quantity == nil {
+		return nil, fmt.Errorf("sor: quantity is required")
+	}
+	if no.quoteOrderQty != nil || no.stopPrice != nil || no.trailingDelta != nil {
+		return nil, fmt.Errorf("sor: quoteOrderQty, stopPrice and trailingDelta are not supported")
+	}
+	return newOrderParams(symbol, side, orderType, no), nil
+}
+
+// 使用 SOR 下单 - POST /api/v3/sor/order
+func createSOROrder(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	timestamp int64,
+	no NewOrder,
+	proxyURL string,
+) (*SOROrderResponse, error) {
+	params, err := sorOrderParams(symbol, side, orderType, no)
+	if err != nil {
+		return nil, err
+	}
+	if isDryRun() {
+		fmt.Println("[dry-run] POST "+initConfig.PATH_SOR_ORDER, sortedQuery(params))
+		if _, err := testSOROrder(thisApiKey, thisSecretKey, symbol, side, orderType, timestamp, no, false, proxyURL); err != nil {
+			return nil, err
+		}
+		return &SOROrderResponse{Symbol: symbol, Side: string(side), Type: string(orderType), Status: dryRunResult}, nil
+	}
+
+	sorOrder := new(SOROrderResponse)
+	err = signedRequestJSON(thisApiKey, thisSecretKey, http.MethodPost, initConfig.PATH_SOR_ORDER, params, proxyURL, sorOrder)
+	if err != nil {
+		return nil, err
+	}
+	return sorOrder, nil
+}
+
+// 测试 SOR 下单 - POST /api/v3/sor/order/test
+func testSOROrder(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	timestamp int64,
+	no NewOrder,
+	computeCommissionRates bool,
+	proxyURL string,
+) (*TestOrderResponse, error) {
+	params, err := sorOrderParams(symbol, side, orderType, no)
+	if err != nil {
+		return nil, err
+	}
+	if computeCommissionRates {
+		params["computeCommissionRates"] = "true"
+	}
+
+	testOrder := new(TestOrderResponse)
+	err = signedRequestJSON(thisApiKey, thisSecretKey, http.MethodPost, initConfig.PATH_SOR_ORDER_TEST, params, proxyURL, testOrder)
+	if err != nil {
+		return nil, err
+	}
+	return testOrder, nil
+}
+
+// 查询 SOR 订单的成交分配 - GET /api/v3/myAllocations
+func getMyAllocations(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	timestamp int64,
+	ma MyAllocations,
+	proxyURL string,
+) ([]*AllocationResponse, error) {
+	params := map[string]string{"symbol": symbol}
+	if ma.startTime != nil {
+		params["startTime"] = strconv.FormatUint(*ma.startTime, 10)
+	}
+	if ma.endTime != nil {
+		params["endTime"] = strconv.FormatUint(*ma.endTime, 10)
+	}
+	if ma.fromAllocationId != nil {
+		params["fromAllocationId"] = strconv.FormatInt(*ma.fromAllocationId, 10)
+	}
+	if ma.limit != nil {
+		params["limit"] = strconv.Itoa(*ma.limit)
+	}
+	if ma.orderId != nil {
+		params["orderId"] = strconv.FormatInt(*ma.orderId, 10)
+	}
+	if ma.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*ma.recvWindow)
+	}
+
+	var allocations []*AllocationResponse
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodGet, initConfig.PATH_MY_ALLOCATIONS, params, proxyURL, &allocations)
+	if err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
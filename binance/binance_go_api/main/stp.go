@@ -0,0 +1,216 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"binance_connector"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 查询被阻止的撮合，preventedMatchId 和 orderId 二选一，fromPreventedMatchId 只能和 orderId 一起使用
+type PreventedMatches struct {
+	preventedMatchId     *int64
+	orderId              *int64
+	fromPreventedMatchId *int64
+	limit                *int
+	recvWindow           *int
+}
+
+// 查询因防自成交被阻止的撮合 - GET /api/v3/myPreventedMatches
+func getMyPreventedMatches(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	timestamp int64,
+	pm PreventedMatches,
+	proxyURL string,
+) ([]*PreventedMatch, error) {
+	params := map[string]string{"symbol": symbol}
+	if pm.preventedMatchId != nil {
+		params["preventedMatchId"] = strconv.FormatInt(*pm.preventedMatchId, 10)
+	}
+	if pm.orderId != nil {
+		params["orderId"] = strconv.FormatInt(*pm.orderId, 10)
+	}
+	if pm.fromPreventedMatchId != nil {
+		params["fromPreventedMatchId"] = strconv.FormatInt(*pm.fromPreventedMatchId, 10)
+	}
+	if pm.limit != nil {
+		params["limit"] = strconv.Itoa(*pm.limit)
+	}
+	if pm.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*pm.recvWindow)
+	}
+
+	var preventedMatches []*PreventedMatch
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodGet, initConfig.PATH_MY_PREVENTED_MATCHES, params, proxyURL, &preventedMatches)
+	if err != nil {
+		return nil, err
+	}
+	return preventedMatches, nil
+}
+
+// 交易对是否允许该防自成交模式
+func (s *SymbolDetail) allowsSTPMode(mode STPMode) bool {
+	for _, allowed := range s.AllowedSelfTradePreventionModes {
+		if allowed == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SymbolDetail) allowedSTPModes() string {
+	modes := make([]string, 0, len(s.AllowedSelfTradePreventionModes))
+	for _, mode := range s.AllowedSelfTradePreventionModes {
+		modes = append(modes, string(mode))
+	}
+	return strings.Join(modes, ",")
+}
+
+// 按优先顺序返回第一个允许的模式，都不允许时返回交易对的默认模式
+func (s *SymbolDetail) resolveSTPMode(preferred ...STPMode) STPMode {
+	for _, mode := range preferred {
+		if s.allowsSTPMode(mode) {
+			return mode
+		}
+	}
+	return s.DefaultSelfTradePreventionMode
+}
+
+// 某个策略被阻止的撮合
+type StrategyPreventedMatches struct {
+	StrategyId        string
+	Matches           []*PreventedMatch
+	PreventedQuantity Decimal         // makerPreventedQuantity 之和
+	ByMode            map[STPMode]int // 每种模式被阻止的次数
+}
+
+// 按策略统计的防自成交报告
+type PreventedMatchReport struct {
+	Symbol     string
+	StartTime  time.Time
+	EndTime    time.Time
+	ByStrategy map[string]*StrategyPreventedMatches
+}
+
+// 统计 [startTime, endTime] 内 prefix 下每个策略被阻止的撮合
+// myPreventedMatches 只能按订单查询，所以先用 allOrders 找出时间范围内被阻止过的订单，再逐个查询
+func getPreventedMatchReport(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	prefix string,
+	startTime time.Time,
+	endTime time.Time,
+	proxyURL string,
+) (*PreventedMatchReport, error) {
+	orders, err := getAllOrdersInRange(thisApiKey, thisSecretKey, symbol, startTime, endTime, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PreventedMatchReport{
+		Symbol:     symbol,
+		StartTime:  startTime,
+		EndTime:    endTime,
+		ByStrategy: make(map[string]*StrategyPreventedMatches),
+	}
+	from, to := uint64(startTime.UnixMilli()), uint64(endTime.UnixMilli())
+	for strategyId, strategyOrders := range attributeOrders(prefix, orders) {
+		seen := make(map[int64]bool)
+		for _, order := range strategyOrders {
+			if !wasPrevented(order) {
+				continue
+			}
+			matches, err := getOrderPreventedMatches(thisApiKey, thisSecretKey, symbol, order.OrderId, proxyURL)
+			if err != nil {
+				return report, err
+			}
+			for _, match := range matches {
+				// 同一策略的 taker 和 maker 会查到同一条记录
+				if seen[match.PreventedMatchId] || match.TransactTime < from || match.TransactTime > to {
+					continue
+				}
+				seen[match.PreventedMatchId] = true
+
+				stats, ok := report.ByStrategy[strategyId]
+				if !ok {
+					stats = &StrategyPreventedMatches{StrategyId: strategyId, ByMode: make(map[STPMode]int)}
+					report.ByStrategy[strategyId] = stats
+				}
+				stats.Matches = append(stats.Matches, match)
+				stats.PreventedQuantity = stats.PreventedQuantity.Add(match.MakerPreventedQuantity)
+				stats.ByMode[STPMode(match.SelfTradePreventionMode)]++
+			}
+		}
+	}
+	for _, stats := range report.ByStrategy {
+		sort.Slice(stats.Matches, func(i, j int) bool {
+			return stats.Matches[i].PreventedMatchId < stats.Matches[j].PreventedMatchId
+		})
+	}
+	return report, nil
+}
+
+// taker 订单会带 preventedMatchId/preventedQuantity，被 EXPIRE_MAKER 等模式过期的 maker 订单状态为 EXPIRED_IN_MATCH
+func wasPrevented(order *binance_connector.NewAllOrdersResponse) bool {
+	return order.PreventedMatchId != 0 ||
+		decimalOrZero(order.PreventedQuantity).Sign() > 0 ||
+		OrderStatus(order.Status) == OrderStatusExpiredInMatch
+}
+
+// 翻页取出某个订单的所有被阻止的撮合
+func getOrderPreventedMatches(thisApiKey, thisSecretKey, symbol string, orderId int64, proxyURL string) ([]*PreventedMatch, error) {
+	var all []*PreventedMatch
+	limit := initConfig.PREVENTED_MATCHES_LIMIT
+	pm := PreventedMatches{orderId: &orderId, limit: &limit}
+	for {
+		matches, err := getMyPreventedMatches(thisApiKey, thisSecretKey, symbol, time.Now().UnixMilli(), pm, proxyURL)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, matches...)
+		if len(matches) < limit {
+			return all, nil
+		}
+		fromPreventedMatchId := matches[len(matches)-1].PreventedMatchId + 1
+		pm.fromPreventedMatchId = &fromPreventedMatchId
+	}
+}
+
+// allOrders 每次最多查询 ALL_ORDERS_MAX_WINDOW，按窗口和 limit 翻页
+func getAllOrdersInRange(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	startTime time.Time,
+	endTime time.Time,
+	proxyURL string,
+) ([]*binance_connector.NewAllOrdersResponse, error) {
+	var all []*binance_connector.NewAllOrdersResponse
+	limit := initConfig.RECONCILE_LIMIT
+	cursor, end := uint64(startTime.UnixMilli()), uint64(endTime.UnixMilli())
+	window := uint64(initConfig.ALL_ORDERS_MAX_WINDOW.Milliseconds())
+	for cursor <= end {
+		windowEnd := cursor + window - 1
+		if windowEnd > end {
+			windowEnd = end
+		}
+		from, to := cursor, windowEnd
+		orders, err := getAllOrders(thisApiKey, thisSecretKey, symbol, time.Now().UnixMilli(), AllOrders{startTime: &from, endTime: &to, limit: &limit}, proxyURL)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, orders...)
+		if len(orders) == limit {
+			cursor = orders[len(orders)-1].Time + 1
+		} else {
+			cursor = windowEnd + 1
+		}
+	}
+	return all, nil
+}
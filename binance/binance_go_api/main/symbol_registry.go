@@ -0,0 +1,240 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 交易对状态变化，NewStatus 为空表示交易对已从 exchangeInfo 中移除
+type SymbolStatusChange struct {
+	Symbol    string
+	OldStatus string
+	NewStatus string
+}
+
+// 交易对注册表：只下载一次 exchangeInfo，按交易对和币种建索引，并在后台定期刷新
+type SymbolRegistry struct {
+	proxyURL string
+	filter   ExchangeInfo // 下载时使用的 symbol/symbols/permissions 过滤条件
+	interval time.Duration
+
+	mu           sync.RWMutex
+	exchangeInfo *ExchangeInfoDetail
+	bySymbol     map[string]*SymbolDetail
+	byBaseAsset  map[string][]*SymbolDetail
+	byQuoteAsset map[string][]*SymbolDetail
+	updatedAt    time.Time
+	subscribers  []func(SymbolStatusChange)
+	stopCh       chan struct{}
+	doneCh       chan struct{}
+}
+
+// interval 为 0 时使用 EXCHANGE_INFO_REFRESH_INTERVAL
+func newSymbolRegistry(filter ExchangeInfo, interval time.Duration, proxyURL string) *SymbolRegistry {
+	if interval <= 0 {
+		interval = initConfig.EXCHANGE_INFO_REFRESH_INTERVAL
+	}
+	return &SymbolRegistry{
+		proxyURL: proxyURL,
+		filter:   filter,
+		interval: interval,
+	}
+}
+
+// 首次加载并启动后台刷新
+func (r *SymbolRegistry) start() error {
+	r.mu.Lock()
+	if r.stopCh != nil {
+		r.mu.Unlock()
+		return fmt.Errorf("symbol registry: already started")
+	}
+	r.mu.Unlock()
+
+	if err := r.refresh(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopCh = make(chan struct{})
+	r.doneCh = make(chan struct{})
+	go r.run(r.stopCh, r.doneCh)
+	return nil
+}
+
+// 停止后台刷新，已加载的数据仍然可用
+func (r *SymbolRegistry) stop() {
+	r.mu.Lock()
+	stopCh, doneCh := r.stopCh, r.doneCh
+	r.stopCh, r.doneCh = nil, nil
+	r.mu.Unlock()
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	<-doneCh
+}
+
+func (r *SymbolRegistry) run(stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			// 刷新失败时继续使用旧数据，等下一次刷新
+			if err := r.refresh(); err != nil {
+				fmt.Println("symbol registry:", err)
+			}
+		}
+	}
+}
+
+// 重新下载 exchangeInfo 并重建索引，状态有变化时通知订阅者
+func (r *SymbolRegistry) refresh() error {
+	exchangeInfo, err := getExchangeInfoDetail(r.filter, r.proxyURL)
+	if err != nil {
+		return err
+	}
+
+	bySymbol := make(map[string]*SymbolDetail, len(exchangeInfo.Symbols))
+	byBaseAsset := make(map[string][]*SymbolDetail)
+	byQuoteAsset := make(map[string][]*SymbolDetail)
+	for _, symbolInfo := range exchangeInfo.Symbols {
+		bySymbol[symbolInfo.Symbol] = symbolInfo
+		byBaseAsset[symbolInfo.BaseAsset] = append(byBaseAsset[symbolInfo.BaseAsset], symbolInfo)
+		byQuoteAsset[symbolInfo.QuoteAsset] = append(byQuoteAsset[symbolInfo.QuoteAsset], symbolInfo)
+	}
+
+	r.mu.Lock()
+	var changes []SymbolStatusChange
+	// 首次加载不通知
+	if r.bySymbol != nil {
+		for symbol, old := range r.bySymbol {
+			newStatus := ""
+			if current, ok := bySymbol[symbol]; ok {
+				newStatus = current.Status
+			}
+			if newStatus != old.Status {
+				changes = append(changes, SymbolStatusChange{Symbol: symbol, OldStatus: old.Status, NewStatus: newStatus})
+			}
+		}
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Symbol < changes[j].Symbol })
+	}
+	r.exchangeInfo = exchangeInfo
+	r.bySymbol = bySymbol
+	r.byBaseAsset = byBaseAsset
+	r.byQuoteAsset = byQuoteAsset
+	r.updatedAt = time.Now()
+	subscribers := append([]func(SymbolStatusChange){}, r.subscribers...)
+	r.mu.Unlock()
+
+	for _, change := range changes {
+		for _, subscriber := range subscribers {
+			subscriber(change)
+		}
+	}
+	return nil
+}
+
+// 订阅交易对状态变化，例如 TRADING -> BREAK/HALT
+func (r *SymbolRegistry) subscribe(subscriber func(SymbolStatusChange)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, subscriber)
+}
+
+// 查找单个交易对
+func (r *SymbolRegistry) symbol(symbol string) (*SymbolDetail, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	symbolInfo, ok := r.bySymbol[symbol]
+	return symbolInfo, ok
+}
+
+// 以 asset 为基础币的所有交易对
+func (r *SymbolRegistry) symbolsByBaseAsset(asset string) []*SymbolDetail {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*SymbolDetail(nil), r.byBaseAsset[asset]...)
+}
+
+// 以 asset 为计价币的所有交易对
+func (r *SymbolRegistry) symbolsByQuoteAsset(asset string) []*SymbolDetail {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*SymbolDetail(nil), r.byQuoteAsset[asset]...)
+}
+
+// 在已加载的数据中按 symbol/symbols/permissions 过滤，条件都为空时返回全部
+func (r *SymbolRegistry) lookup(ei ExchangeInfo) []*SymbolDetail {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[string]bool)
+	if ei.symbol != "" {
+		wanted[ei.symbol] = true
+	}
+	for _, symbol := range ei.symbols {
+		wanted[symbol] = true
+	}
+
+	var symbols []*SymbolDetail
+	if r.exchangeInfo == nil {
+		return symbols
+	}
+	for _, symbolInfo := range r.exchangeInfo.Symbols {
+		if len(wanted) > 0 && !wanted[symbolInfo.Symbol] {
+			continue
+		}
+		if ei.permissions != "" && !symbolInfo.hasPermission(ei.permissions) {
+			continue
+		}
+		symbols = append(symbols, symbolInfo)
+	}
+	return symbols
+}
+
+// 最近一次加载的完整 exchangeInfo 和加载时间
+func (r *SymbolRegistry) snapshot() (*ExchangeInfoDetail, time.Time) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.exchangeInfo, r.updatedAt
+}
+
+// 用缓存的交易对规则检查订单，不再每次下载 exchangeInfo
+func (r *SymbolRegistry) checkOrder(
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	no NewOrder,
+	fc FilterCheck,
+) (NewOrder, error) {
+	symbolInfo, ok := r.symbol(symbol)
+	if !ok {
+		return no, fmt.Errorf("symbol registry: unknown symbol %s", symbol)
+	}
+	return applyExchangeFilters(symbolInfo, side, orderType, no, fc)
+}
+
+// permissions 和 permissionSets 中任意一处包含即可
+func (s *SymbolDetail) hasPermission(permission string) bool {
+	for _, p := range s.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	for _, set := range s.PermissionSets {
+		for _, p := range set {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
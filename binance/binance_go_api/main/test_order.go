@@ -0,0 +1,116 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"binance_connector"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// dry-run 模式下 cancelReplace 返回的结果
+const dryRunResult = "DRY_RUN"
+
+// 全局 dry-run 开关，打开后 createNewOrder 和 cancelReplace 只调用测试下单接口
+var dryRun atomic.Bool
+
+func setDryRun(enabled bool) {
+	dryRun.Store(enabled)
+}
+
+func isDryRun() bool {
+	return dryRun.Load()
+}
+
+// 测试下单的返回，只有 computeCommissionRates=true 时才有内容
+type TestOrderResponse struct {
+	StandardCommissionForOrder *CommissionRates `json:"standardCommissionForOrder,omitempty"`
+	TaxCommissionForOrder      *CommissionRates `json:"taxCommissionForOrder,omitempty"`
+	Discount                   *struct {
+		EnabledForAccount bool   `json:"enabledForAccount"`
+		EnabledForSymbol  bool   `json:"enabledForSymbol"`
+		DiscountAsset     string `json:"discountAsset"`
+		Discount          string `json:"discount"`
+	} `json:"discount,omitempty"`
+}
+
+type CommissionRates struct {
+	Maker string `json:"maker"`
+	Taker string `json:"taker"`
+}
+
+// 测试下单，校验参数和签名但不会进入撮合 - POST /api/v3/order/test
+func testNewOrder(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	timestamp int64,
+	no NewOrder,
+	computeCommissionRates bool,
+	proxyURL string,
+) (*TestOrderResponse, error) {
+	if err := validateNewOrder(side, orderType, no); err != nil {
+		return nil, err
+	}
+	params := newOrderParams(symbol, side, orderType, no)
+	if computeCommissionRates {
+		params["computeCommissionRates"] = "true"
+	}
+	testOrder := new(TestOrderResponse)
+	err := signedRequestJSON(thisApiKey, thisSecretKey, http.MethodPost, initConfig.PATH_ORDER_TEST, params, proxyURL, testOrder)
+	if err != nil {
+		return nil, err
+	}
+	return testOrder, nil
+}
+
+// dry-run 下的 createNewOrder：打印本来要发送的参数，并用测试接口校验
+func dryRunNewOrder(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	timestamp int64,
+	no NewOrder,
+	proxyURL string,
+) (*CreateOrderResponse, error) {
+	fmt.Println("[dry-run] POST "+initConfig.PATH_ORDER, sortedQuery(newOrderParams(symbol, side, orderType, no)))
+	if _, err := testNewOrder(thisApiKey, thisSecretKey, symbol, side, orderType, timestamp, no, false, proxyURL); err != nil {
+		return nil, err
+	}
+	return &CreateOrderResponse{
+		Symbol:   symbol,
+		Side:     string(side),
+		Type:     string(orderType),
+		Status:   dryRunResult,
+		RespType: resolveNewOrderRespType(orderType, no),
+	}, nil
+}
+
+// dry-run 下的 cancelReplace：撤单部分只打印，新订单部分用测试接口校验
+func dryRunCancelReplace(
+	thisApiKey,
+	thisSecretKey,
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	cancelReplaceMode CancelReplaceMode,
+	timestamp int64,
+	cr CancelReplace,
+	proxyURL string,
+) (*binance_connector.CancelReplaceResponse, error) {
+	fmt.Println("[dry-run] POST "+initConfig.PATH_ORDER_CANCEL_REPLACE,
+		sortedQuery(cancelReplaceParams(symbol, side, orderType, cancelReplaceMode, cr)))
+
+	no := cancelReplaceNewOrder(cr)
+	if _, err := testNewOrder(thisApiKey, thisSecretKey, symbol, side, orderType, timestamp, no, false, proxyURL); err != nil {
+		return nil, err
+	}
+	return &binance_connector.CancelReplaceResponse{
+		CancelResult:   dryRunResult,
+		NewOrderResult: dryRunResult,
+	}, nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"binance_connector"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 下单通道
+const (
+	TransportREST = "REST"
+	TransportWS   = "WS"
+)
+
+// 交易接口，策略只依赖这个接口，不关心底层是 REST 还是 WebSocket API
+type TradingAPI interface {
+	createNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*CreateOrderResponse, error)
+	cancelOrder(symbol string, co CancelOrder) (*binance_connector.CancelOrderResponse, error)
+	cancelReplace(symbol string, side OrderSide, orderType OrderType, cancelReplaceMode CancelReplaceMode, cr CancelReplace) (*binance_connector.CancelReplaceResponse, error)
+	amendOrderKeepPriority(symbol string, newQty Decimal, ao AmendOrder) (*AmendOrderResponse, error)
+	getQueryOrder(symbol string, qo QueryOrder) (*binance_connector.GetOrderResponse, error)
+	getCurrentOpenOrders(symbol string) ([]*binance_connector.NewOpenOrdersResponse, error)
+	getAccountInformation(ai AccountInformation) (*binance_connector.AccountResponse, error)
+	createNewOCO(symbol string, side OrderSide, quantity Decimal, oco NewOCO) (*OrderListResponse, error)
+	cancelOrderList(symbol string, col CancelOrderList) (*OrderListResponse, error)
+}
+
+// 创建交易接口，transport 为 TransportWS 时连接失败会先走 REST，并在后台重连
+func newTradingAPI(
+	thisApiKey,
+	thisSecretKey string,
+	transport string,
+	wsBaseURL string,
+	proxyURL string,
+) (TradingAPI, error) {
+	rest := &restTradingAPI{apiKey: thisApiKey, secretKey: thisSecretKey, proxyURL: proxyURL}
+	switch transport {
+	case TransportREST, "":
+		return rest, nil
+	case TransportWS:
+		ws := newWsAPIClient(thisApiKey, thisSecretKey, wsBaseURL, proxyURL)
+		if err := ws.connect(); err != nil {
+			fmt.Println(err)
+		}
+		return &wsTradingAPI{ws: ws, fallback: rest, lastReconnect: time.Now()}, nil
+	default:
+		return nil, fmt.Errorf("trading api: unknown transport %q", transport)
+	}
+}
+
+// REST 实现
+type restTradingAPI struct {
+	apiKey    string
+	secretKey string
+	proxyURL  string
+}
+
+func (r *restTradingAPI) createNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*CreateOrderResponse, error) {
+	return createNewOrder(r.apiKey, r.secretKey, symbol, side, orderType, time.Now().UnixMilli(), no, r.proxyURL)
+}
+
+func (r *restTradingAPI) cancelOrder(symbol string, co CancelOrder) (*binance_connector.CancelOrderResponse, error) {
+	return cancelOrder(r.apiKey, r.secretKey, symbol, co, r.proxyURL)
+}
+
+func (r *restTradingAPI) cancelReplace(symbol string, side OrderSide, orderType OrderType, cancelReplaceMode CancelReplaceMode, cr CancelReplace) (*binance_connector.CancelReplaceResponse, error) {
+	return cancelReplace(r.apiKey, r.secretKey, symbol, side, orderType, cancelReplaceMode, time.Now().UnixMilli(), cr, r.proxyURL)
+}
+
+func (r *restTradingAPI) amendOrderKeepPriority(symbol string, newQty Decimal, ao AmendOrder) (*AmendOrderResponse, error) {
+	return amendOrderKeepPriority(r.apiKey, r.secretKey, symbol, newQty, time.Now().UnixMilli(), ao, r.proxyURL)
+}
+
+func (r *restTradingAPI) getQueryOrder(symbol string, qo QueryOrder) (*binance_connector.GetOrderResponse, error) {
+	return getQueryOrder(r.apiKey, r.secretKey, symbol, time.Now().UnixMilli(), qo, r.proxyURL)
+}
+
+func (r *restTradingAPI) getCurrentOpenOrders(symbol string) ([]*binance_connector.NewOpenOrdersResponse, error) {
+	return getCurrentOpenOrders(r.apiKey, r.secretKey, symbol, time.Now().UnixMilli(), r.proxyURL)
+}
+
+func (r *restTradingAPI) getAccountInformation(ai AccountInformation) (*binance_connector.AccountResponse, error) {
+	return getAccountInformation(r.apiKey, r.secretKey, time.Now().UnixMilli(), ai, r.proxyURL)
+}
+
+func (r *restTradingAPI) createNewOCO(symbol string, side OrderSide, quantity Decimal, oco NewOCO) (*OrderListResponse, error) {
+	return createNewOCO(r.apiKey, r.secretKey, symbol, side, quantity, time.Now().UnixMilli(), oco, r.proxyURL)
+}
+
+func (r *restTradingAPI) cancelOrderList(symbol string, col CancelOrderList) (*OrderListResponse, error) {
+	return cancelOrderList(r.apiKey, r.secretKey, symbol, col, r.proxyURL)
+}
+
+// WebSocket API 实现，socket 不可用时改走 REST
+// 只有请求确定没有发出去时才会回退，已发出但超时的请求直接返回错误，避免重复下单
+type wsTradingAPI struct {
+	ws       *WsAPIClient
+	fallback TradingAPI
+
+	mu            sync.Mutex
+	reconnecting  bool
+	lastReconnect time.Time
+}
+
+// 当前是否在使用 WebSocket API
+func (w *wsTradingAPI) usingWebsocket() bool {
+	return w.ws.isConnected()
+}
+
+// 判断是否需要回退到 REST，需要时顺便在后台重连
+func (w *wsTradingAPI) shouldFallback(err error) bool {
+	if !errors.Is(err, errWsNotConnected) {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.reconnecting && time.Since(w.lastReconnect) >= initConfig.WS_API_RECONNECT_DELAY {
+		w.reconnecting = true
+		w.lastReconnect = time.Now()
+		go func() {
+			if err := w.ws.connect(); err != nil {
+				fmt.Println(err)
+			}
+			w.mu.Lock()
+			w.reconnecting = false
+			w.mu.Unlock()
+		}()
+	}
+	return true
+}
+
+func (w *wsTradingAPI) createNewOrder(symbol string, side OrderSide, orderType OrderType, no NewOrder) (*CreateOrderResponse, error) {
+	// dry-run 只走 REST 的测试下单接口
+	if isDryRun() {
+		return w.fallback.createNewOrder(symbol, side, orderType, no)
+	}
+	newOrder, err := w.ws.wsPlaceOrder(context.Background(), symbol, side, orderType, no)
+	if w.shouldFallback(err) {
+		return w.fallback.createNewOrder(symbol, side, orderType, no)
+	}
+	return newOrder, err
+}
+
+func (w *wsTradingAPI) cancelOrder(symbol string, co CancelOrder) (*binance_connector.CancelOrderResponse, error) {
+	cancelOrder, err := w.ws.wsCancelOrder(context.Background(), symbol, co)
+	if w.shouldFallback(err) {
+		return w.fallback.cancelOrder(symbol, co)
+	}
+	return cancelOrder, err
+}
+
+func (w *wsTradingAPI) cancelReplace(symbol string, side OrderSide, orderType OrderType, cancelReplaceMode CancelReplaceMode, cr CancelReplace) (*binance_connector.CancelReplaceResponse, error) {
+	if isDryRun() {
+		return w.fallback.cancelReplace(symbol, side, orderType, cancelReplaceMode, cr)
+	}
+	cancelReplace, err := w.ws.wsCancelReplace(context.Background(), symbol, side, orderType, cancelReplaceMode, cr)
+	if w.shouldFallback(err) {
+		return w.fallback.cancelReplace(symbol, side, orderType, cancelReplaceMode, cr)
+	}
+	return cancelReplace, err
+}
+
+func (w *wsTradingAPI) amendOrderKeepPriority(symbol string, newQty Decimal, ao AmendOrder) (*AmendOrderResponse, error) {
+	if isDryRun() {
+		return w.fallback.amendOrderKeepPriority(symbol, newQty, ao)
+	}
+	amendOrder, err := w.ws.wsAmendOrderKeepPriority(context.Background(), symbol, newQty, ao)
+	if w.shouldFallback(err) {
+		return w.fallback.amendOrderKeepPriority(symbol, newQty, ao)
+	}
+	return amendOrder, err
+}
+
+func (w *wsTradingAPI) getQueryOrder(symbol string, qo QueryOrder) (*binance_connector.GetOrderResponse, error) {
+	queryOrder, err := w.ws.wsQueryOrder(context.Background(), symbol, qo)
+	if w.shouldFallback(err) {
+		return w.fallback.getQueryOrder(symbol, qo)
+	}
+	return queryOrder, err
+}
+
+func (w *wsTradingAPI) getCurrentOpenOrders(symbol string) ([]*binance_connector.NewOpenOrdersResponse, error) {
+	openOrders, err := w.ws.wsOpenOrdersStatus(context.Background(), symbol)
+	if w.shouldFallback(err) {
+		return w.fallback.getCurrentOpenOrders(symbol)
+	}
+	return openOrders, err
+}
+
+func (w *wsTradingAPI) getAccountInformation(ai AccountInformation) (*binance_connector.AccountResponse, error) {
+	accountInformation, err := w.ws.wsAccountStatus(context.Background(), ai)
+	if w.shouldFallback(err) {
+		return w.fallback.getAccountInformation(ai)
+	}
+	return accountInformation, err
+}
+
+// WebSocket API 客户端没有实现订单列表，直接走 REST
+func (w *wsTradingAPI) createNewOCO(symbol string, side OrderSide, quantity Decimal, oco NewOCO) (*OrderListResponse, error) {
+	return w.fallback.createNewOCO(symbol, side, quantity, oco)
+}
+
+func (w *wsTradingAPI) cancelOrderList(symbol string, col CancelOrderList) (*OrderListResponse, error) {
+	return w.fallback.cancelOrderList(symbol, col)
+}
+
+// 改单结果，amended 和 replaced 只有一个不为空
+type ReplaceOrderResult struct {
+	amended  *AmendOrderResponse
+	replaced *binance_connector.CancelReplaceResponse
+}
+
+// 改单：keepPriority 为 true 时用 amend 只减少数量并保留排队优先级，
+// 此时只使用 cr 中的 quantity、cancelOrderId/cancelOrigClientOrderId、newClientOrderId 和 recvWindow；
+// 否则走 cancelReplace 撤单重下
+func replaceOrder(
+	api TradingAPI,
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	cancelReplaceMode CancelReplaceMode,
+	cr CancelReplace,
+	keepPriority bool,
+) (*ReplaceOrderResult, error) {
+	if !keepPriority {
+		replaced, err := api.cancelReplace(symbol, side, orderType, cancelReplaceMode, cr)
+		if err != nil {
+			return nil, err
+		}
+		return &ReplaceOrderResult{replaced: replaced}, nil
+	}
+
+	if cr.quantity == nil {
+		return nil, fmt.Errorf("replace order: keepPriority requires quantity")
+	}
+	if cr.cancelOrderId == nil && cr.cancelOrigClientOrderId == nil {
+		return nil, fmt.Errorf("replace order: keepPriority requires cancelOrderId or cancelOrigClientOrderId")
+	}
+	amended, err := api.amendOrderKeepPriority(symbol, *cr.quantity, AmendOrder{
+		orderId:           cr.cancelOrderId,
+		origClientOrderId: cr.cancelOrigClientOrderId,
+		newClientOrderId:  cr.newClientOrderId,
+		recvWindow:        cr.recvWindow,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ReplaceOrderResult{amended: amended}, nil
+}
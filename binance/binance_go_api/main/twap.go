@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// TWAP 配置
+type TWAPConfig struct {
+	symbol         string
+	side           OrderSide
+	quantity       Decimal
+	duration       time.Duration
+	slices         int
+	orderType      OrderType // LIMIT 或 MARKET
+	limitPrice     *Decimal  // 买入最高价/卖出最低价，超出时该次子单跳过，数量累加到下一次
+	marketOnFinish bool      // 结束时剩余数量用市价单补齐（仍受 limitPrice 限制）
+}
+
+// TWAP 执行器：把母单数量在 duration 内平均拆成 slices 个子单
+// 每次调度时目标成交量为 quantity * i / slices，LIMIT 子单未成交的部分通过 cancelReplace 以最新盘口价改挂
+type TWAPExecutor struct {
+	*algoExecution
+	config TWAPConfig
+}
+
+func newTWAPExecutor(
+	api TradingAPI,
+	registry *SymbolRegistry,
+	ids *ClientOrderIdGenerator,
+	config TWAPConfig,
+	proxyURL string,
+) (*TWAPExecutor, error) {
+	if config.quantity.Sign() <= 0 {
+		return nil, fmt.Errorf("twap: quantity must be positive")
+	}
+	if config.duration <= 0 || config.slices <= 0 {
+		return nil, fmt.Errorf("twap: duration and slices must be positive")
+	}
+	if config.orderType != OrderTypeLimit && config.orderType != OrderTypeMarket {
+		return nil, fmt.Errorf("twap: orderType must be LIMIT or MARKET, got %s", config.orderType)
+	}
+	if !config.side.isValid() {
+		return nil, fmt.Errorf("twap: invalid side %q", config.side)
+	}
+	return &TWAPExecutor{
+		algoExecution: newAlgoExecution(api, registry, ids, config.symbol, config.side, config.quantity, proxyURL),
+		config:        config,
+	}, nil
+}
+
+// 在后台开始执行
+func (t *TWAPExecutor) start() error {
+	return t.launch(t.run)
+}
+
+func (t *TWAPExecutor) run() error {
+	startTime := time.Now()
+	interval := t.config.duration / time.Duration(t.config.slices)
+	sliceCount := newDecimalFromInt(int64(t.config.slices))
+
+	for i := 1; i <= t.config.slices; i++ {
+		if !t.waitUntil(startTime.Add(time.Duration(i-1) * interval)) {
+			return nil
+		}
+		due := t.config.quantity.Mul(newDecimalFromInt(int64(i))).Div(sliceCount)
+		if err := t.catchUp(due, t.config.orderType, t.config.limitPrice); err != nil {
+			return err
+		}
+	}
+
+	if !t.waitUntil(startTime.Add(t.config.duration)) {
+		return nil
+	}
+	return t.finishRemaining(t.config.marketOnFinish, t.config.limitPrice)
+}
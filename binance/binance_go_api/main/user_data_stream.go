@@ -0,0 +1,456 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 用户数据流事件类型
+const (
+	eventOutboundAccountPosition = "outboundAccountPosition"
+	eventBalanceUpdate           = "balanceUpdate"
+	eventExecutionReport         = "executionReport"
+	eventListStatus              = "listStatus"
+	eventListenKeyExpired        = "listenKeyExpired"
+	eventStreamTerminated        = "eventStreamTerminated"
+)
+
+// 账户余额变化 outboundAccountPosition
+type AccountPositionEvent struct {
+	EventType      string            `json:"e"`
+	EventTime      int64             `json:"E"`
+	LastUpdateTime int64             `json:"u"`
+	Balances       []AccountPosition `json:"B"`
+}
+
+type AccountPosition struct {
+	Asset  string `json:"a"`
+	Free   string `json:"f"`
+	Locked string `json:"l"`
+}
+
+// 充值、提现、划转引起的余额变化 balanceUpdate
+type BalanceUpdateEvent struct {
+	EventType    string `json:"e"`
+	EventTime    int64  `json:"E"`
+	Asset        string `json:"a"`
+	BalanceDelta string `json:"d"`
+	ClearTime    int64  `json:"T"`
+}
+
+// 订单更新 executionReport
+// 大小写不同的字段都要声明，否则 encoding/json 会按不区分大小写的规则互相覆盖
+type ExecutionReportEvent struct {
+	EventType               string `json:"e"`
+	EventTime               int64  `json:"E"`
+	Symbol                  string `json:"s"`
+	ClientOrderId           string `json:"c"`
+	Side                    string `json:"S"`
+	OrderType               string `json:"o"`
+	TimeInForce             string `json:"f"`
+	Quantity                string `json:"q"`
+	Price                   string `json:"p"`
+	StopPrice               string `json:"P"`
+	TrailingDelta           int64  `json:"d"`
+	IcebergQty              string `json:"F"`
+	OrderListId             int64  `json:"g"`
+	OrigClientOrderId       string `json:"C"`
+	ExecutionType           string `json:"x"`
+	OrderStatus             string `json:"X"`
+	RejectReason            string `json:"r"`
+	OrderId                 int64  `json:"i"`
+	LastExecutedQty         string `json:"l"`
+	CumulativeFilledQty     string `json:"z"`
+	LastExecutedPrice       string `json:"L"`
+	Commission              string `json:"n"`
+	CommissionAsset         string `json:"N"`
+	TransactionTime         int64  `json:"T"`
+	TradeId                 int64  `json:"t"`
+	PreventedMatchId        int64  `json:"v"`
+	ExecutionId             int64  `json:"I"`
+	IsOnBook                bool   `json:"w"`
+	IsMaker                 bool   `json:"m"`
+	Ignore                  bool   `json:"M"`
+	CreationTime            int64  `json:"O"`
+	CumulativeQuoteQty      string `json:"Z"`
+	LastQuoteQty            string `json:"Y"`
+	QuoteOrderQty           string `json:"Q"`
+	WorkingTime             int64  `json:"W"`
+	SelfTradePreventionMode string `json:"V"`
+	TrailingTime            int64  `json:"D"`
+	StrategyId              int64  `json:"j"`
+	StrategyType            int64  `json:"J"`
+	TradeGroupId            int64  `json:"u"`
+	CounterOrderId          int64  `json:"U"`
+	PreventedQuantity       string `json:"A"`
+	LastPreventedQuantity   string `json:"B"`
+	// SOR 订单和被自成交保护阻止的成交才有以下字段
+	AllocId                    int64  `json:"a"`
+	MatchType                  string `json:"b"`
+	WorkingFloor               string `json:"k"`
+	UsedSor                    bool   `json:"uS"`
+	CounterSymbol              string `json:"Cs"`
+	PreventedExecutionQty      string `json:"pl"`
+	PreventedExecutionPrice    string `json:"pL"`
+	PreventedExecutionQuoteQty string `json:"pY"`
+}
+
+// OCO 等订单列表状态 listStatus
+type ListStatusEvent struct {
+	EventType         string            `json:"e"`
+	EventTime         int64             `json:"E"`
+	Symbol            string            `json:"s"`
+	OrderListId       int64             `json:"g"`
+	ContingencyType   string            `json:"c"`
+	ListStatusType    string            `json:"l"`
+	ListOrderStatus   string            `json:"L"`
+	ListRejectReason  string            `json:"r"`
+	ListClientOrderId string            `json:"C"`
+	TransactionTime   int64             `json:"T"`
+	Orders            []ListStatusOrder `json:"O"`
+}
+
+type ListStatusOrder struct {
+	Symbol        string `json:"s"`
+	OrderId       int64  `json:"i"`
+	ClientOrderId string `json:"c"`
+}
+
+// listenKey 过期 listenKeyExpired
+type ListenKeyExpiredEvent struct {
+	EventType string `json:"e"`
+	EventTime int64  `json:"E"`
+	ListenKey string `json:"listenKey"`
+}
+
+// 数据流被服务端终止 eventStreamTerminated
+type EventStreamTerminatedEvent struct {
+	EventType string `json:"e"`
+	EventTime int64  `json:"E"`
+}
+
+// 用户数据流回调，未设置的回调会被忽略
+type UserDataHandlers struct {
+	onAccountPosition  func(event *AccountPositionEvent)
+	onBalanceUpdate    func(event *BalanceUpdateEvent)
+	onExecutionReport  func(event *ExecutionReportEvent)
+	onListStatus       func(event *ListStatusEvent)
+	onListenKeyExpired func(event *ListenKeyExpiredEvent)
+	onStreamTerminated func(event *EventStreamTerminatedEvent)
+	onError            func(err error)
+}
+
+// 用户数据流，负责 listenKey 的创建、续期、过期重建以及断线重连
+type UserDataStream struct {
+	apiKey    string
+	secretKey string
+	proxyURL  string
+	wsBaseURL string
+	handlers  UserDataHandlers
+
+	mu        sync.Mutex
+	listenKey string
+	conn      *websocket.Conn
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+}
+
+func newUserDataStream(
+	thisApiKey,
+	thisSecretKey string,
+	wsBaseURL string,
+	handlers UserDataHandlers,
+	proxyURL string,
+) *UserDataStream {
+	if wsBaseURL == "" {
+		wsBaseURL = initConfig.BASE_WS_PROD_1
+	}
+	return &UserDataStream{
+		apiKey:    thisApiKey,
+		secretKey: thisSecretKey,
+		proxyURL:  proxyURL,
+		wsBaseURL: wsBaseURL,
+		handlers:  handlers,
+	}
+}
+
+// 创建一个新的 listenKey - POST /api/v3/userDataStream
+func createListenKey(
+	thisApiKey,
+	thisSecretKey string,
+	proxyURL string,
+) (string, error) {
+	reClient, clientErr := initClient(thisApiKey, thisSecretKey, proxyURL)
+	if clientErr != nil {
+		return "", clientErr
+	}
+	listenKey, err := reClient.NewCreateListenKeyService().Do(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		return "", err
+	}
+	return listenKey, nil
+}
+
+// 延长 listenKey 有效期 - PUT /api/v3/userDataStream
+func keepAliveListenKey(
+	thisApiKey,
+	thisSecretKey,
+	listenKey string,
+	proxyURL string,
+) error {
+	reClient, clientErr := initClient(thisApiKey, thisSecretKey, proxyURL)
+	if clientErr != nil {
+		return clientErr
+	}
+	return reClient.NewPingUserStream().ListenKey(listenKey).Do(context.Background())
+}
+
+// 关闭 listenKey - DELETE /api/v3/userDataStream
+func closeListenKey(
+	thisApiKey,
+	thisSecretKey,
+	listenKey string,
+	proxyURL string,
+) error {
+	reClient, clientErr := initClient(thisApiKey, thisSecretKey, proxyURL)
+	if clientErr != nil {
+		return clientErr
+	}
+	return reClient.NewCloseUserStream().ListenKey(listenKey).Do(context.Background())
+}
+
+// 启动数据流：创建 listenKey、建立连接，并在后台续期和重连
+func (s *UserDataStream) start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopCh != nil {
+		return fmt.Errorf("user data stream: already started")
+	}
+	if err := s.connectLocked(); err != nil {
+		return err
+	}
+	s.stopCh = make(chan struct{})
+	s.doneCh = make(chan struct{})
+	go s.run(s.stopCh, s.doneCh)
+	return nil
+}
+
+// 停止数据流并关闭 listenKey
+func (s *UserDataStream) stop() error {
+	s.mu.Lock()
+	stopCh, doneCh := s.stopCh, s.doneCh
+	s.stopCh, s.doneCh = nil, nil
+	s.mu.Unlock()
+	if stopCh == nil {
+		return nil
+	}
+	close(stopCh)
+	<-doneCh
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeConnLocked()
+	if s.listenKey == "" {
+		return nil
+	}
+	err := closeListenKey(s.apiKey, s.secretKey, s.listenKey, s.proxyURL)
+	s.listenKey = ""
+	return err
+}
+
+// 当前使用的 listenKey
+func (s *UserDataStream) getListenKey() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listenKey
+}
+
+func (s *UserDataStream) run(stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+	keepAlive := time.NewTicker(initConfig.LISTEN_KEY_KEEPALIVE_INTERVAL)
+	defer keepAlive.Stop()
+
+	for {
+		s.mu.Lock()
+		conn := s.conn
+		s.mu.Unlock()
+
+		readDone := make(chan error, 1)
+		go s.readLoop(conn, readDone)
+		if !s.waitRead(stopCh, keepAlive, readDone) {
+			return
+		}
+		if !s.reconnect(stopCh) {
+			return
+		}
+	}
+}
+
+// 定时续期 listenKey，直到当前连接结束，返回 false 表示已停止
+func (s *UserDataStream) waitRead(stopCh chan struct{}, keepAlive *time.Ticker, readDone chan error) bool {
+	for {
+		select {
+		case <-stopCh:
+			s.mu.Lock()
+			s.closeConnLocked()
+			s.mu.Unlock()
+			<-readDone
+			return false
+		case <-keepAlive.C:
+			if err := keepAliveListenKey(s.apiKey, s.secretKey, s.getListenKey(), s.proxyURL); err != nil {
+				s.reportError(fmt.Errorf("user data stream: keepalive: %w", err))
+			}
+		case err := <-readDone:
+			if err != nil {
+				s.reportError(err)
+			}
+			return true
+		}
+	}
+}
+
+// 连接断开或 listenKey 失效后重新创建 listenKey 并重连，返回 false 表示已停止
+func (s *UserDataStream) reconnect(stopCh chan struct{}) bool {
+	for {
+		select {
+		case <-stopCh:
+			return false
+		case <-time.After(initConfig.USER_STREAM_RECONNECT_DELAY):
+		}
+		s.mu.Lock()
+		s.closeConnLocked()
+		err := s.connectLocked()
+		s.mu.Unlock()
+		if err == nil {
+			return true
+		}
+		s.reportError(fmt.Errorf("user data stream: reconnect: %w", err))
+	}
+}
+
+// 读取消息直到连接断开，或收到 listenKeyExpired / eventStreamTerminated
+func (s *UserDataStream) readLoop(conn *websocket.Conn, readDone chan error) {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			readDone <- err
+			return
+		}
+		eventType, err := s.dispatch(message)
+		if err != nil {
+			s.reportError(err)
+			continue
+		}
+		if eventType == eventListenKeyExpired || eventType == eventStreamTerminated {
+			s.mu.Lock()
+			s.listenKey = ""
+			s.mu.Unlock()
+			readDone <- nil
+			return
+		}
+	}
+}
+
+// 按事件类型解码并回调，返回事件类型
+func (s *UserDataStream) dispatch(message []byte) (string, error) {
+	// "E" 也要声明，否则数字的事件时间会按不区分大小写的规则解析到 EventType
+	var header struct {
+		EventType string `json:"e"`
+		EventTime int64  `json:"E"`
+	}
+	if err := json.Unmarshal(message, &header); err != nil {
+		return "", fmt.Errorf("user data stream: decode event: %w", err)
+	}
+
+	var err error
+	switch header.EventType {
+	case eventOutboundAccountPosition:
+		event := new(AccountPositionEvent)
+		if err = json.Unmarshal(message, event); err == nil && s.handlers.onAccountPosition != nil {
+			s.handlers.onAccountPosition(event)
+		}
+	case eventBalanceUpdate:
+		event := new(BalanceUpdateEvent)
+		if err = json.Unmarshal(message, event); err == nil && s.handlers.onBalanceUpdate != nil {
+			s.handlers.onBalanceUpdate(event)
+		}
+	case eventExecutionReport:
+		event := new(ExecutionReportEvent)
+		if err = json.Unmarshal(message, event); err == nil && s.handlers.onExecutionReport != nil {
+			s.handlers.onExecutionReport(event)
+		}
+	case eventListStatus:
+		event := new(ListStatusEvent)
+		if err = json.Unmarshal(message, event); err == nil && s.handlers.onListStatus != nil {
+			s.handlers.onListStatus(event)
+		}
+	case eventListenKeyExpired:
+		event := new(ListenKeyExpiredEvent)
+		if err = json.Unmarshal(message, event); err == nil && s.handlers.onListenKeyExpired != nil {
+			s.handlers.onListenKeyExpired(event)
+		}
+	case eventStreamTerminated:
+		event := new(EventStreamTerminatedEvent)
+		if err = json.Unmarshal(message, event); err == nil && s.handlers.onStreamTerminated != nil {
+			s.handlers.onStreamTerminated(event)
+		}
+	}
+	if err != nil {
+		return header.EventType, fmt.Errorf("user data stream: decode %s: %w", header.EventType, err)
+	}
+	return header.EventType, nil
+}
+
+// 需要持有 s.mu；listenKey 为空时先创建
+func (s *UserDataStream) connectLocked() error {
+	if s.listenKey == "" {
+		listenKey, err := createListenKey(s.apiKey, s.secretKey, s.proxyURL)
+		if err != nil {
+			return err
+		}
+		s.listenKey = listenKey
+	}
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: initConfig.TIMEOUT * 10,
+	}
+	if s.proxyURL != "" {
+		proxyParsed, err := url.Parse(s.proxyURL)
+		if err != nil {
+			return err
+		}
+		dialer.Proxy = http.ProxyURL(proxyParsed)
+	}
+	conn, _, err := dialer.Dial(s.wsBaseURL+initConfig.PATH_WS+"/"+s.listenKey, nil)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// 需要持有 s.mu
+func (s *UserDataStream) closeConnLocked() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *UserDataStream) reportError(err error) {
+	if s.handlers.onError != nil {
+		s.handlers.onError(err)
+		return
+	}
+	fmt.Println(err)
+}
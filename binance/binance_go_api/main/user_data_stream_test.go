@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+// 以下 payload 取自 Binance 用户数据流文档
+const (
+	testExecutionReportPayload    = `{"e":"executionReport","E":1499405658658,"s":"ETHBTC","c":"mUvoqJxFIILMdfAW5iGSOW","S":"BUY","o":"LIMIT","f":"GTC","q":"1.00000000","p":"0.10264410","P":"0.00000000","F":"0.00000000","g":-1,"C":"","x":"TRADE","X":"PARTIALLY_FILLED","r":"NONE","i":4293153,"l":"0.40000000","z":"0.40000000","L":"0.10264410","n":"0.00040000","N":"ETH","T":1499405658657,"t":1234,"v":3,"I":8641984,"w":true,"m":false,"M":false,"O":1499405658657,"Z":"0.04105764","Y":"0.04105764","Q":"0.00000000","W":1499405658657,"V":"NONE"}`
+	testSORExecutionReportPayload = `{"e":"executionReport","E":1689120000000,"s":"BTCUSDT","c":"sor-1","S":"BUY","o":"LIMIT","f":"GTC","q":"0.10000000","p":"30000.00000000","x":"TRADE","X":"FILLED","i":12,"l":"0.10000000","z":"0.10000000","L":"29999.00000000","n":"0","N":"BNB","T":1689120000000,"t":-1,"a":1234,"b":"ONE_PARTY_TRADE_REPORT","k":"SOR","uS":true,"Cs":"BTCUSDC","pl":"0.00100000","pL":"29999.00000000","pY":"29.99900000","v":7,"A":"0.00100000","B":"0.00100000","W":1689120000000,"V":"EXPIRE_TAKER"}`
+	testAccountPositionPayload    = `{"e":"outboundAccountPosition","E":1564034571105,"u":1564034571073,"B":[{"a":"ETH","f":"10000.000000","l":"0.000000"}]}`
+	testListenKeyExpiredPayload   = `{"e":"listenKeyExpired","E":1699596037418,"listenKey":"OfYGbUzi3PraNagEkdKuFwUHn48brFsItTdsuiIXrucEvD0rhRXZ7I6URWfE8YE8"}`
+)
+
+func TestUserDataStreamDispatch(t *testing.T) {
+	var reports []*ExecutionReportEvent
+	var positions []*AccountPositionEvent
+	var expired []*ListenKeyExpiredEvent
+	s := newUserDataStream("", "", "", UserDataHandlers{
+		onExecutionReport:  func(event *ExecutionReportEvent) { reports = append(reports, event) },
+		onAccountPosition:  func(event *AccountPositionEvent) { positions = append(positions, event) },
+		onListenKeyExpired: func(event *ListenKeyExpiredEvent) { expired = append(expired, event) },
+	}, "")
+
+	for _, payload := range []string{testExecutionReportPayload, testSORExecutionReportPayload, testAccountPositionPayload, testListenKeyExpiredPayload} {
+		if _, err := s.dispatch([]byte(payload)); err != nil {
+			t.Fatalf("dispatch: %v", err)
+		}
+	}
+	if len(reports) != 2 || len(positions) != 1 || len(expired) != 1 {
+		t.Fatalf("dispatched %d reports, %d positions, %d expired, want 2, 1, 1", len(reports), len(positions), len(expired))
+	}
+
+	report := reports[0]
+	if report.EventTime != 1499405658658 || report.OrderId != 4293153 || report.OrderStatus != "PARTIALLY_FILLED" ||
+		report.LastExecutedQty != "0.40000000" || report.StopPrice != "0.00000000" || report.TradeId != 1234 {
+		t.Errorf("execution report = %+v", report)
+	}
+	sor := reports[1]
+	if sor.AllocId != 1234 || sor.MatchType != "ONE_PARTY_TRADE_REPORT" || !sor.UsedSor || sor.WorkingFloor != "SOR" ||
+		sor.PreventedQuantity != "0.00100000" || sor.LastPreventedQuantity != "0.00100000" || sor.CounterSymbol != "BTCUSDC" ||
+		sor.PreventedExecutionQty != "0.00100000" || sor.PreventedExecutionQuoteQty != "29.99900000" {
+		t.Errorf("SOR execution report = %+v", sor)
+	}
+	if positions[0].LastUpdateTime != 1564034571073 || len(positions[0].Balances) != 1 || positions[0].Balances[0].Free != "10000.000000" {
+		t.Errorf("account position = %+v", positions[0])
+	}
+	if expired[0].ListenKey == "" {
+		t.Errorf("listenKeyExpired = %+v", expired[0])
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"fmt"
+	"time"
+)
+
+// k 线周期对应的时长
+var klineIntervals = map[string]time.Duration{
+	"1s":  time.Second,
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// VWAP 配置
+type VWAPConfig struct {
+	symbol         string
+	side           OrderSide
+	quantity       Decimal
+	duration       time.Duration
+	interval       string    // 分桶使用的 k 线周期，例如 5m，每个桶下一次子单
+	lookbackDays   int       // 取前几天同一时段的成交量做分布，为 0 时使用 VWAP_PROFILE_LOOKBACK_DAYS
+	orderType      OrderType // LIMIT 或 MARKET
+	limitPrice     *Decimal  // 买入最高价/卖出最低价
+	marketOnFinish bool      // 结束时剩余数量用市价单补齐
+}
+
+// VWAP 执行器：按历史同一时段的 k 线成交量分布拆单，成交量大的时段多下
+type VWAPExecutor struct {
+	*algoExecution
+	config   VWAPConfig
+	bucket   time.Duration
+	schedule []Decimal // 每个桶结束时累计应成交的比例
+}
+
+func newVWAPExecutor(
+	api TradingAPI,
+	registry *SymbolRegistry,
+	ids *ClientOrderIdGenerator,
+	config VWAPConfig,
+	proxyURL string,
+) (*VWAPExecutor, error) {
+	if config.quantity.Sign() <= 0 {
+		return nil, fmt.Errorf("vwap: quantity must be positive")
+	}
+	if config.orderType != OrderTypeLimit && config.orderType != OrderTypeMarket {
+		return nil, fmt.Errorf("vwap: orderType must be LIMIT or MARKET, got %s", config.orderType)
+	}
+	if !config.side.isValid() {
+		return nil, fmt.Errorf("vwap: invalid side %q", config.side)
+	}
+	bucket, ok := klineIntervals[config.interval]
+	if !ok {
+		return nil, fmt.Errorf("vwap: unknown kline interval %q", config.interval)
+	}
+	if config.duration < bucket {
+		return nil, fmt.Errorf("vwap: duration %s shorter than interval %s", config.duration, config.interval)
+	}
+	if config.lookbackDays <= 0 {
+		config.lookbackDays = initConfig.VWAP_PROFILE_LOOKBACK_DAYS
+	}
+	return &VWAPExecutor{
+		algoExecution: newAlgoExecution(api, registry, ids, config.symbol, config.side, config.quantity, proxyURL),
+		config:        config,
+		bucket:        bucket,
+	}, nil
+}
+
+// 按当前时段加载成交量分布后在后台开始执行
+func (v *VWAPExecutor) start() error {
+	schedule, err := volumeSchedule(v.config.symbol, v.config.interval, v.bucket, v.config.duration, v.config.lookbackDays, time.Now(), v.proxyURL)
+	if err != nil {
+		return err
+	}
+	v.schedule = schedule
+	return v.launch(v.run)
+}
+
+func (v *VWAPExecutor) run() error {
+	startTime := time.Now()
+	for i, fraction := range v.schedule {
+		if !v.waitUntil(startTime.Add(time.Duration(i) * v.bucket)) {
+			return nil
+		}
+		if err := v.catchUp(v.config.quantity.Mul(fraction), v.config.orderType, v.config.limitPrice); err != nil {
+			return err
+		}
+	}
+	if !v.waitUntil(startTime.Add(v.config.duration)) {
+		return nil
+	}
+	return v.finishRemaining(v.config.marketOnFinish, v.config.limitPrice)
+}
+
+// 等待执行结束，返回以执行期间市场 VWAP 为基准的报告
+func (v *VWAPExecutor) report() (AlgoReport, error) {
+	startTime, endTime := v.window()
+	benchmark, err := getMarketVWAP(v.config.symbol, startTime, endTime, v.proxyURL)
+	if err != nil {
+		return v.buildReport("VWAP", Decimal{}), err
+	}
+	return v.buildReport("VWAP", benchmark), nil
+}
+
+// 前 lookbackDays 天同一时段每个桶的平均成交量，归一化为累计比例
+// 历史上没有成交量时退化为均匀分布
+func volumeSchedule(
+	symbol string,
+	interval string,
+	bucket time.Duration,
+	duration time.Duration,
+	lookbackDays int,
+	startTime time.Time,
+	proxyURL string,
+) ([]Decimal, error) {
+	buckets := int((duration + bucket - 1) / bucket)
+	if buckets > initConfig.KLINES_LIMIT {
+		return nil, fmt.Errorf("vwap: %d buckets exceed klines limit %d, use a longer interval", buckets, initConfig.KLINES_LIMIT)
+	}
+
+	volumes := make([]Decimal, buckets)
+	for day := 1; day <= lookbackDays; day++ {
+		dayStart := startTime.Add(-time.Duration(day) * 24 * time.Hour)
+		from := uint64(dayStart.UnixMilli())
+		to := uint64(dayStart.Add(duration).UnixMilli()) - 1
+		limit := buckets
+		klines, err := getKlines("", "", symbol, Kline{interval: interval, startTime: &from, endTime: &to, limit: &limit}, proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		for _, kline := range klines {
+			index := int(time.Duration(kline.OpenTime-from) * time.Millisecond / bucket)
+			if index >= 0 && index < buckets {
+				volumes[index] = volumes[index].Add(decimalOrZero(kline.Volume))
+			}
+		}
+	}
+
+	var total Decimal
+	for _, volume := range volumes {
+		total = total.Add(volume)
+	}
+	schedule := make([]Decimal, buckets)
+	var cumulative Decimal
+	for i := range volumes {
+		if total.IsZero() {
+			schedule[i] = newDecimalFromInt(int64(i + 1)).Div(newDecimalFromInt(int64(buckets)))
+			continue
+		}
+		cumulative = cumulative.Add(volumes[i])
+		schedule[i] = cumulative.Div(total)
+	}
+	// 最后一个桶必须覆盖全部数量
+	schedule[buckets-1] = newDecimalFromInt(1)
+	return schedule, nil
+}
+
+// [startTime, endTime] 内的市场 VWAP，用 VWAP_BENCHMARK_INTERVAL k 线的成交额除以成交量
+func getMarketVWAP(symbol string, startTime, endTime time.Time, proxyURL string) (Decimal, error) {
+	var volume, quoteVolume Decimal
+	cursor, end := uint64(startTime.UnixMilli()), uint64(endTime.UnixMilli())
+	limit := initConfig.KLINES_LIMIT
+	for cursor <= end {
+		from, to := cursor, end
+		klines, err := getKlines("", "", symbol, Kline{interval: initConfig.VWAP_BENCHMARK_INTERVAL, startTime: &from, endTime: &to, limit: &limit}, proxyURL)
+		if err != nil {
+			return Decimal{}, err
+		}
+		for _, kline := range klines {
+			volume = volume.Add(decimalOrZero(kline.Volume))
+			quoteVolume = quoteVolume.Add(decimalOrZero(kline.QuoteAssetVolume))
+		}
+		if len(klines) < limit {
+			break
+		}
+		cursor = klines[len(klines)-1].CloseTime + 1
+	}
+	if volume.IsZero() {
+		return Decimal{}, fmt.Errorf("vwap: no volume for %s between %s and %s", symbol, startTime, endTime)
+	}
+	return quoteVolume.Div(volume), nil
+}
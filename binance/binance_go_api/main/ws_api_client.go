@@ -0,0 +1,599 @@
+package main
+
+import (
+	initConfig "binance/binance_go_api/config"
+	"binance_connector"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 请求没有发出去（未连接或写入失败），可以安全地改用其他通道重试
+var errWsNotConnected = errors.New("ws-api: not connected")
+
+// WebSocket API 请求的鉴权类型
+const (
+	wsSecurityNone   = 0
+	wsSecuritySigned = 1
+)
+
+// WebSocket API 请求
+type WsAPIRequest struct {
+	Id     string            `json:"id"`
+	Method string            `json:"method"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// WebSocket API 响应
+type WsAPIResponse struct {
+	Id         string                             `json:"id"`
+	Status     int                                `json:"status"`
+	Result     json.RawMessage                    `json:"result"`
+	Error      *WsAPIError                        `json:"error"`
+	RateLimits []binance_connector.WsAPIRateLimit `json:"rateLimits"`
+}
+
+// WebSocket API 返回的错误
+type WsAPIError struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"msg"`
+}
+
+func (e *WsAPIError) Error() string {
+	return fmt.Sprintf("ws-api: status %d, code %d: %s", e.Status, e.Code, e.Message)
+}
+
+// WebSocket API (ws-api/v3) 客户端，按请求 id 匹配响应
+type WsAPIClient struct {
+	apiKey    string
+	secretKey string
+	endpoint  string
+	proxyURL  string
+	timeout   time.Duration
+
+	mu         sync.Mutex
+	writeMu    sync.Mutex
+	conn       *websocket.Conn
+	pending    map[string]chan *WsAPIResponse
+	nextId     uint64
+	loggedOn   bool
+	logonKey   ed25519.PrivateKey // session.logon 使用的私钥，重连后用它重新登录
+	rateLimits []binance_connector.WsAPIRateLimit
+}
+
+func newWsAPIClient(
+	thisApiKey,
+	thisSecretKey string,
+	wsBaseURL string,
+	proxyURL string,
+) *WsAPIClient {
+	if wsBaseURL == "" {
+		wsBaseURL = initConfig.BASE_WS_PROD_3
+	}
+	return &WsAPIClient{
+		apiKey:    thisApiKey,
+		secretKey: thisSecretKey,
+		endpoint:  wsBaseURL + initConfig.PATH_WS_API,
+		proxyURL:  proxyURL,
+		timeout:   initConfig.TIMEOUT * 10,
+	}
+}
+
+// 建立连接，并启动读取协程
+func (c *WsAPIClient) connect() error {
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: c.timeout,
+	}
+	if c.proxyURL != "" {
+		proxyParsed, err := url.Parse(c.proxyURL)
+		if err != nil {
+			return err
+		}
+		dialer.Proxy = http.ProxyURL(proxyParsed)
+	}
+	conn, _, err := dialer.Dial(c.endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	for _, ch := range c.pending {
+		close(ch)
+	}
+	c.conn = conn
+	c.pending = make(map[string]chan *WsAPIResponse)
+	c.loggedOn = false
+	logonKey := c.logonKey
+	c.mu.Unlock()
+
+	go c.readLoop(conn)
+	// 之前登录过会话时重新登录，否则签名请求会被拒绝
+	if logonKey != nil {
+		if err := c.sessionLogon(context.Background(), logonKey); err != nil {
+			return fmt.Errorf("ws-api: session logon after reconnect: %w", err)
+		}
+	}
+	return nil
+}
+
+// 关闭连接，所有等待中的请求会返回错误
+func (c *WsAPIClient) close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// 连接是否可用
+func (c *WsAPIClient) isConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+// 最近一次响应中的限频使用情况
+func (c *WsAPIClient) getRateLimits() []binance_connector.WsAPIRateLimit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]binance_connector.WsAPIRateLimit(nil), c.rateLimits...)
+}
+
+func (c *WsAPIClient) readLoop(conn *websocket.Conn) {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			var pending map[string]chan *WsAPIResponse
+			c.mu.Lock()
+			// 已经重连过时，新连接的请求不受影响
+			if c.conn == conn || c.conn == nil {
+				c.conn = nil
+				pending = c.pending
+				c.pending = make(map[string]chan *WsAPIResponse)
+			}
+			c.mu.Unlock()
+			// 连接断开，未完成的请求全部失败
+			for _, ch := range pending {
+				close(ch)
+			}
+			return
+		}
+
+		response := new(WsAPIResponse)
+		if err := json.Unmarshal(message, response); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		c.mu.Lock()
+		if response.RateLimits != nil {
+			c.rateLimits = response.RateLimits
+		}
+		ch, ok := c.pending[response.Id]
+		delete(c.pending, response.Id)
+		c.mu.Unlock()
+		if ok {
+			ch <- response
+		}
+	}
+}
+
+// 发送一个请求并等待对应 id 的响应，ctx 没有截止时间时使用客户端默认超时
+func (c *WsAPIClient) call(
+	ctx context.Context,
+	method string,
+	params map[string]string,
+	security int,
+) (*WsAPIResponse, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	if params == nil {
+		params = map[string]string{}
+	}
+
+	c.mu.Lock()
+	conn := c.conn
+	if conn == nil {
+		c.mu.Unlock()
+		return nil, errWsNotConnected
+	}
+	// Ed25519 会话还没有重新登录时不能退回 HMAC 签名，请求视为没有发出
+	if security == wsSecuritySigned && c.logonKey != nil && !c.loggedOn {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%w: session not logged on", errWsNotConnected)
+	}
+	c.nextId++
+	id := strconv.FormatUint(c.nextId, 10)
+	loggedOn := c.loggedOn
+	ch := make(chan *WsAPIResponse, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if security == wsSecuritySigned {
+		params["timestamp"] = strconv.FormatInt(time.Now().UnixMilli(), 10)
+		// session.logon 之后的签名请求只需要 timestamp
+		if !loggedOn {
+			params["apiKey"] = c.apiKey
+			params["signature"] = hmacSignature(c.secretKey, params)
+		}
+	}
+
+	c.writeMu.Lock()
+	err := conn.WriteJSON(WsAPIRequest{Id: id, Method: method, Params: params})
+	c.writeMu.Unlock()
+	if err != nil {
+		c.forget(id)
+		return nil, fmt.Errorf("%w: %v", errWsNotConnected, err)
+	}
+
+	select {
+	case response, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("ws-api: connection closed while waiting for %s", method)
+		}
+		if response.Error != nil {
+			response.Error.Status = response.Status
+			return response, response.Error
+		}
+		return response, nil
+	case <-ctx.Done():
+		c.forget(id)
+		return nil, fmt.Errorf("ws-api: %s: %w", method, ctx.Err())
+	}
+}
+
+func (c *WsAPIClient) forget(id string) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// 调用并把 result 解码到 out
+func (c *WsAPIClient) callInto(ctx context.Context, method string, params map[string]string, security int, out interface{}) error {
+	response, err := c.call(ctx, method, params, security)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	if err := json.Unmarshal(response.Result, out); err != nil {
+		return err
+	}
+	fmt.Println(binance_connector.PrettyPrint(out))
+	return nil
+}
+
+// HMAC-SHA256 签名，参数按 key 排序
+func hmacSignature(secretKey string, params map[string]string) string {
+	return hmacSha256(secretKey, sortedQuery(params))
+}
+
+func hmacSha256(secretKey, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func sortedQuery(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+params[key])
+	}
+	return strings.Join(pairs, "&")
+}
+
+// 使用 Ed25519 API Key 登录会话，之后的签名请求不再需要逐个签名；断线重连后会自动重新登录
+func (c *WsAPIClient) sessionLogon(ctx context.Context, privateKey ed25519.PrivateKey) error {
+	params := map[string]string{
+		"apiKey":    c.apiKey,
+		"timestamp": strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}
+	params["signature"] = base64.StdEncoding.EncodeToString(
+		ed25519.Sign(privateKey, []byte(sortedQuery(params))))
+	if _, err := c.call(ctx, "session.logon", params, wsSecurityNone); err != nil {
+		fmt.Println(err)
+		return err
+	}
+	c.mu.Lock()
+	c.loggedOn = true
+	c.logonKey = privateKey
+	c.mu.Unlock()
+	return nil
+}
+
+// 退出会话
+func (c *WsAPIClient) sessionLogout(ctx context.Context) error {
+	if _, err := c.call(ctx, "session.logout", nil, wsSecurityNone); err != nil {
+		fmt.Println(err)
+		return err
+	}
+	c.mu.Lock()
+	c.loggedOn = false
+	c.logonKey = nil
+	c.mu.Unlock()
+	return nil
+}
+
+// 下单 order.place
+func (c *WsAPIClient) wsPlaceOrder(
+	ctx context.Context,
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	no NewOrder,
+) (*CreateOrderResponse, error) {
+	if err := validateNewOrder(side, orderType, no); err != nil {
+		return nil, err
+	}
+	params := newOrderParams(symbol, side, orderType, no)
+	newOrder := &CreateOrderResponse{RespType: resolveNewOrderRespType(orderType, no)}
+	if err := c.callInto(ctx, "order.place", params, wsSecuritySigned, newOrder); err != nil {
+		return nil, err
+	}
+	return newOrder, nil
+}
+
+// 撤单 order.cancel
+func (c *WsAPIClient) wsCancelOrder(
+	ctx context.Context,
+	symbol string,
+	co CancelOrder,
+) (*binance_connector.CancelOrderResponse, error) {
+	params := map[string]string{"symbol": symbol}
+	if co.orderId != nil {
+		params["orderId"] = strconv.FormatInt(*co.orderId, 10)
+	}
+	if co.origClientOrderId != nil {
+		params["origClientOrderId"] = *co.origClientOrderId
+	}
+	if co.newClientOrderId != nil {
+		params["newClientOrderId"] = *co.newClientOrderId
+	}
+	if co.cancelRestrictions != nil {
+		params["cancelRestrictions"] = *co.cancelRestrictions
+	}
+	if co.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*co.recvWindow)
+	}
+	cancelOrder := new(binance_connector.CancelOrderResponse)
+	if err := c.callInto(ctx, "order.cancel", params, wsSecuritySigned, cancelOrder); err != nil {
+		return nil, err
+	}
+	return cancelOrder, nil
+}
+
+// 撤单后立即下新单 order.cancelReplace
+func (c *WsAPIClient) wsCancelReplace(
+	ctx context.Context,
+	symbol string,
+	side OrderSide,
+	orderType OrderType,
+	cancelReplaceMode CancelReplaceMode,
+	cr CancelReplace,
+) (*binance_connector.CancelReplaceResponse, error) {
+	if err := validateCancelReplace(side, orderType, cancelReplaceMode, cr); err != nil {
+		return nil, err
+	}
+	params := cancelReplaceParams(symbol, side, orderType, cancelReplaceMode, cr)
+	cancelReplace := new(binance_connector.CancelReplaceResponse)
+	if err := c.callInto(ctx, "order.cancelReplace", params, wsSecuritySigned, cancelReplace); err != nil {
+		return nil, err
+	}
+	return cancelReplace, nil
+}
+
+// 减少挂单数量并保留排队优先级 order.amend.keepPriority
+func (c *WsAPIClient) wsAmendOrderKeepPriority(
+	ctx context.Context,
+	symbol string,
+	newQty Decimal,
+	ao AmendOrder,
+) (*AmendOrderResponse, error) {
+	amendOrder := new(AmendOrderResponse)
+	if err := c.callInto(ctx, "order.amend.keepPriority", amendOrderParams(symbol, newQty, ao), wsSecuritySigned, amendOrder); err != nil {
+		return nil, err
+	}
+	return amendOrder, nil
+}
+
+// 查询订单 order.status
+func (c *WsAPIClient) wsQueryOrder(
+	ctx context.Context,
+	symbol string,
+	qo QueryOrder,
+) (*binance_connector.GetOrderResponse, error) {
+	params := map[string]string{"symbol": symbol}
+	if qo.orderId != nil {
+		params["orderId"] = strconv.FormatInt(*qo.orderId, 10)
+	}
+	if qo.origClientOrderId != nil {
+		params["origClientOrderId"] = *qo.origClientOrderId
+	}
+	if qo.recvWindow != nil {
+		params["recvWindow"] = strconv.Itoa(*qo.recvWindow)
+	}
+	queryOrder := new(binance_connector.GetOrderResponse)
+	if err := c.callInto(ctx, "order.status", params, wsSecuritySigned, queryOrder); err != nil {
+		return nil, err
+	}
+	return queryOrder, nil
+}
+
+// 当前挂单 openOrders.status，symbol 为空时返回所有交易对
+func (c *WsAPIClient) wsOpenOrdersStatus(ctx context.Context, symbol string) ([]*binance_connector.NewOpenOrdersResponse, error) {
+	params := map[string]string{}
+	if symbol != "" {
+		params["symbol"] = symbol
+	}
+	var openOrders []*binance_connector.NewOpenOrdersResponse
+	if err := c.callInto(ctx, "openOrders.status", params, wsSecuritySigned, &openOrders); err != nil {
+		return nil, err
+	}
+	return openOrders, nil
+}
+
+// 账户信息 account.status
+func (c *WsAPIClient) wsAccountStatus(ctx context.Context, ai AccountInformation) (*binance_connector.AccountResponse, error) {
+	params := map[string]string{}
+	if ai.omitZeroBalances {
+		params["omitZeroBalances"] = "true"
+	}
+	if ai.recvWindow != 0 {
+		params["recvWindow"] = strconv.Itoa(ai.recvWindow)
+	}
+	accountInformation := new(binance_connector.AccountResponse)
+	if err := c.callInto(ctx, "account.status", params, wsSecuritySigned, accountInformation); err != nil {
+		return nil, err
+	}
+	return accountInformation, nil
+}
+
+// 订单簿 depth
+func (c *WsAPIClient) wsDepth(ctx context.Context, symbol string, limit *int) (*binance_connector.OrderBookResponse, error) {
+	params := map[string]string{"symbol": symbol}
+	if limit != nil {
+		params["limit"] = strconv.Itoa(*limit)
+	}
+	var raw struct {
+		LastUpdateId uint64      `json:"lastUpdateId"`
+		Bids         [][2]string `json:"bids"`
+		Asks         [][2]string `json:"asks"`
+	}
+	response, err := c.call(ctx, "depth", params, wsSecurityNone)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+	if err := json.Unmarshal(response.Result, &raw); err != nil {
+		return nil, err
+	}
+	orderBook := &binance_connector.OrderBookResponse{LastUpdateId: raw.LastUpdateId}
+	if orderBook.Bids, err = parseWsDepthLevels(raw.Bids); err != nil {
+		return nil, err
+	}
+	if orderBook.Asks, err = parseWsDepthLevels(raw.Asks); err != nil {
+		return nil, err
+	}
+	fmt.Println(binance_connector.PrettyPrint(orderBook))
+	return orderBook, nil
+}
+
+func parseWsDepthLevels(raw [][2]string) ([][]*big.Float, error) {
+	levels := make([][]*big.Float, 0, len(raw))
+	for _, row := range raw {
+		price, _, err := big.ParseFloat(row[0], 10, 256, big.ToNearestEven)
+		if err != nil {
+			return nil, err
+		}
+		quantity, _, err := big.ParseFloat(row[1], 10, 256, big.ToNearestEven)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, []*big.Float{price, quantity})
+	}
+	return levels, nil
+}
+
+// k线 klines
+func (c *WsAPIClient) wsKlines(ctx context.Context, symbol string, k Kline) ([]*binance_connector.KlinesResponse, error) {
+	params := map[string]string{
+		"symbol":   symbol,
+		"interval": k.interval,
+	}
+	if k.startTime != nil {
+		params["startTime"] = strconv.FormatUint(*k.startTime, 10)
+	}
+	if k.endTime != nil {
+		params["endTime"] = strconv.FormatUint(*k.endTime, 10)
+	}
+	if k.timeZone != nil {
+		params["timeZone"] = *k.timeZone
+	}
+	if k.limit != nil {
+		params["limit"] = strconv.Itoa(*k.limit)
+	}
+	response, err := c.call(ctx, "klines", params, wsSecurityNone)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+	var rows []json.RawMessage
+	if err := json.Unmarshal(response.Result, &rows); err != nil {
+		return nil, err
+	}
+	klines := make([]*binance_connector.KlinesResponse, 0, len(rows))
+	for _, row := range rows {
+		kline, err := parseKlineRow(row)
+		if err != nil {
+			return nil, err
+		}
+		klines = append(klines, kline)
+	}
+	fmt.Println(binance_connector.PrettyPrint(klines))
+	return klines, nil
+}
+
+// 解析 [openTime, open, high, low, close, volume, closeTime, quoteVolume, trades, takerBase, takerQuote, ignore]
+func parseKlineRow(row json.RawMessage) (*binance_connector.KlinesResponse, error) {
+	var fields []interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(row)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if len(fields) < 11 {
+		return nil, fmt.Errorf("ws-api: malformed kline with %d fields", len(fields))
+	}
+	integer := func(v interface{}) uint64 {
+		n, _ := v.(json.Number)
+		value, _ := strconv.ParseUint(n.String(), 10, 64)
+		return value
+	}
+	text := func(v interface{}) string {
+		s, _ := v.(string)
+		return s
+	}
+	return &binance_connector.KlinesResponse{
+		OpenTime:                 integer(fields[0]),
+		Open:                     text(fields[1]),
+		High:                     text(fields[2]),
+		Low:                      text(fields[3]),
+		Close:                    text(fields[4]),
+		Volume:                   text(fields[5]),
+		CloseTime:                integer(fields[6]),
+		QuoteAssetVolume:         text(fields[7]),
+		NumberOfTrades:           integer(fields[8]),
+		TakerBuyBaseAssetVolume:  text(fields[9]),
+		TakerBuyQuoteAssetVolume: text(fields[10]),
+	}, nil
+}
@@ -5,6 +5,6 @@ go 1.22.2
 require (
 	github.com/binance/binance-connector-go v0.5.2 // indirect
 	github.com/bitly/go-simplejson v0.5.0 // indirect
-	github.com/gorilla/websocket v1.5.1 // indirect
+	github.com/gorilla/websocket v1.5.1
 	golang.org/x/net v0.24.0 // indirect
 )